@@ -14,6 +14,7 @@ import (
 
 	"template/config"
 	"template/internal/store/mysql"
+	"template/pkg/storage"
 )
 
 var (
@@ -48,9 +49,6 @@ func run() error {
 		return nil
 	}
 	command := args[0]
-	if err := goose.SetDialect("mysql"); err != nil {
-		return err
-	}
 	// 初始化配置
 	if err := config.LoadConfig(*configFile); err != nil {
 		log.Fatal(err)
@@ -61,6 +59,13 @@ func run() error {
 		return err
 	}
 	defer db.DB.Close()
+	// 迁移脚本使用MySQL的DDL,其他数据库类型仅用于测试,由AutoMigrate建表
+	if dialect := db.DB.Dialect(); dialect != storage.DialectMySQL {
+		return fmt.Errorf("migrations only support mysql, got %s", dialect)
+	}
+	if err = goose.SetDialect(string(storage.DialectMySQL)); err != nil {
+		return err
+	}
 	var arguments []string
 	if len(args) > 1 {
 		arguments = append(arguments, args[1:]...)
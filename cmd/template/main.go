@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -14,20 +15,35 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 
 	"template/config"
 	"template/internal/code"
+	"template/internal/ctxw"
 	"template/internal/gateway"
+	"template/internal/jobs"
 	"template/internal/router"
+	"template/internal/service"
 	"template/internal/store/mysql"
 	"template/internal/util/v"
+	"template/pkg/async"
+	"template/pkg/audit"
 	"template/pkg/conc/pool"
+	"template/pkg/job"
+	jobstore "template/pkg/job/store/mysql"
 	"template/pkg/json/extension"
 	"template/pkg/logger"
+	"template/pkg/logger/gormx"
+	"template/pkg/syncx"
+	"template/pkg/task"
+	taskstore "template/pkg/task/store/mysql"
+	"template/pkg/tasklog"
 	"template/pkg/validator"
 )
 
@@ -68,11 +84,66 @@ func run() error {
 	defer dataStore.DB.Close()
 	// 后台调用注册实现
 	client := gateway.NewBaseClient()
+	// 任务日志
+	tasklog.InitTaskLogDBClient(ctx, func(context.Context) *gorm.DB {
+		return dataStore.DB.DB
+	}, ctxw.GetTraceID)
+	// 审计日志
+	audit.InitAuditLogDBClient(ctx, func(context.Context) *gorm.DB {
+		return dataStore.DB.DB
+	})
+	// 定时任务
+	scheduler := job.NewTimeWheel()
+	// 多实例部署时通过redis租约选举leader,任务默认仅在leader上执行
+	if addrs := viper.GetStringSlice("redis.addrs"); len(addrs) > 0 {
+		redisClient := redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs:    addrs,
+			Password: viper.GetString("redis.password"),
+		})
+		defer redisClient.Close()
+		if scheduler, err = job.NewClusterScheduler(scheduler,
+			syncx.NewLease(v.ServiceName+":scheduler", redisClient)); err != nil {
+			return err
+		}
+	}
+	// 持久化的任务在重启后重新加载
+	scheduler = job.NewPersistentScheduler(scheduler, jobstore.NewJobStore(dataStore.DB.DB))
+	if err = job.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		return err
+	}
+	if err = async.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		return err
+	}
+	if err = scheduleJobs(ctx, scheduler, service.NewService(dataStore, client)); err != nil {
+		return err
+	}
+	// saga执行器,saga需在恢复前注册
+	sagaExecutor := task.NewSagaExecutor(taskstore.NewSagaStore(dataStore.DB.DB))
+	if err = scheduleSagaResume(ctx, scheduler, sagaExecutor); err != nil {
+		return err
+	}
+	// 发件箱中的消息由定时任务发布到rabbitmq
+	if uri := viper.GetString("rabbitmq.uri"); uri != "" {
+		channel, err := async.NewRabbitmqChannel(async.WithURI(uri), async.WithName("outbox"),
+			async.WithChannelPool(viper.GetInt("rabbitmq.channel_pool")),
+			async.WithAttempt(viper.GetInt("rabbitmq.attempts")),
+			async.WithConfirm(viper.GetDuration("rabbitmq.confirm_timeout")),
+			async.WithLogger(logger.From(ctx)))
+		if err != nil {
+			return err
+		}
+		if closer, ok := channel.(io.Closer); ok {
+			defer closer.Close()
+		}
+		if err = scheduleOutboxRelay(ctx, scheduler, dataStore.Outbox(), channel); err != nil {
+			return err
+		}
+	}
 
 	g := pool.New().WithContext(ctx).WithCancelOnError()
 	srv := &http.Server{
 		Addr:    ":8080",
-		Handler: router.New(dataStore, client),
+		Handler: router.New(dataStore, client, scheduler),
 		BaseContext: func(_ net.Listener) context.Context {
 			return ctx
 		},
@@ -82,6 +153,10 @@ func run() error {
 	g.Go(func(ctx context.Context) error {
 		return startAction(ctx, srv)
 	})
+	// 定时任务调度流程
+	g.Go(func(ctx context.Context) error {
+		return scheduler.Start(ctx)
+	})
 	// 服务关闭流程
 	g.Go(func(ctx context.Context) error {
 		return shutdownAction(ctx, srv)
@@ -109,7 +184,54 @@ func startAction(ctx context.Context, srv *http.Server) error {
 	return srv.ListenAndServe()
 }
 
-const DefaultStopTime = 15 * time.Second
+const (
+	DefaultStopTime           = 15 * time.Second
+	DefaultAreaSyncInterval   = 10 * time.Minute
+	DefaultOutboxInterval     = 5 * time.Second
+	DefaultSagaResumeInterval = time.Minute
+)
+
+func scheduleJobs(ctx context.Context, scheduler job.SchedulerRuntime, srv service.Service) error {
+	areaSyncInterval := viper.GetDuration("job.area_sync_interval")
+	if areaSyncInterval <= 0 {
+		areaSyncInterval = DefaultAreaSyncInterval
+	}
+	var calendar job.CalendarConfig
+	if err := viper.UnmarshalKey("job.calendar", &calendar); err != nil {
+		return errors.Wrap(err, "unmarshal job calendar failed")
+	}
+	calendars, err := calendar.Calendars()
+	if err != nil {
+		return err
+	}
+	var trigger job.Trigger = job.Every(areaSyncInterval)
+	if len(calendars) > 0 {
+		trigger = job.WithCalendar(trigger, calendars...)
+	}
+	if jitter := viper.GetDuration("job.jitter"); jitter > 0 {
+		trigger = job.WithJitter(trigger, jitter)
+	}
+	return scheduler.ScheduleJob(ctx, jobs.NewAreaSyncJob(srv), trigger)
+}
+
+func scheduleOutboxRelay(ctx context.Context, scheduler job.SchedulerRuntime, outbox async.OutboxStore,
+	channel async.Channel) error {
+	interval := viper.GetDuration("rabbitmq.outbox_interval")
+	if interval <= 0 {
+		interval = DefaultOutboxInterval
+	}
+	relay := async.NewOutboxRelay(outbox, channel, async.WithRelayLogFrom(gormx.NewZapGormWriterFrom))
+	return scheduler.ScheduleJob(ctx, relay, job.Every(interval))
+}
+
+// scheduleSagaResume 定时恢复租约过期的saga,集群调度器默认仅在leader上执行
+func scheduleSagaResume(ctx context.Context, scheduler job.SchedulerRuntime, executor *task.SagaExecutor) error {
+	interval := viper.GetDuration("job.saga_resume_interval")
+	if interval <= 0 {
+		interval = DefaultSagaResumeInterval
+	}
+	return scheduler.ScheduleJob(ctx, jobs.NewSagaResumeJob(executor), job.Every(interval))
+}
 
 func shutdownAction(ctx context.Context, srv *http.Server) error {
 	quit := make(chan os.Signal, 1)
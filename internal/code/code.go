@@ -9,11 +9,23 @@ var (
 
 	ErrNoAccount = code.Froze("TEMPLATE.4001100000", "用户不存在")
 	ErrNoUpdate  = code.Froze("TEMPLATE.3041100001", "数据无更新")
+
+	// 100~199 区域类
+
+	ErrAreaNotFound     = code.Froze("TEMPLATE.4041100100", "区域不存在")
+	ErrAreaCodeConflict = code.Froze("TEMPLATE.4091100101", "区域编码已存在")
+
+	// 200~299 站点类
+
+	ErrSiteNotFound = code.Froze("TEMPLATE.4041100200", "站点不存在")
 )
 
 func Loading() error {
 	return code.AddCode(map[code.ErrorCode]struct{}{
-		ErrNoAccount: {},
-		ErrNoUpdate:  {},
+		ErrNoAccount:        {},
+		ErrNoUpdate:         {},
+		ErrAreaNotFound:     {},
+		ErrAreaCodeConflict: {},
+		ErrSiteNotFound:     {},
 	})
 }
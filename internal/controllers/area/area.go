@@ -20,6 +20,82 @@ func NewAreaController(srv service.Service) *AreaController {
 	}
 }
 
+// Create 创建区域
+func (a *AreaController) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.CreateAreaReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.ErrorParam(c, err)
+		return
+	}
+
+	result, err := a.srv.Area().Create(ctx, &req)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// Get 获取区域详情
+func (a *AreaController) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.GetAreaReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.ErrorParam(c, err)
+		return
+	}
+	req.ID = c.Param("id")
+
+	result, err := a.srv.Area().Get(ctx, &req)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	if resp.NotModified(c, result.Version) {
+		return
+	}
+	resp.SetETag(c, result.Version)
+	c.JSON(http.StatusOK, result)
+}
+
+// Update 更新区域,PUT和PATCH均只更新请求中携带的字段
+func (a *AreaController) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.UpdateAreaReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.ErrorParam(c, err)
+		return
+	}
+	version, ok, err := resp.IfMatch(c)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	if ok {
+		req.Version = &version
+	}
+
+	version, err = a.srv.Area().Update(ctx, c.Param("id"), &req)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	// 返回新的ETag,客户端无需重新查询即可继续条件更新
+	resp.SetETag(c, version)
+	c.Status(http.StatusNoContent)
+}
+
+// Delete 删除区域
+func (a *AreaController) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := a.srv.Area().Delete(ctx, c.Param("id")); err != nil {
+		resp.Error(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 // List 获取区域列表
 func (a *AreaController) List(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -7,7 +7,6 @@ import (
 
 	"github.com/pkg/errors"
 
-	"template/pkg/client"
 	"template/pkg/code"
 	jsonx "template/pkg/json"
 )
@@ -21,7 +20,7 @@ type Response struct {
 type Parser struct {
 }
 
-func (p Parser) Parse(resp *http.Response, result interface{}, opts ...client.Func) error {
+func (p Parser) Parse(resp *http.Response, result interface{}, opts ...func(*http.Response) error) error {
 	for _, opt := range opts {
 		if err := opt(resp); err != nil {
 			return err
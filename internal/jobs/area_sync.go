@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"template/internal/service"
+	"template/pkg/logger"
+	"template/pkg/tasklog"
+)
+
+func NewAreaSyncJob(srv service.Service) *areaSyncJob {
+	return &areaSyncJob{
+		srv: srv,
+	}
+}
+
+// areaSyncJob 将dcs网关中的区域同步到本地区域表
+type areaSyncJob struct {
+	srv service.Service
+}
+
+func (a areaSyncJob) Description() string {
+	return "sync areas from dcs gateway"
+}
+
+// Key returns the unique key for the Job.
+func (a areaSyncJob) Key() string {
+	return "jobs.areaSyncJob"
+}
+
+// Execute is called by a SchedulerRuntime when the Trigger associated with this job fires.
+func (a areaSyncJob) Execute(ctx context.Context) {
+	taskLog := tasklog.NewTaskLog()
+	if err := taskLog.Create(ctx, a.Description(), a.Key()); err != nil {
+		logger.From(ctx).Error("fail to create task log", zap.Error(err))
+		return
+	}
+	result, err := a.srv.Area().Sync(ctx, func(complete, total int) {
+		_ = taskLog.ResourceNum(total).RefreshProgress(ctx, complete)
+	})
+	if err != nil {
+		logger.From(ctx).Error("fail to sync areas", zap.Error(err))
+	}
+	_ = taskLog.Finish(ctx, result, err)
+}
@@ -0,0 +1,3 @@
+// Package jobs contains the background jobs scheduled by pkg/job,
+// such as synchronizing data from the gateway.
+package jobs
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"template/pkg/logger"
+	"template/pkg/task"
+)
+
+func NewSagaResumeJob(executor *task.SagaExecutor) *sagaResumeJob {
+	return &sagaResumeJob{
+		executor: executor,
+	}
+}
+
+// sagaResumeJob 定时恢复租约过期的saga,多实例部署时仅由集群调度器的leader执行
+type sagaResumeJob struct {
+	executor *task.SagaExecutor
+}
+
+func (s sagaResumeJob) Description() string {
+	return "resume unfinished sagas"
+}
+
+// Key returns the unique key for the Job.
+func (s sagaResumeJob) Key() string {
+	return "jobs.sagaResumeJob"
+}
+
+// Execute is called by a SchedulerRuntime when the Trigger associated with this job fires.
+func (s sagaResumeJob) Execute(ctx context.Context) {
+	if err := s.executor.Resume(ctx); err != nil {
+		logger.From(ctx).Error("fail to resume sagas", zap.Error(err))
+	}
+}
@@ -8,7 +8,7 @@ import (
 type Area struct {
 	storage.Base
 	AreaName      string `gorm:"column:area_name;type:varchar(255);comment:区域名称;NOT NULL" json:"area_name"`
-	AreaCode      string `gorm:"column:area_code;type:varchar(255);comment:区域编码;NOT NULL;uniqueIndex:idx_area_code" json:"area_code"` // nolint:lll
+	AreaCode      string `gorm:"column:area_code;type:varchar(255);comment:区域编码;NOT NULL;uniqueIndex:idx_area_code,priority:1" json:"area_code"` // nolint:lll
 	AreaDesc      string `gorm:"column:area_desc;type:text;comment:区域描述" json:"area_desc"`
 	Status        string `gorm:"column:status;type:varchar(16);comment:区域使用状态" json:"status"`
 	CountryName   string `gorm:"column:country_name;type:varchar(30);default:中国;comment:国家名称;NOT NULL" json:"country_name"`
@@ -17,5 +17,35 @@ type Area struct {
 	ProvinceCode  string `gorm:"column:province_code;type:varchar(30);comment:省份编码" json:"province_code"`
 	ProvinceName  string `gorm:"column:province_name;type:varchar(30);comment:省份名称" json:"province_name"`
 
-	Deleted storage.Deleted `gorm:"column:deleted;type:bigint(20) unsigned" json:"-"`
+	Version storage.Version `gorm:"column:version;comment:版本号;NOT NULL;default:1" json:"version"`
+	Deleted storage.Deleted `gorm:"column:deleted;uniqueIndex:idx_area_code,priority:2" json:"-"`
+
+	Sites []*Site `gorm:"foreignKey:AreaID" json:"sites,omitempty"`
+}
+
+// AuditResourceType 区域的变更记录审计日志
+func (Area) AuditResourceType() string {
+	return "area"
+}
+
+// Site 站点信息表
+type Site struct {
+	storage.Base
+	AreaID   uint64 `gorm:"column:area_id;comment:区域ID;NOT NULL;index:idx_site_area_id" json:"area_id,string"` // nolint:lll
+	RemoteID uint64 `gorm:"column:remote_id;comment:网关中的站点ID;NOT NULL" json:"remote_id,string"`
+	SiteName string `gorm:"column:site_name;type:varchar(255);comment:站点名称;NOT NULL" json:"site_name"`
+
+	Deleted storage.Deleted `gorm:"column:deleted" json:"-"`
+
+	SiteNets []*SiteNet `gorm:"foreignKey:SiteID" json:"site_nets,omitempty"`
+}
+
+// SiteNet 站点网络类型表
+type SiteNet struct {
+	storage.Base
+	SiteID   uint64 `gorm:"column:site_id;comment:站点ID;NOT NULL;index:idx_site_net_site_id" json:"site_id,string"` // nolint:lll
+	RemoteID uint64 `gorm:"column:remote_id;comment:网关中的站点网络类型ID;NOT NULL" json:"remote_id,string"`
+	NetType  string `gorm:"column:net_type;type:varchar(64);comment:网络类型,如ChinaUnicom;NOT NULL" json:"net_type"`
+
+	Deleted storage.Deleted `gorm:"column:deleted" json:"-"`
 }
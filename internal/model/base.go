@@ -2,12 +2,12 @@ package model
 
 import (
 	"context"
-	"fmt"
-	"strings"
 
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 
 	"template/internal/util/v"
+	"template/pkg/code"
 	"template/pkg/storage"
 )
 
@@ -47,29 +47,9 @@ type Sort struct {
 	SortField string `form:"sort" json:"sort" binding:"omitempty,order"`
 }
 
-func (s *Sort) Build(_ context.Context, query *gorm.DB, opts ...storage.SQLOption) *gorm.DB {
-	// SortField 给多个字段排序
-	// created_at, id asc => order by created_at desc, id asc
-	defaultCreatedAtSort := true
-	if s.SortField != "" {
-		sorts := strings.Split(s.SortField, ",")
-		for _, field := range sorts {
-			if strings.Contains(field, "created_at") {
-				defaultCreatedAtSort = false
-			}
-			// 如果排序没有明确要按asc或desc来排序，则按照默认排序(倒序)
-			if !strings.HasSuffix(field, "asc") && !strings.HasSuffix(field, "desc") {
-				query = query.Order(fmt.Sprintf("%s desc", field))
-				continue
-			}
-			query = query.Order(field)
-		}
-	}
-	if defaultCreatedAtSort {
-		// 默认按created_at倒序排列
-		query = query.Order("created_at desc")
-	}
-	return query
+// Build 以查询模型的列作为白名单校验排序条件,见 storage.Sort
+func (s *Sort) Build(ctx context.Context, query *gorm.DB, opts ...storage.SQLOption) *gorm.DB {
+	return (&storage.Sort{SortField: s.SortField}).Build(ctx, query, opts...)
 }
 
 type ListQuery struct {
@@ -77,9 +57,25 @@ type ListQuery struct {
 	Sort
 	Select []string `json:"-"`
 	IDs    []string `form:"id" binding:"omitempty,number"`
+	// 过滤条件,例如: area_name eq 'a' and (status in (on,off) or created_at gt 2022-01-01)
+	// 操作符: eq ne gt ge lt le like in nin between,语法见 storage.Filter
+	Filter string `form:"filter" json:"filter" binding:"omitempty,max=2048"`
 }
 
+// Build 以查询模型(需先通过 Model 指定)的列作为白名单校验过滤及排序条件后组装查询,
+// 条件不合法时在查询中记录 code.ErrInvalidParam
 func (l *ListQuery) Build(ctx context.Context, query *gorm.DB, opts ...storage.SQLOption) *gorm.DB {
+	filter, err := storage.NewFilter(query.Statement.Model, l.Filter, l.SortField)
+	if err != nil {
+		_ = query.AddError(errors.WithStack(code.ErrInvalidParam.WithResult(err.Error())))
+		return query
+	}
+	query = l.where(query)
+	query = filter.Build(ctx, query, opts...)
+	return l.Pagination.Build(ctx, query, opts...)
+}
+
+func (l *ListQuery) where(query *gorm.DB) *gorm.DB {
 	if length := len(l.Select); length > 0 {
 		if length == 1 {
 			query = query.Select(l.Select[0])
@@ -99,7 +95,5 @@ func (l *ListQuery) Build(ctx context.Context, query *gorm.DB, opts ...storage.S
 			query = query.Where("id IN (?)", l.IDs)
 		}
 	}
-
-	query = l.Sort.Build(ctx, query, opts...)
-	return l.Pagination.Build(ctx, query, opts...)
+	return query
 }
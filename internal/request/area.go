@@ -4,6 +4,9 @@ import (
 	"template/internal/model"
 )
 
+// ExpandSites 查询区域时一并返回站点及站点网络
+const ExpandSites = "sites"
+
 type QueryAreaListReq struct {
 	model.ListQuery
 	AreaName      string `json:"area_name" form:"area_name"`
@@ -12,4 +15,90 @@ type QueryAreaListReq struct {
 	BigRegionName string `json:"big_region_name" form:"big_region_name"`
 	ProvinceCode  string `json:"province_code" form:"province_code"`
 	ProvinceName  string `json:"province_name" form:"province_name"`
+	// 扩展返回的关联信息,可选值: sites
+	Expand string `json:"expand" form:"expand" binding:"omitempty,oneof=sites"`
+}
+
+type GetAreaReq struct {
+	ID string `json:"-" form:"-"`
+	// 扩展返回的关联信息,可选值: sites
+	Expand string `json:"expand" form:"expand" binding:"omitempty,oneof=sites"`
+}
+
+type CreateAreaReq struct {
+	// 区域名称
+	AreaName string `json:"area_name" binding:"required,max=255"`
+	// 区域编码
+	AreaCode string `json:"area_code" binding:"required,max=255"`
+	// 区域描述
+	AreaDesc string `json:"area_desc"`
+	// 区域使用状态
+	Status string `json:"status" binding:"omitempty,max=16"`
+	// 国家名称
+	CountryName string `json:"country_name" binding:"omitempty,max=30"`
+	// 大区编码
+	BigRegionCode string `json:"big_region_code" binding:"omitempty,max=30"`
+	// 大区名称
+	BigRegionName string `json:"big_region_name" binding:"omitempty,max=30"`
+	// 省份编码
+	ProvinceCode string `json:"province_code" binding:"omitempty,max=30"`
+	// 省份名称
+	ProvinceName string `json:"province_name" binding:"omitempty,max=30"`
+}
+
+// UpdateAreaReq 字段为nil时不更新
+type UpdateAreaReq struct {
+	// 区域名称
+	AreaName *string `json:"area_name" binding:"omitempty,min=1,max=255"`
+	// 区域编码
+	AreaCode *string `json:"area_code" binding:"omitempty,min=1,max=255"`
+	// 区域描述
+	AreaDesc *string `json:"area_desc"`
+	// 区域使用状态
+	Status *string `json:"status" binding:"omitempty,max=16"`
+	// 国家名称
+	CountryName *string `json:"country_name" binding:"omitempty,max=30"`
+	// 大区编码
+	BigRegionCode *string `json:"big_region_code" binding:"omitempty,max=30"`
+	// 大区名称
+	BigRegionName *string `json:"big_region_name" binding:"omitempty,max=30"`
+	// 省份编码
+	ProvinceCode *string `json:"province_code" binding:"omitempty,max=30"`
+	// 省份名称
+	ProvinceName *string `json:"province_name" binding:"omitempty,max=30"`
+	// 版本号,不为空时仅在版本一致时更新,也可通过If-Match请求头指定
+	Version *int64 `json:"version" binding:"omitempty,min=1"`
+}
+
+// Values 转换为需要更新的列,不包含版本号
+func (u *UpdateAreaReq) Values() map[string]interface{} {
+	values := make(map[string]interface{})
+	if u.AreaName != nil {
+		values["area_name"] = *u.AreaName
+	}
+	if u.AreaCode != nil {
+		values["area_code"] = *u.AreaCode
+	}
+	if u.AreaDesc != nil {
+		values["area_desc"] = *u.AreaDesc
+	}
+	if u.Status != nil {
+		values["status"] = *u.Status
+	}
+	if u.CountryName != nil {
+		values["country_name"] = *u.CountryName
+	}
+	if u.BigRegionCode != nil {
+		values["big_region_code"] = *u.BigRegionCode
+	}
+	if u.BigRegionName != nil {
+		values["big_region_name"] = *u.BigRegionName
+	}
+	if u.ProvinceCode != nil {
+		values["province_code"] = *u.ProvinceCode
+	}
+	if u.ProvinceName != nil {
+		values["province_name"] = *u.ProvinceName
+	}
+	return values
 }
@@ -36,4 +36,46 @@ type ListQueryAreaList struct {
 	ProvinceCode string `json:"province_code"`
 	// 省份名称
 	ProvinceName string `json:"province_name"`
+	// 版本号,更新时可通过If-Match请求头或version字段进行乐观锁校验
+	Version int64 `json:"version"`
+	// 站点信息,expand=sites时返回
+	Sites []*SiteRes `json:"sites,omitempty"`
+}
+
+type SiteRes struct {
+	// ID
+	ID string `json:"id"`
+	// 网关中的站点ID
+	RemoteID uint64 `json:"remote_id,string"`
+	// 站点名称
+	SiteName string `json:"site_name"`
+	// 站点网络类型
+	SiteNets []*SiteNetRes `json:"site_nets"`
+}
+
+type SiteNetRes struct {
+	// ID
+	ID string `json:"id"`
+	// 网关中的站点网络类型ID
+	RemoteID uint64 `json:"remote_id,string"`
+	// 网络类型, ChinaUnicom
+	NetType string `json:"net_type"`
+}
+
+type CreateAreaRes struct {
+	// ID
+	ID string `json:"id"`
+}
+
+type GetAreaRes struct {
+	ListQueryAreaList
+}
+
+type SyncAreaRes struct {
+	// 新增区域数量
+	Created int `json:"created"`
+	// 更新区域数量
+	Updated int `json:"updated"`
+	// 删除区域数量
+	Deleted int `json:"deleted"`
 }
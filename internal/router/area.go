@@ -14,6 +14,11 @@ func registerArea(router *gin.RouterGroup, srv service.Service) {
 		areaController := area.NewAreaController(srv)
 		// public api
 		userGroup.Use(middleware.CheckHeaders)
+		userGroup.POST("", areaController.Create)
 		userGroup.GET("/", areaController.List)
+		userGroup.GET("/:id", areaController.Get)
+		userGroup.PUT("/:id", areaController.Update)
+		userGroup.PATCH("/:id", areaController.Update)
+		userGroup.DELETE("/:id", areaController.Delete)
 	}
 }
@@ -2,19 +2,24 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"template/internal/controllers"
 	"template/internal/gateway"
 	"template/internal/service"
 	"template/internal/store"
+	"template/pkg/audit"
+	"template/pkg/job"
 	"template/pkg/logger/gormx"
 	"template/pkg/middlewares"
+	"template/pkg/tasklog"
 )
 
 // New gin router
-func New(store store.Store, client gateway.Client) *gin.Engine {
+func New(store store.Store, client gateway.Client, scheduler job.SchedulerRuntime) *gin.Engine {
 	router := gin.New()
 	router.GET("/health", controllers.Health)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	// add middlewares
 	router.Use(
 		middlewares.AccessLog(gormx.NewZapGormWriterFrom),
@@ -22,6 +27,12 @@ func New(store store.Store, client gateway.Client) *gin.Engine {
 	)
 	srv := service.NewService(store, client)
 	v1RouterGroup(router, srv)
+	// 任务日志查询
+	tasklog.RegisterAPI(router)
+	// 审计日志查询
+	audit.RegisterAPI(router)
+	// 定时任务查询
+	job.RegisterAPI(router, scheduler)
 
 	return router
 }
@@ -3,6 +3,7 @@ package area
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
 	"template/internal/gateway"
@@ -10,11 +11,19 @@ import (
 	"template/internal/request"
 	"template/internal/response"
 	"template/internal/store"
+	"template/pkg/code"
 	"template/pkg/logger"
 )
 
 type AreaSrv interface {
+	Create(ctx context.Context, req *request.CreateAreaReq) (*response.CreateAreaRes, error)
+	Get(ctx context.Context, req *request.GetAreaReq) (*response.GetAreaRes, error)
+	// Update 更新区域,返回更新后的版本号
+	Update(ctx context.Context, id string, req *request.UpdateAreaReq) (int64, error)
+	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, req *request.QueryAreaListReq) (*response.QueryAreaListRes, error)
+	// Sync 从dcs网关同步区域信息,onProgress在每页拉取完成后回调
+	Sync(ctx context.Context, onProgress func(complete, total int)) (*response.SyncAreaRes, error)
 }
 
 func NewAreaSrv(store store.Store, client gateway.Client) AreaSrv {
@@ -29,8 +38,110 @@ type areaSrv struct {
 	client gateway.Client
 }
 
+func (a areaSrv) Create(ctx context.Context, req *request.CreateAreaReq) (*response.CreateAreaRes, error) {
+	area := &model.Area{
+		AreaName:      req.AreaName,
+		AreaCode:      req.AreaCode,
+		AreaDesc:      req.AreaDesc,
+		Status:        req.Status,
+		CountryName:   req.CountryName,
+		BigRegionCode: req.BigRegionCode,
+		BigRegionName: req.BigRegionName,
+		ProvinceCode:  req.ProvinceCode,
+		ProvinceName:  req.ProvinceName,
+	}
+	if err := a.getStore(ctx).Area().Create(ctx, area); err != nil {
+		logger.From(ctx).Error("The database failed to create the area",
+			zap.Any("param", req), zap.Error(err))
+		return nil, err
+	}
+	return &response.CreateAreaRes{ID: area.PK()}, nil
+}
+
+func (a areaSrv) Get(ctx context.Context, req *request.GetAreaReq) (*response.GetAreaRes, error) {
+	var (
+		area *model.Area
+		err  error
+	)
+	if req.Expand == request.ExpandSites {
+		area, err = a.getStore(ctx).Area().GetWithSites(ctx, req.ID)
+	} else {
+		area, err = a.getStore(ctx).Area().Get(ctx, req.ID)
+	}
+	if err != nil {
+		logger.From(ctx).Error("The database failed to query the area",
+			zap.Any("param", req), zap.Error(err))
+		return nil, err
+	}
+	return &response.GetAreaRes{ListQueryAreaList: *toAreaRes(area)}, nil
+}
+
+func (a areaSrv) Update(ctx context.Context, id string, req *request.UpdateAreaReq) (int64, error) {
+	values := req.Values()
+	var version int64
+	err := a.getStore(ctx).Transaction(ctx, func(ctx context.Context, s store.Store) error {
+		area, err := s.Area().Get(ctx, id)
+		if err != nil {
+			logger.From(ctx).Error("The database failed to query the area",
+				zap.String("id", id), zap.Error(err))
+			return err
+		}
+		version = int64(area.Version)
+		if req.Version != nil {
+			if version != *req.Version {
+				return errors.WithStack(code.ErrVersionConflict.WithResult(area.Version))
+			}
+			// 以版本号作为更新条件,防止并发更新相互覆盖
+			if len(values) > 0 {
+				values["version"] = *req.Version
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		if err = s.Area().Update(ctx, id, values); err != nil {
+			logger.From(ctx).Error("The database failed to update the area",
+				zap.String("id", id), zap.Any("param", req), zap.Error(err))
+			return err
+		}
+		// 重新查询更新后的版本号
+		if area, err = s.Area().Get(ctx, id); err != nil {
+			logger.From(ctx).Error("The database failed to query the area",
+				zap.String("id", id), zap.Error(err))
+			return err
+		}
+		version = int64(area.Version)
+		return nil
+	})
+	return version, err
+}
+
+func (a areaSrv) Delete(ctx context.Context, id string) error {
+	return a.getStore(ctx).Transaction(ctx, func(ctx context.Context, s store.Store) error {
+		area, err := s.Area().GetWithSites(ctx, id)
+		if err != nil {
+			logger.From(ctx).Error("The database failed to query the area",
+				zap.String("id", id), zap.Error(err))
+			return err
+		}
+		for _, site := range area.Sites {
+			if err = s.Site().Delete(ctx, site.PK()); err != nil {
+				logger.From(ctx).Error("The database failed to delete the site",
+					zap.String("id", site.PK()), zap.Error(err))
+				return err
+			}
+		}
+		if err = s.Area().Delete(ctx, id); err != nil {
+			logger.From(ctx).Error("The database failed to delete the area",
+				zap.String("id", id), zap.Error(err))
+			return err
+		}
+		return nil
+	})
+}
+
 func (a areaSrv) List(ctx context.Context, req *request.QueryAreaListReq) (*response.QueryAreaListRes, error) {
-	areas, err := a.store.Area().List(ctx, req)
+	areas, err := a.getStore(ctx).Area().List(ctx, req)
 	if err != nil {
 		logger.From(ctx).Error("The database failed to query the area list",
 			zap.Any("param", req), zap.Error(err))
@@ -45,21 +156,51 @@ func (a areaSrv) List(ctx context.Context, req *request.QueryAreaListReq) (*resp
 		}}
 	results.List = make([]*response.ListQueryAreaList, len(areas))
 	for i, area := range areas {
-		results.List[i] = &response.ListQueryAreaList{
-			ID:            area.PK(),
-			CreatedAt:     area.CreatedAt,
-			UpdatedAt:     area.UpdatedAt,
-			AreaName:      area.AreaName,
-			AreaCode:      area.AreaCode,
-			AreaDesc:      area.AreaDesc,
-			Status:        area.Status,
-			CountryName:   area.CountryName,
-			BigRegionCode: area.BigRegionCode,
-			BigRegionName: area.BigRegionName,
-			ProvinceCode:  area.ProvinceCode,
-			ProvinceName:  area.ProvinceName,
-		}
+		results.List[i] = toAreaRes(area)
 	}
 
 	return &results, nil
 }
+
+// getStore 上下文中存在事务时使用事务中的Store
+func (a areaSrv) getStore(ctx context.Context) store.Store {
+	return store.FromContext(ctx, a.store)
+}
+
+func toAreaRes(area *model.Area) *response.ListQueryAreaList {
+	res := &response.ListQueryAreaList{
+		ID:            area.PK(),
+		CreatedAt:     area.CreatedAt,
+		UpdatedAt:     area.UpdatedAt,
+		AreaName:      area.AreaName,
+		AreaCode:      area.AreaCode,
+		AreaDesc:      area.AreaDesc,
+		Status:        area.Status,
+		CountryName:   area.CountryName,
+		BigRegionCode: area.BigRegionCode,
+		BigRegionName: area.BigRegionName,
+		ProvinceCode:  area.ProvinceCode,
+		ProvinceName:  area.ProvinceName,
+		Version:       int64(area.Version),
+	}
+	if area.Sites == nil {
+		return res
+	}
+	res.Sites = make([]*response.SiteRes, len(area.Sites))
+	for i, site := range area.Sites {
+		res.Sites[i] = &response.SiteRes{
+			ID:       site.PK(),
+			RemoteID: site.RemoteID,
+			SiteName: site.SiteName,
+			SiteNets: make([]*response.SiteNetRes, len(site.SiteNets)),
+		}
+		for j, siteNet := range site.SiteNets {
+			res.Sites[i].SiteNets[j] = &response.SiteNetRes{
+				ID:       siteNet.PK(),
+				RemoteID: siteNet.RemoteID,
+				NetType:  siteNet.NetType,
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,260 @@
+package area
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"template/internal/gateway/dcs"
+	"template/internal/model"
+	"template/internal/request"
+	"template/internal/response"
+	"template/internal/store"
+	"template/pkg/logger"
+)
+
+// syncPageSize 每次从网关拉取的区域数量
+const syncPageSize = 100
+
+func (a areaSrv) Sync(ctx context.Context, onProgress func(complete, total int)) (*response.SyncAreaRes, error) {
+	remotes, err := a.fetchRemoteAreas(ctx, onProgress)
+	if err != nil {
+		return nil, err
+	}
+	var result response.SyncAreaRes
+	err = a.getStore(ctx).Transaction(ctx, func(ctx context.Context, s store.Store) error {
+		req := &request.QueryAreaListReq{Expand: request.ExpandSites}
+		req.PageSize = -1
+		locals, err := s.Area().List(ctx, req)
+		if err != nil {
+			logger.From(ctx).Error("The database failed to query the area list", zap.Error(err))
+			return err
+		}
+		localMap := make(map[string]*model.Area, len(locals))
+		for _, local := range locals {
+			localMap[local.AreaCode] = local
+		}
+
+		for _, remote := range remotes {
+			local, ok := localMap[remote.AreaCode]
+			if !ok {
+				if err = s.Area().Create(ctx, newAreaFromRemote(remote)); err != nil {
+					logger.From(ctx).Error("The database failed to create the area",
+						zap.String("area_code", remote.AreaCode), zap.Error(err))
+					return err
+				}
+				result.Created++
+				continue
+			}
+			delete(localMap, remote.AreaCode)
+			values := diffArea(local, remote)
+			if len(values) != 0 {
+				if err = s.Area().Update(ctx, local.PK(), values); err != nil {
+					logger.From(ctx).Error("The database failed to update the area",
+						zap.String("area_code", remote.AreaCode), zap.Error(err))
+					return err
+				}
+			}
+			sitesChanged, err := syncSites(ctx, s, local, remote.Sites)
+			if err != nil {
+				logger.From(ctx).Error("The database failed to sync the sites",
+					zap.String("area_code", remote.AreaCode), zap.Error(err))
+				return err
+			}
+			if len(values) != 0 || sitesChanged {
+				result.Updated++
+			}
+		}
+
+		// 网关中已不存在的区域标记删除
+		for _, local := range localMap {
+			if _, err = syncSites(ctx, s, local, nil); err != nil {
+				logger.From(ctx).Error("The database failed to delete the sites",
+					zap.String("area_code", local.AreaCode), zap.Error(err))
+				return err
+			}
+			if err = s.Area().Delete(ctx, local.PK()); err != nil {
+				logger.From(ctx).Error("The database failed to delete the area",
+					zap.String("area_code", local.AreaCode), zap.Error(err))
+				return err
+			}
+			result.Deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// fetchRemoteAreas 分页拉取网关中的全部区域
+func (a areaSrv) fetchRemoteAreas(ctx context.Context, onProgress func(complete, total int)) ([]*dcs.Area, error) {
+	var remotes []*dcs.Area
+	param := &dcs.QueryAreaListParam{
+		PageNum:  1,
+		PageSize: syncPageSize,
+	}
+	for {
+		rsp, err := a.client.Area().List(ctx, param)
+		if err != nil {
+			logger.From(ctx).Error("The gateway failed to query the area list",
+				zap.Any("param", param), zap.Error(err))
+			return nil, err
+		}
+		remotes = append(remotes, rsp.List...)
+		if onProgress != nil {
+			onProgress(len(rsp.List), rsp.Total)
+		}
+		if len(rsp.List) == 0 || len(remotes) >= rsp.Total {
+			return remotes, nil
+		}
+		param.PageNum++
+	}
+}
+
+func newAreaFromRemote(remote *dcs.Area) *model.Area {
+	area := &model.Area{
+		AreaName:      remote.AreaName,
+		AreaCode:      remote.AreaCode,
+		AreaDesc:      remote.AreaDesc,
+		Status:        remote.Status,
+		CountryName:   remote.CountryName,
+		BigRegionCode: remote.BigRegionCode,
+		BigRegionName: remote.BigRegionName,
+		ProvinceCode:  remote.ProvinceCode,
+		ProvinceName:  remote.ProvinceName,
+		Sites:         make([]*model.Site, len(remote.Sites)),
+	}
+	for i := range remote.Sites {
+		area.Sites[i] = newSiteFromRemote(&remote.Sites[i])
+	}
+	return area
+}
+
+func newSiteFromRemote(remote *dcs.Site) *model.Site {
+	site := &model.Site{
+		RemoteID: remote.ID,
+		SiteName: remote.Name,
+		SiteNets: make([]*model.SiteNet, len(remote.SiteNets)),
+	}
+	for i, siteNet := range remote.SiteNets {
+		site.SiteNets[i] = &model.SiteNet{
+			RemoteID: siteNet.ID,
+			NetType:  siteNet.NetType,
+		}
+	}
+	return site
+}
+
+// syncSites 同步区域下的站点,remotes为空时删除区域下的全部站点
+func syncSites(ctx context.Context, s store.Store, area *model.Area, remotes []dcs.Site) (bool, error) {
+	var changed bool
+	localMap := make(map[uint64]*model.Site, len(area.Sites))
+	for _, site := range area.Sites {
+		localMap[site.RemoteID] = site
+	}
+	for i := range remotes {
+		remote := &remotes[i]
+		local, ok := localMap[remote.ID]
+		if !ok {
+			site := newSiteFromRemote(remote)
+			site.AreaID = area.ID
+			if err := s.Site().Create(ctx, site); err != nil {
+				return false, err
+			}
+			changed = true
+			continue
+		}
+		delete(localMap, remote.ID)
+		if local.SiteName != remote.Name {
+			if err := s.Site().Update(ctx, local.PK(), map[string]interface{}{
+				"site_name": remote.Name,
+			}); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+		siteNetsChanged, err := syncSiteNets(ctx, s, local, remote.SiteNets)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || siteNetsChanged
+	}
+	for _, local := range localMap {
+		if err := s.Site().Delete(ctx, local.PK()); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// syncSiteNets 同步站点下的站点网络
+func syncSiteNets(ctx context.Context, s store.Store, site *model.Site, remotes []dcs.SiteNet) (bool, error) {
+	var changed bool
+	localMap := make(map[uint64]*model.SiteNet, len(site.SiteNets))
+	for _, siteNet := range site.SiteNets {
+		localMap[siteNet.RemoteID] = siteNet
+	}
+	for _, remote := range remotes {
+		local, ok := localMap[remote.ID]
+		if !ok {
+			if err := s.SiteNet().Create(ctx, &model.SiteNet{
+				SiteID:   site.ID,
+				RemoteID: remote.ID,
+				NetType:  remote.NetType,
+			}); err != nil {
+				return false, err
+			}
+			changed = true
+			continue
+		}
+		delete(localMap, remote.ID)
+		if local.NetType != remote.NetType {
+			if err := s.SiteNet().Update(ctx, local.PK(), map[string]interface{}{
+				"net_type": remote.NetType,
+			}); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+	}
+	for _, local := range localMap {
+		if err := s.SiteNet().Delete(ctx, local.PK()); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// diffArea 返回本地区域与网关区域不一致的列
+func diffArea(local *model.Area, remote *dcs.Area) map[string]interface{} {
+	values := make(map[string]interface{})
+	if local.AreaName != remote.AreaName {
+		values["area_name"] = remote.AreaName
+	}
+	if local.AreaDesc != remote.AreaDesc {
+		values["area_desc"] = remote.AreaDesc
+	}
+	if local.Status != remote.Status {
+		values["status"] = remote.Status
+	}
+	if local.CountryName != remote.CountryName {
+		values["country_name"] = remote.CountryName
+	}
+	if local.BigRegionCode != remote.BigRegionCode {
+		values["big_region_code"] = remote.BigRegionCode
+	}
+	if local.BigRegionName != remote.BigRegionName {
+		values["big_region_name"] = remote.BigRegionName
+	}
+	if local.ProvinceCode != remote.ProvinceCode {
+		values["province_code"] = remote.ProvinceCode
+	}
+	if local.ProvinceName != remote.ProvinceName {
+		values["province_name"] = remote.ProvinceName
+	}
+	return values
+}
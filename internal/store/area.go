@@ -8,5 +8,11 @@ import (
 )
 
 type AreaStore interface {
+	Create(ctx context.Context, area *model.Area) error
+	Get(ctx context.Context, id string) (*model.Area, error)
+	// GetWithSites 查询区域并预加载站点及站点网络
+	GetWithSites(ctx context.Context, id string) (*model.Area, error)
+	Update(ctx context.Context, id string, values map[string]interface{}) error
+	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, req *request.QueryAreaListReq) ([]*model.Area, error)
 }
@@ -0,0 +1,27 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `area`
+(
+    `id`              BIGINT(20) UNSIGNED NOT NULL COMMENT 'id',
+    `area_name`       VARCHAR(255) NOT NULL COMMENT '区域名称',
+    `area_code`       VARCHAR(255) NOT NULL COMMENT '区域编码',
+    `area_desc`       TEXT NULL DEFAULT NULL COMMENT '区域描述',
+    `status`          VARCHAR(16) NULL DEFAULT NULL COMMENT '区域使用状态',
+    `country_name`    VARCHAR(30)  NOT NULL DEFAULT '中国' COMMENT '国家名称',
+    `big_region_code` VARCHAR(30) NULL DEFAULT NULL COMMENT '大区编码',
+    `big_region_name` VARCHAR(30) NULL DEFAULT NULL COMMENT '大区名称',
+    `province_code`   VARCHAR(30) NULL DEFAULT NULL COMMENT '省份编码',
+    `province_name`   VARCHAR(30) NULL DEFAULT NULL COMMENT '省份名称',
+    `deleted`         BIGINT(20) UNSIGNED NOT NULL DEFAULT '0' COMMENT '软删除记录id',
+    `created_at`      DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at`      DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    `deleted_at`      DATETIME(3) NULL DEFAULT NULL COMMENT '删除时间',
+    PRIMARY KEY (`id`) USING BTREE,
+    UNIQUE INDEX `idx_area_code` (`area_code`, `deleted`) USING BTREE,
+    INDEX             `idx_area_deleted_at` (`deleted_at`) USING BTREE
+) COMMENT ='区域信息表' COLLATE = 'utf8_unicode_ci'
+                  ENGINE = InnoDB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `area`;
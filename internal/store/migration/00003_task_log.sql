@@ -0,0 +1,29 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `task_log`
+(
+    `id`         BIGINT(20) UNSIGNED NOT NULL COMMENT '主键id',
+    `created_at` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    `start_time` DATETIME(3) NOT NULL COMMENT '开始时间',
+    `end_time`   DATETIME(3) NOT NULL COMMENT '结束时间',
+    `task_name`  VARCHAR(255) NULL DEFAULT NULL COMMENT '任务名称',
+    `task_type`  VARCHAR(255) NULL DEFAULT NULL COMMENT '任务类型',
+    `progress`   BIGINT(20) NULL DEFAULT NULL COMMENT '任务的执行进度百分比值',
+    `res_id`     BIGINT(20) UNSIGNED NULL DEFAULT NULL COMMENT '任务操作资源ID',
+    `input`      JSON NULL DEFAULT NULL COMMENT '任务开始输入',
+    `result`     JSON NULL DEFAULT NULL COMMENT '任务结果输出',
+    `trace_id`   VARCHAR(255) NULL DEFAULT NULL COMMENT '追踪trace_id',
+    `status`     VARCHAR(16) NULL DEFAULT NULL COMMENT '任务状态',
+    `reason`     LONGTEXT NULL DEFAULT NULL COMMENT '失败原因',
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX        `idx_idx_task_type_created` (`created_at`) USING BTREE,
+    INDEX        `idx_idx_task_type_start_time` (`task_type`, `start_time`) USING BTREE,
+    INDEX        `idx_res_id` (`res_id`) USING BTREE,
+    INDEX        `idx_trace_id` (`trace_id`) USING BTREE
+) COMMENT ='任务执行日志记录表' COLLATE = 'utf8_unicode_ci'
+                   ENGINE = InnoDB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `task_log`;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `site`
+(
+    `id`         BIGINT(20) UNSIGNED NOT NULL COMMENT 'id',
+    `area_id`    BIGINT(20) UNSIGNED NOT NULL COMMENT '区域ID',
+    `remote_id`  BIGINT(20) UNSIGNED NOT NULL COMMENT '网关中的站点ID',
+    `site_name`  VARCHAR(255) NOT NULL COMMENT '站点名称',
+    `deleted`    BIGINT(20) UNSIGNED NOT NULL DEFAULT '0' COMMENT '软删除记录id',
+    `created_at` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    `deleted_at` DATETIME(3) NULL DEFAULT NULL COMMENT '删除时间',
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX        `idx_site_area_id` (`area_id`) USING BTREE,
+    INDEX        `idx_site_deleted_at` (`deleted_at`) USING BTREE
+) COMMENT ='站点信息表' COLLATE = 'utf8_unicode_ci'
+                  ENGINE = InnoDB;
+
+CREATE TABLE `site_net`
+(
+    `id`         BIGINT(20) UNSIGNED NOT NULL COMMENT 'id',
+    `site_id`    BIGINT(20) UNSIGNED NOT NULL COMMENT '站点ID',
+    `remote_id`  BIGINT(20) UNSIGNED NOT NULL COMMENT '网关中的站点网络类型ID',
+    `net_type`   VARCHAR(64) NOT NULL COMMENT '网络类型,如ChinaUnicom',
+    `deleted`    BIGINT(20) UNSIGNED NOT NULL DEFAULT '0' COMMENT '软删除记录id',
+    `created_at` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    `deleted_at` DATETIME(3) NULL DEFAULT NULL COMMENT '删除时间',
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX        `idx_site_net_site_id` (`site_id`) USING BTREE,
+    INDEX        `idx_site_net_deleted_at` (`deleted_at`) USING BTREE
+) COMMENT ='站点网络类型表' COLLATE = 'utf8_unicode_ci'
+                     ENGINE = InnoDB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `site_net`;
+DROP TABLE IF EXISTS `site`;
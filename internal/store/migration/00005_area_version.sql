@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE `area`
+    ADD COLUMN `version` BIGINT(20) NOT NULL DEFAULT '1' COMMENT '版本号' AFTER `province_name`;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `area`
+    DROP COLUMN `version`;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `audit_log`
+(
+    `id`            BIGINT(20) UNSIGNED NOT NULL COMMENT 'id',
+    `resource_type` VARCHAR(64) NOT NULL COMMENT '资源类型',
+    `resource_id`   VARCHAR(64) NOT NULL COMMENT '资源ID',
+    `action`        VARCHAR(16) NOT NULL COMMENT '操作类型',
+    `before`        JSON NULL DEFAULT NULL COMMENT '变更前的值',
+    `after`         JSON NULL DEFAULT NULL COMMENT '变更后的值',
+    `account_id`    VARCHAR(64) NOT NULL DEFAULT '' COMMENT '账户ID',
+    `user_id`       VARCHAR(64) NOT NULL DEFAULT '' COMMENT '用户ID',
+    `trace_id`      VARCHAR(64) NOT NULL DEFAULT '' COMMENT '追踪ID',
+    `created_at`    DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX           `idx_audit_resource` (`resource_type`, `resource_id`) USING BTREE,
+    INDEX           `idx_audit_account_id` (`account_id`) USING BTREE,
+    INDEX           `idx_audit_trace_id` (`trace_id`) USING BTREE,
+    INDEX           `idx_audit_created_at` (`created_at`) USING BTREE
+) COMMENT ='数据变更审计日志表' COLLATE = 'utf8_unicode_ci'
+                  ENGINE = InnoDB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `audit_log`;
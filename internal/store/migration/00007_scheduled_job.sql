@@ -0,0 +1,21 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `scheduled_job`
+(
+    `job_key`        VARCHAR(191) NOT NULL COMMENT '任务唯一标识',
+    `description`    VARCHAR(255) NOT NULL DEFAULT '' COMMENT '任务描述',
+    `factory`        VARCHAR(64)  NOT NULL DEFAULT '' COMMENT '任务工厂名称',
+    `data`           BLOB NULL DEFAULT NULL COMMENT '任务工厂的参数',
+    `trigger_spec`   VARCHAR(255) NOT NULL DEFAULT '' COMMENT '触发器描述',
+    `misfire`        VARCHAR(16)  NOT NULL DEFAULT '' COMMENT '错过触发的处理策略',
+    `last_fire_time` BIGINT(20) NOT NULL DEFAULT 0 COMMENT '上次触发时间(纳秒)',
+    `next_fire_time` BIGINT(20) NOT NULL DEFAULT 0 COMMENT '下次触发时间(纳秒)',
+    `created_at`     DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at`     DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    PRIMARY KEY (`job_key`) USING BTREE
+) COMMENT ='定时任务持久化表' COLLATE = 'utf8_unicode_ci'
+                  ENGINE = InnoDB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `scheduled_job`;
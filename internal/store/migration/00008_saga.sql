@@ -0,0 +1,37 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `saga`
+(
+    `id`          VARCHAR(64)  NOT NULL COMMENT '执行ID',
+    `name`        VARCHAR(128) NOT NULL DEFAULT '' COMMENT 'saga名称',
+    `state`       VARCHAR(16)  NOT NULL DEFAULT '' COMMENT '执行状态',
+    `input`       BLOB NULL DEFAULT NULL COMMENT '输入参数',
+    `step`        INT(11) NOT NULL DEFAULT 0 COMMENT '下一个执行的步骤',
+    `reason`      TEXT NULL DEFAULT NULL COMMENT '失败原因',
+    `owner`       VARCHAR(64)  NOT NULL DEFAULT '' COMMENT '执行实例',
+    `lease_until` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '租约过期时间',
+    `created_at`  DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at`  DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX `idx_state_lease` (`state`, `lease_until`) USING BTREE
+) COMMENT ='saga执行状态表' COLLATE = 'utf8_unicode_ci'
+                  ENGINE = InnoDB;
+
+CREATE TABLE `saga_step`
+(
+    `saga_id`    VARCHAR(64)  NOT NULL COMMENT '执行ID',
+    `step`       INT(11) NOT NULL COMMENT '步骤序号',
+    `name`       VARCHAR(128) NOT NULL DEFAULT '' COMMENT '步骤名称',
+    `state`      VARCHAR(16)  NOT NULL DEFAULT '' COMMENT '执行状态',
+    `input`      BLOB NULL DEFAULT NULL COMMENT '输入参数',
+    `reason`     TEXT NULL DEFAULT NULL COMMENT '失败原因',
+    `created_at` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    PRIMARY KEY (`saga_id`, `step`) USING BTREE
+) COMMENT ='saga步骤执行状态表' COLLATE = 'utf8_unicode_ci'
+                  ENGINE = InnoDB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `saga_step`;
+DROP TABLE IF EXISTS `saga`;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `async_outbox`
+(
+    `id`          BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '消息ID',
+    `uuid`        VARCHAR(64)  NOT NULL DEFAULT '' COMMENT '消息uuid',
+    `exchange`    VARCHAR(255) NOT NULL DEFAULT '' COMMENT '交换机',
+    `routing_key` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '路由',
+    `payload`     MEDIUMBLOB NULL DEFAULT NULL COMMENT '任务参数',
+    `state`       VARCHAR(16)  NOT NULL DEFAULT '' COMMENT '发送状态',
+    `attempts`    INT(11) NOT NULL DEFAULT 0 COMMENT '发送失败的次数',
+    `last_error`  TEXT NULL DEFAULT NULL COMMENT '最近一次发送失败的原因',
+    `sent_at`     DATETIME(3) NULL DEFAULT NULL COMMENT '发送时间',
+    `owner`       VARCHAR(64)  NOT NULL DEFAULT '' COMMENT '发布实例',
+    `lease_until` DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '租约过期时间',
+    `created_at`  DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at`  DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX `idx_state_id` (`state`, `id`) USING BTREE,
+    INDEX `idx_sent_at` (`sent_at`) USING BTREE
+) COMMENT ='异步消息发件箱表' COLLATE = 'utf8_unicode_ci'
+                  ENGINE = InnoDB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `async_outbox`;
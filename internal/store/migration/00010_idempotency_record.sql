@@ -0,0 +1,21 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `idempotency_record`
+(
+    `idempotency_key` VARCHAR(191) NOT NULL COMMENT '幂等key',
+    `state`           VARCHAR(16)  NOT NULL DEFAULT '' COMMENT '处理状态',
+    `status`          INT(11) NOT NULL DEFAULT 0 COMMENT '响应状态码',
+    `content_type`    VARCHAR(255) NOT NULL DEFAULT '' COMMENT '响应类型',
+    `body`            MEDIUMBLOB NULL DEFAULT NULL COMMENT '响应内容',
+    `fingerprint`     VARCHAR(64)  NOT NULL DEFAULT '' COMMENT '请求摘要',
+    `expired_at`      DATETIME(3) NOT NULL COMMENT '过期时间',
+    `created_at`      DATETIME(3) NOT NULL DEFAULT current_timestamp (3) COMMENT '创建时间',
+    `updated_at`      DATETIME(3) NOT NULL DEFAULT current_timestamp (3) ON UPDATE current_timestamp (3) COMMENT '更新时间',
+    PRIMARY KEY (`idempotency_key`) USING BTREE,
+    INDEX `idx_expired_at` (`expired_at`) USING BTREE
+) COMMENT ='幂等记录表' COLLATE = 'utf8_unicode_ci'
+                  ENGINE = InnoDB;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `idempotency_record`;
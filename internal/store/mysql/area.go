@@ -4,13 +4,18 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 
+	internalcode "template/internal/code"
 	"template/internal/model"
 	"template/internal/request"
 	"template/pkg/code"
 	"template/pkg/storage"
 )
 
+// sitesPreload 预加载区域下的站点及站点网络
+const sitesPreload = "Sites.SiteNets"
+
 func newArea(db *storage.DB) *area {
 	return &area{
 		DB: db,
@@ -21,6 +26,64 @@ type area struct {
 	*storage.DB
 }
 
+func (a area) Create(ctx context.Context, obj *model.Area) error {
+	if err := a.WithContext(ctx).Create(obj).Error; err != nil {
+		if storage.IsDuplicate(err) {
+			return errors.WithStack(internalcode.ErrAreaCodeConflict.WithResult(obj.AreaCode))
+		}
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return nil
+}
+
+func (a area) Get(ctx context.Context, id string) (*model.Area, error) {
+	var obj model.Area
+	if err := a.WithContext(ctx).Model(&model.Area{}).Where("id = ?", id).First(&obj).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(internalcode.ErrAreaNotFound.WithResult(id))
+		}
+		return nil, errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return &obj, nil
+}
+
+func (a area) GetWithSites(ctx context.Context, id string) (*model.Area, error) {
+	var obj model.Area
+	if err := a.WithContext(ctx).Model(&model.Area{}).Preload(sitesPreload).
+		Where("id = ?", id).First(&obj).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(internalcode.ErrAreaNotFound.WithResult(id))
+		}
+		return nil, errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return &obj, nil
+}
+
+func (a area) Update(ctx context.Context, id string, values map[string]interface{}) error {
+	query := a.WithContext(ctx).Model(&model.Area{}).Where("id = ?", id).Updates(values)
+	if err := query.Error; err != nil {
+		if storage.IsDuplicate(err) {
+			return errors.WithStack(internalcode.ErrAreaCodeConflict.WithResult(values["area_code"]))
+		}
+		if errors.Is(err, code.ErrVersionConflict) {
+			return err
+		}
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return nil
+}
+
+func (a area) Delete(ctx context.Context, id string) error {
+	query := a.WithContext(ctx).Where("id = ?", id).Delete(&model.Area{})
+	if err := query.Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	if query.RowsAffected == 0 {
+		return errors.WithStack(internalcode.ErrAreaNotFound.WithResult(id))
+	}
+	return nil
+}
+
 func (a area) List(ctx context.Context, req *request.QueryAreaListReq) ([]*model.Area, error) {
 	var objs []*model.Area
 	query := a.WithContext(ctx).Model(&model.Area{})
@@ -42,7 +105,14 @@ func (a area) List(ctx context.Context, req *request.QueryAreaListReq) ([]*model
 	if req.ProvinceName != "" {
 		query = query.Where("province_name = ?", req.ProvinceName)
 	}
-	if err := req.Build(ctx, query).Find(&objs).Error; err != nil {
+	query = req.Build(ctx, query)
+	if req.Expand == request.ExpandSites {
+		query = query.Preload(sitesPreload)
+	}
+	if err := query.Find(&objs).Error; err != nil {
+		if errors.Is(err, code.ErrInvalidParam) {
+			return nil, err
+		}
 		return nil, errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
 	}
 	return objs, nil
@@ -6,15 +6,22 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 
+	"template/internal/ctxw"
 	"template/internal/store"
+	"template/pkg/async"
+	asyncstore "template/pkg/async/store/mysql"
+	"template/pkg/audit"
 	"template/pkg/logger/gormx"
+	"template/pkg/selector"
 	"template/pkg/storage"
 )
 
 // NewMysqlClient create mysql factory with context.Context
 func NewMysqlClient(ctx context.Context) (*dataStore, error) {
 	c, err := storage.New(ctx,
+		storage.WithDialect(dialect()),
 		storage.WithUser(viper.GetString("mysql.user")),
 		storage.WithPassword(viper.GetString("mysql.password")),
 		storage.WithIP(viper.GetString("mysql.ip")),
@@ -28,13 +35,65 @@ func NewMysqlClient(ctx context.Context) (*dataStore, error) {
 		storage.WithPlugins(
 			// 注入忽略select语句的日志
 			storage.NewIgnoreSelectLogger(viper.GetString("mode") != gin.ReleaseMode),
-		))
+			// 审计日志
+			audit.NewPlugin(
+				audit.WithAccountID(ctxw.GetAccountID),
+				audit.WithUserID(ctxw.GetUserID),
+				audit.WithTraceID(ctxw.GetTraceID),
+			),
+		),
+		storage.WithReplicas(replicas()...),
+		storage.WithReplicaSelector(replicaSelector()),
+		storage.WithHealthCheckInterval(viper.GetDuration("mysql.health_check_interval")),
+	)
 	if err != nil {
 		return nil, err
 	}
 	return &dataStore{DB: c}, nil
 }
 
+// dialect 数据库类型,未配置时默认为MySQL
+func dialect() storage.Dialect {
+	if d := viper.GetString("mysql.dialect"); d != "" {
+		return storage.Dialect(d)
+	}
+	return storage.DialectMySQL
+}
+
+// replicas 只读副本配置
+func replicas() []storage.Replica {
+	var list []struct {
+		IP     string   `mapstructure:"ip"`
+		Port   string   `mapstructure:"port"`
+		Weight *float64 `mapstructure:"weight"`
+	}
+	if err := viper.UnmarshalKey("mysql.replicas", &list); err != nil {
+		return nil
+	}
+	result := make([]storage.Replica, 0, len(list))
+	for _, v := range list {
+		// 未配置权重时默认为1,避免加权策略下全部副本被忽略
+		weight := 1.0
+		if v.Weight != nil {
+			weight = *v.Weight
+		}
+		result = append(result, storage.Replica{IP: v.IP, Port: v.Port, Weight: weight})
+	}
+	return result
+}
+
+// replicaSelector 副本选择策略: random, round_robin, weighted
+func replicaSelector() func([]*selector.Node) selector.Selector {
+	switch viper.GetString("mysql.replica_policy") {
+	case "round_robin":
+		return selector.NewRoundRobin
+	case "weighted":
+		return selector.NewWeightedRoundRobin
+	default:
+		return selector.NewRandom
+	}
+}
+
 type dataStore struct {
 	DB *storage.DB
 }
@@ -45,14 +104,38 @@ func (d *dataStore) Begin() store.Store {
 	}}
 }
 
-func (d *dataStore) Commit() {
-	d.DB.Commit()
+func (d *dataStore) Commit() error {
+	return d.DB.Commit().Error
 }
 
-func (d *dataStore) Rollback() {
-	d.DB.Rollback()
+func (d *dataStore) Rollback() error {
+	return d.DB.Rollback().Error
+}
+
+func (d *dataStore) Transaction(ctx context.Context, fn func(ctx context.Context, s store.Store) error) error {
+	db := d.DB
+	// 上下文中已存在事务时,在该事务中以savepoint的方式嵌套执行
+	if tx, ok := store.FromContext(ctx, d).(*dataStore); ok {
+		db = tx.DB
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		s := &dataStore{DB: &storage.DB{DB: tx}}
+		return fn(store.WithContext(ctx, s), s)
+	})
 }
 
 func (d *dataStore) Area() store.AreaStore {
 	return newArea(d.DB)
 }
+
+func (d *dataStore) Site() store.SiteStore {
+	return newSite(d.DB)
+}
+
+func (d *dataStore) SiteNet() store.SiteNetStore {
+	return newSiteNet(d.DB)
+}
+
+func (d *dataStore) Outbox() async.OutboxStore {
+	return asyncstore.NewOutboxStore(d.DB.DB)
+}
@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	internalcode "template/internal/code"
+	"template/internal/model"
+	"template/pkg/code"
+	"template/pkg/storage"
+)
+
+func newSite(db *storage.DB) *site {
+	return &site{
+		DB: db,
+	}
+}
+
+type site struct {
+	*storage.DB
+}
+
+// Create 创建站点,所属区域不存在或已删除时返回 ErrAreaNotFound
+func (s site) Create(ctx context.Context, obj *model.Site) error {
+	if err := exists(ctx, s.DB, &model.Area{}, obj.AreaID, internalcode.ErrAreaNotFound); err != nil {
+		return err
+	}
+	if err := s.WithContext(ctx).Create(obj).Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return nil
+}
+
+func (s site) Update(ctx context.Context, id string, values map[string]interface{}) error {
+	if err := s.WithContext(ctx).Model(&model.Site{}).Where("id = ?", id).
+		Updates(values).Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return nil
+}
+
+// Delete 删除站点及其站点网络
+func (s site) Delete(ctx context.Context, id string) error {
+	if err := s.WithContext(ctx).Where("site_id = ?", id).Delete(&model.SiteNet{}).Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	if err := s.WithContext(ctx).Where("id = ?", id).Delete(&model.Site{}).Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return nil
+}
+
+func newSiteNet(db *storage.DB) *siteNet {
+	return &siteNet{
+		DB: db,
+	}
+}
+
+type siteNet struct {
+	*storage.DB
+}
+
+// Create 创建站点网络,所属站点不存在或已删除时返回 ErrSiteNotFound
+func (s siteNet) Create(ctx context.Context, obj *model.SiteNet) error {
+	if err := exists(ctx, s.DB, &model.Site{}, obj.SiteID, internalcode.ErrSiteNotFound); err != nil {
+		return err
+	}
+	if err := s.WithContext(ctx).Create(obj).Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return nil
+}
+
+func (s siteNet) Update(ctx context.Context, id string, values map[string]interface{}) error {
+	if err := s.WithContext(ctx).Model(&model.SiteNet{}).Where("id = ?", id).
+		Updates(values).Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return nil
+}
+
+func (s siteNet) Delete(ctx context.Context, id string) error {
+	if err := s.WithContext(ctx).Where("id = ?", id).Delete(&model.SiteNet{}).Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	return nil
+}
+
+// exists 检查关联的记录是否存在,表之间不使用外键约束,由存储层保证关联关系
+func exists(ctx context.Context, db *storage.DB, model interface{}, id uint64, notFound code.ErrorCode) error {
+	var count int64
+	if err := db.WithContext(ctx).Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return errors.WithStack(code.ErrInternalServerError.WithResult(err.Error()))
+	}
+	if count == 0 {
+		return errors.WithStack(notFound.WithResult(strconv.FormatUint(id, 10)))
+	}
+	return nil
+}
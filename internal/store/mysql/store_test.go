@@ -0,0 +1,124 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	internalcode "template/internal/code"
+	"template/internal/model"
+	"template/internal/request"
+	"template/internal/store"
+	"template/pkg/code"
+	"template/pkg/storage"
+)
+
+// newTestStore 使用内存中的SQLite,无需MySQL即可测试存储层
+func newTestStore(t *testing.T) *dataStore {
+	db, err := storage.New(context.Background(),
+		storage.WithDialect(storage.DialectSQLite),
+		storage.WithDatabase("file:"+t.Name()+"?mode=memory&cache=shared"),
+		storage.WithMaxOpenConn(1),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	require.NoError(t, db.AutoMigrate(&model.Area{}, &model.Site{}, &model.SiteNet{}))
+	return &dataStore{DB: db}
+}
+
+func TestArea(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	area := &model.Area{AreaName: "华东", AreaCode: "cn-east"}
+	require.NoError(t, s.Area().Create(ctx, area))
+	err := s.Area().Create(ctx, &model.Area{AreaName: "华东", AreaCode: "cn-east"})
+	assert.ErrorIs(t, err, internalcode.ErrAreaCodeConflict)
+
+	got, err := s.Area().Get(ctx, area.PK())
+	require.NoError(t, err)
+	assert.Equal(t, storage.Version(1), got.Version)
+
+	require.NoError(t, s.Area().Update(ctx, area.PK(), map[string]interface{}{
+		"area_desc": "desc",
+		"version":   got.Version,
+	}))
+	// 版本号不匹配时更新失败
+	err = s.Area().Update(ctx, area.PK(), map[string]interface{}{
+		"area_desc": "stale",
+		"version":   got.Version,
+	})
+	assert.Error(t, err)
+
+	list, err := s.Area().List(ctx, &request.QueryAreaListReq{AreaCode: "cn-east"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "desc", list[0].AreaDesc)
+
+	require.NoError(t, s.Area().Delete(ctx, area.PK()))
+	_, err = s.Area().Get(ctx, area.PK())
+	assert.ErrorIs(t, err, internalcode.ErrAreaNotFound)
+	// 软删除后允许重新创建相同编码的区域
+	assert.NoError(t, s.Area().Create(ctx, &model.Area{AreaName: "华东", AreaCode: "cn-east"}))
+}
+
+func TestSite(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	area := &model.Area{AreaName: "华东", AreaCode: "cn-east"}
+	require.NoError(t, s.Area().Create(ctx, area))
+	err := s.Site().Create(ctx, &model.Site{AreaID: area.ID + 1, SiteName: "orphan"})
+	assert.ErrorIs(t, err, internalcode.ErrAreaNotFound)
+
+	err = s.Transaction(ctx, func(ctx context.Context, s store.Store) error {
+		site := &model.Site{AreaID: area.ID, RemoteID: 1, SiteName: "上海"}
+		if err := s.Site().Create(ctx, site); err != nil {
+			return err
+		}
+		return s.SiteNet().Create(ctx, &model.SiteNet{SiteID: site.ID, RemoteID: 1, NetType: "ChinaUnicom"})
+	})
+	require.NoError(t, err)
+
+	got, err := s.Area().GetWithSites(ctx, area.PK())
+	require.NoError(t, err)
+	require.Len(t, got.Sites, 1)
+	require.Len(t, got.Sites[0].SiteNets, 1)
+
+	site := got.Sites[0]
+	require.NoError(t, s.Site().Delete(ctx, site.PK()))
+	err = s.SiteNet().Create(ctx, &model.SiteNet{SiteID: site.ID, NetType: "ChinaMobile"})
+	assert.ErrorIs(t, err, internalcode.ErrSiteNotFound)
+	got, err = s.Area().GetWithSites(ctx, area.PK())
+	require.NoError(t, err)
+	assert.Empty(t, got.Sites)
+}
+
+func TestAreaList(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	for _, c := range []string{"cn-east", "cn-west"} {
+		require.NoError(t, s.Area().Create(ctx, &model.Area{AreaName: c, AreaCode: c, Status: "on"}))
+	}
+
+	req := &request.QueryAreaListReq{}
+	req.Filter, req.SortField = "area_code in (cn-east,cn-north)", "area_code asc"
+	list, err := s.Area().List(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "cn-east", list[0].AreaCode)
+	assert.Equal(t, int64(1), req.Total)
+
+	// 未知的列返回参数错误
+	for _, req = range []*request.QueryAreaListReq{
+		{ListQuery: model.ListQuery{Filter: "unknown eq 1"}},
+		{ListQuery: model.ListQuery{Sort: model.Sort{SortField: "unknown desc"}}},
+	} {
+		_, err = s.Area().List(ctx, req)
+		assert.ErrorIs(t, err, code.ErrInvalidParam)
+	}
+}
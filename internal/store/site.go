@@ -0,0 +1,19 @@
+package store
+
+import (
+	"context"
+
+	"template/internal/model"
+)
+
+type SiteStore interface {
+	Create(ctx context.Context, site *model.Site) error
+	Update(ctx context.Context, id string, values map[string]interface{}) error
+	Delete(ctx context.Context, id string) error
+}
+
+type SiteNetStore interface {
+	Create(ctx context.Context, siteNet *model.SiteNet) error
+	Update(ctx context.Context, id string, values map[string]interface{}) error
+	Delete(ctx context.Context, id string) error
+}
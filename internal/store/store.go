@@ -1,9 +1,41 @@
 package store
 
+import (
+	"context"
+
+	"template/pkg/async"
+)
+
 // Store defines the storage interface.
 type Store interface {
 	Begin() Store
-	Commit()
-	Rollback()
+	Commit() error
+	Rollback() error
+	// Transaction runs fn in a transaction which is committed when fn returns nil
+	// and rolled back when fn returns an error or panics.
+	// The ctx passed to fn carries the transactional Store, so calling Transaction
+	// again with it opens a savepoint inside the outer transaction.
+	Transaction(ctx context.Context, fn func(ctx context.Context, s Store) error) error
 	Area() AreaStore
+	Site() SiteStore
+	SiteNet() SiteNetStore
+	// Outbox 异步消息发件箱,在 Transaction 中写入时与业务数据一同提交
+	Outbox() async.OutboxStore
+}
+
+type txStoreKey struct{}
+
+// WithContext Add the transactional Store to context.Context.
+func WithContext(ctx context.Context, s Store) context.Context {
+	return context.WithValue(ctx, txStoreKey{}, s)
+}
+
+// FromContext Get the transactional Store from context.Context,
+// def is returned if there is no active transaction.
+func FromContext(ctx context.Context, def Store) Store {
+	s, ok := ctx.Value(txStoreKey{}).(Store)
+	if !ok {
+		return def
+	}
+	return s
 }
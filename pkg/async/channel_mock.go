@@ -86,3 +86,110 @@ func (mr *MockChannelMockRecorder) Publish(exchange, key, mandatory, immediate i
 	varargs := append([]interface{}{exchange, key, mandatory, immediate}, msg...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockChannel)(nil).Publish), varargs...)
 }
+
+// MockQueueChannel is a mock of QueueChannel interface.
+type MockQueueChannel struct {
+	ctrl     *gomock.Controller
+	recorder *MockQueueChannelMockRecorder
+}
+
+// MockQueueChannelMockRecorder is the mock recorder for MockQueueChannel.
+type MockQueueChannelMockRecorder struct {
+	mock *MockQueueChannel
+}
+
+// NewMockQueueChannel creates a new mock instance.
+func NewMockQueueChannel(ctrl *gomock.Controller) *MockQueueChannel {
+	mock := &MockQueueChannel{ctrl: ctrl}
+	mock.recorder = &MockQueueChannelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQueueChannel) EXPECT() *MockQueueChannelMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockQueueChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", queue, consumer, autoAck, exclusive, noLocal, noWail, args)
+	ret0, _ := ret[0].(<-chan amqp.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockQueueChannelMockRecorder) Consume(queue, consumer, autoAck, exclusive, noLocal, noWail, args interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockQueueChannel)(nil).Consume), queue, consumer, autoAck, exclusive, noLocal, noWail, args)
+}
+
+// DeclareAndBind mocks base method.
+func (m *MockQueueChannel) DeclareAndBind(exchange, kind, queue, key string, args ...map[string]interface{}) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{exchange, kind, queue, key}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeclareAndBind", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeclareAndBind indicates an expected call of DeclareAndBind.
+func (mr *MockQueueChannelMockRecorder) DeclareAndBind(exchange, kind, queue, key interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{exchange, kind, queue, key}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeclareAndBind", reflect.TypeOf((*MockQueueChannel)(nil).DeclareAndBind), varargs...)
+}
+
+// Get mocks base method.
+func (m *MockQueueChannel) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", queue, autoAck)
+	ret0, _ := ret[0].(amqp.Delivery)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockQueueChannelMockRecorder) Get(queue, autoAck interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockQueueChannel)(nil).Get), queue, autoAck)
+}
+
+// Publish mocks base method.
+func (m *MockQueueChannel) Publish(exchange, key string, mandatory, immediate bool, msg ...amqp.Publishing) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{exchange, key, mandatory, immediate}
+	for _, a := range msg {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Publish", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockQueueChannelMockRecorder) Publish(exchange, key, mandatory, immediate interface{}, msg ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{exchange, key, mandatory, immediate}, msg...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockQueueChannel)(nil).Publish), varargs...)
+}
+
+// Purge mocks base method.
+func (m *MockQueueChannel) Purge(queue string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", queue)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockQueueChannelMockRecorder) Purge(queue interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockQueueChannel)(nil).Purge), queue)
+}
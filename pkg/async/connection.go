@@ -0,0 +1,408 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/streadway/amqp"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// connect 连接不可用时重新建立连接
+func (r *rabbitmqChannel) connect() error {
+	r.mu.RLock()
+	connected := !r.closed && r.conn != nil && !r.conn.IsClosed()
+	r.mu.RUnlock()
+	if connected {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dial()
+}
+
+// dial 建立连接及通道池并重新声明记录的拓扑,调用方需持有写锁
+func (r *rabbitmqChannel) dial() (err error) {
+	if r.closed {
+		return ErrChannelClosed
+	}
+	//检测链接是否正常
+	if r.conn != nil && !r.conn.IsClosed() {
+		return nil
+	}
+	var conn *amqp.Connection
+	if r.config == nil {
+		conn, err = amqp.Dial(r.uri)
+	} else {
+		conn, err = amqp.DialConfig(r.uri, *r.config)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = conn.Close()
+		}
+	}()
+	pool := make(chan *pooledChannel, r.poolSize)
+	for i := 0; i < r.poolSize; i++ {
+		var ch *pooledChannel
+		if ch, err = r.openChannel(conn); err != nil {
+			return err
+		}
+		pool <- ch
+	}
+	// 消费者由各自的转发协程在投递通道关闭后重新注册
+	r.declareTopology(conn)
+	if r.conn != nil {
+		reconnectsTotal.WithLabelValues(r.name).Inc()
+	}
+	r.conn, r.pool = conn, pool
+	connectionState.WithLabelValues(r.name).Set(1)
+	go r.watch(conn.NotifyClose(make(chan *amqp.Error, 1)))
+	return nil
+}
+
+// watch 连接异常关闭后按退避策略重连,直到重连成功或调用Close
+func (r *rabbitmqChannel) watch(closes <-chan *amqp.Error) {
+	select {
+	case <-r.done:
+		return
+	case e := <-closes:
+		// 调用Close正常关闭
+		if e == nil {
+			return
+		}
+	}
+	connectionState.WithLabelValues(r.name).Set(0)
+	_ = backoff.Retry(func() error {
+		return permanent(r.connect())
+	}, r.newReconnectBackOff(context.Background()))
+}
+
+func (r *rabbitmqChannel) newReconnectBackOff(ctx context.Context) backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = reconnectInterval
+	b.MaxInterval = maxReconnectInterval
+	// 一直重试,直到成功或调用Close
+	b.MaxElapsedTime = 0
+	b.Reset()
+	return backoff.WithContext(b, ctx)
+}
+
+// permanent 通道已关闭时不再重试
+func permanent(err error) error {
+	if errors.Is(err, ErrChannelClosed) {
+		return backoff.Permanent(err)
+	}
+	return err
+}
+
+// withChannel 从通道池中取出一个通道执行fn,通道异常关闭时重新打开后放回
+func (r *rabbitmqChannel) withChannel(fn func(ch *pooledChannel) error) error {
+	if err := r.connect(); err != nil {
+		return err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return ErrChannelClosed
+	}
+	ch := <-r.pool
+	defer func() {
+		r.pool <- r.renew(ch)
+	}()
+	return fn(ch)
+}
+
+type pooledChannel struct {
+	*amqp.Channel
+	closes   chan *amqp.Error
+	confirms chan amqp.Confirmation
+	// 当前通道已发布的消息数,即最后一条消息的确认序号
+	published uint64
+}
+
+func (r *rabbitmqChannel) openChannel(conn *amqp.Connection) (*pooledChannel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("can't open channel,%w", err)
+	}
+	ch := &pooledChannel{
+		Channel: channel,
+		closes:  channel.NotifyClose(make(chan *amqp.Error, 1)),
+	}
+	if r.confirm {
+		if err = channel.Confirm(false); err != nil {
+			_ = channel.Close()
+			return nil, fmt.Errorf("can't enable confirm mode,%w", err)
+		}
+		ch.confirms = channel.NotifyPublish(make(chan amqp.Confirmation, confirmBuffer))
+	}
+	return ch, nil
+}
+
+// renew 通道因异常关闭时重新打开,打开失败时仍放回原通道,由下次使用时重连
+func (r *rabbitmqChannel) renew(ch *pooledChannel) *pooledChannel {
+	select {
+	case <-ch.closes:
+	default:
+		return ch
+	}
+	if renewed, err := r.openChannel(r.conn); err == nil {
+		return renewed
+	}
+	return ch
+}
+
+func (ch *pooledChannel) txPublish(exchange, key string, mandatory, immediate bool, msg ...amqp.Publishing) (err error) {
+	if err = ch.Tx(); err != nil {
+		err = fmt.Errorf("can't start transaction,%w", err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			err = multierr.Append(err, ch.TxRollback())
+		}
+	}()
+	for _, m := range msg {
+		if err = ch.Publish(exchange, key, mandatory, immediate, m); err != nil {
+			return
+		}
+	}
+	err = ch.TxCommit()
+	return
+}
+
+// confirmPublish 发布消息并等待broker确认全部消息
+func (ch *pooledChannel) confirmPublish(timeout time.Duration, exchange, key string, mandatory, immediate bool,
+	msg ...amqp.Publishing) error {
+	// 之前超时的消息的确认序号小于first,等待时忽略
+	first := ch.published + 1
+	for i := range msg {
+		if err := ch.Publish(exchange, key, mandatory, immediate, msg[i]); err != nil {
+			return err
+		}
+		ch.published++
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	for pending := len(msg); pending > 0; {
+		select {
+		case c, ok := <-ch.confirms:
+			if !ok {
+				return fmt.Errorf("channel closed before confirm,%w", amqp.ErrClosed)
+			}
+			if c.DeliveryTag < first {
+				continue
+			}
+			pending--
+			if !c.Ack {
+				err = multierr.Append(err, fmt.Errorf("delivery tag %d,%w", c.DeliveryTag, ErrPublishNack))
+			}
+		case <-timer.C:
+			return multierr.Append(err, ErrConfirmTimeout)
+		}
+	}
+	return err
+}
+
+// subscription 记录消费者的注册参数,底层通道关闭后重新注册
+type subscription struct {
+	queue                               string
+	tag                                 string
+	autoAck, exclusive, noLocal, noWait bool
+	args                                amqp.Table
+	// 当前消费使用的通道
+	channel    *amqp.Channel
+	deliveries chan amqp.Delivery
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+func newSubscription(queue, tag string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) *subscription {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &subscription{
+		queue:      queue,
+		tag:        tag,
+		autoAck:    autoAck,
+		exclusive:  exclusive,
+		noLocal:    noLocal,
+		noWait:     noWait,
+		args:       args,
+		deliveries: make(chan amqp.Delivery),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// subscribe 打开新的通道注册消费者,调用方需持有写锁
+func (r *rabbitmqChannel) subscribe(s *subscription) (<-chan amqp.Delivery, error) {
+	if r.closed {
+		return nil, ErrChannelClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return nil, backoff.Permanent(err)
+	}
+	if r.conn == nil || r.conn.IsClosed() {
+		return nil, amqp.ErrClosed
+	}
+	channel, err := r.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("can't open channel,%w", err)
+	}
+	if r.qos != nil {
+		if err = channel.Qos(r.qos.PrefetchCount, r.qos.PrefetchSize, r.qos.Global); err != nil {
+			_ = channel.Close()
+			return nil, fmt.Errorf("set qos failed,%w", err)
+		}
+	}
+	deliveries, err := channel.Consume(
+		s.queue,
+		// 用来区分多个消费者
+		s.tag,
+		// 是否自动应答(自动应答确认消息，这里设置为否，在下面手动应答确认)
+		s.autoAck,
+		// 是否具有排他性
+		s.exclusive,
+		// 如果设置为true，表示不能将同一个connection中发送的消息
+		// 传递给同一个connection的消费者
+		s.noLocal,
+		// 是否为阻塞
+		s.noWait,
+		s.args,
+	)
+	if err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	// 关闭之前的通道,其中未确认的消息重新入队
+	if s.channel != nil {
+		_ = s.channel.Close()
+	}
+	s.channel = channel
+	return deliveries, nil
+}
+
+// forward 转发投递的消息,底层通道或连接关闭后重新注册消费者,取消或调用Close后结束
+func (r *rabbitmqChannel) forward(s *subscription, deliveries <-chan amqp.Delivery) {
+	defer close(s.deliveries)
+	for {
+		for d := range deliveries {
+			select {
+			case s.deliveries <- d:
+			case <-s.ctx.Done():
+				requeue(s.autoAck, d, deliveries)
+				return
+			}
+		}
+		resubscribe := func() (err error) {
+			if err = s.ctx.Err(); err != nil {
+				return backoff.Permanent(err)
+			}
+			if err = r.connect(); err != nil {
+				return permanent(err)
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			deliveries, err = r.subscribe(s)
+			return permanent(err)
+		}
+		if err := backoff.Retry(resubscribe, r.newReconnectBackOff(s.ctx)); err != nil {
+			return
+		}
+	}
+}
+
+// requeue 取消后未转发的消息重新入队
+func requeue(autoAck bool, d amqp.Delivery, deliveries <-chan amqp.Delivery) {
+	if autoAck {
+		return
+	}
+	_ = d.Nack(false, true)
+	for d := range deliveries {
+		_ = d.Nack(false, true)
+	}
+}
+
+// binding 声明的交换机、队列及绑定
+type binding struct {
+	exchange, kind, queue, key     string
+	exchangeArg, queueArg, bindArg map[string]interface{}
+}
+
+// declare 使用临时的通道声明,声明失败导致的通道关闭不影响发布及消费
+func (b *binding) declare(conn *amqp.Connection) (err error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("can't open channel,%w", err)
+	}
+	defer func() {
+		if cerr := channel.Close(); cerr != nil && !errors.Is(cerr, amqp.ErrClosed) {
+			err = multierr.Append(err, cerr)
+		}
+	}()
+	if err = channel.ExchangeDeclare(
+		b.exchange,
+		b.kind,
+		true,  // duration (note: is durable)
+		false, // auto-delete
+		false, // internal
+		false, // nowait
+		b.exchangeArg); err != nil {
+		return err
+	}
+	if _, err = channel.QueueDeclare(b.queue,
+		// 控制队列是否为持久的，当mq重启的时候不会丢失队列
+		true,
+		// 是否为自动删除
+		false,
+		// 是否具有排他性
+		false,
+		// 是否阻塞
+		false,
+		// 额外属性
+		b.queueArg,
+	); err != nil {
+		return err
+	}
+	return channel.QueueBind(b.queue, b.key, b.exchange, false, b.bindArg)
+}
+
+// record 记录声明成功的绑定,相同的绑定保留最后一次的参数。
+// 设置了 x-expires 的队列(例如延迟队列)由使用方按需重新声明,不记录,避免重连时重建已过期的队列
+func (r *rabbitmqChannel) record(b binding) {
+	if _, ok := b.queueArg["x-expires"]; ok {
+		return
+	}
+	r.topologyMu.Lock()
+	defer r.topologyMu.Unlock()
+	if r.topologyIndex == nil {
+		r.topologyIndex = make(map[string]int)
+	}
+	id := b.exchange + "\x00" + b.queue + "\x00" + b.key
+	if i, ok := r.topologyIndex[id]; ok {
+		r.topology[i] = b
+		return
+	}
+	r.topologyIndex[id] = len(r.topology)
+	r.topology = append(r.topology, b)
+}
+
+// declareTopology 按声明的顺序重新声明记录的绑定,声明失败时记录日志并跳过,不影响重连
+func (r *rabbitmqChannel) declareTopology(conn *amqp.Connection) {
+	r.topologyMu.Lock()
+	defer r.topologyMu.Unlock()
+	for i := range r.topology {
+		b := &r.topology[i]
+		if err := b.declare(conn); err != nil {
+			r.logger.Warn("redeclare topology failed", zap.String("name", r.name), zap.String("exchange", b.exchange),
+				zap.String("queue", b.queue), zap.String("key", b.key), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package async
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+func newTestRabbitmqChannel() *rabbitmqChannel {
+	return &rabbitmqChannel{
+		clientOption:  clientOption{poolSize: 1, name: defaultName, logger: zap.NewNop()},
+		subscriptions: make(map[string]*subscription),
+		done:          make(chan struct{}),
+	}
+}
+
+func waitClosed(t *testing.T, deliveries <-chan amqp.Delivery) {
+	select {
+	case _, ok := <-deliveries:
+		if ok {
+			t.Fatal("unexpected delivery")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deliveries not closed")
+	}
+}
+
+func TestForward_cancel(t *testing.T) {
+	r := newTestRabbitmqChannel()
+	s := newSubscription("test", "ctag.test", false, false, false, false, nil)
+	first, second := &recordAck{}, &recordAck{}
+	deliveries := make(chan amqp.Delivery, 2)
+	deliveries <- amqp.Delivery{Acknowledger: first}
+	deliveries <- amqp.Delivery{Acknowledger: second}
+	go r.forward(s, deliveries)
+
+	if _, ok := <-s.deliveries; !ok {
+		t.Fatal("expected first delivery")
+	}
+	s.cancel()
+	// 取消后broker关闭底层的投递通道
+	close(deliveries)
+	waitClosed(t, s.deliveries)
+	// 未转发的消息重新入队
+	if first.nacks != 0 || second.nacks != 1 || !second.requeue {
+		t.Fatalf("unexpected acks %+v, %+v", first, second)
+	}
+}
+
+func TestForward_closed(t *testing.T) {
+	r := newTestRabbitmqChannel()
+	r.closed = true
+	s := newSubscription("test", "ctag.test", true, false, false, false, nil)
+	deliveries := make(chan amqp.Delivery)
+	go r.forward(s, deliveries)
+
+	// 调用Close后底层通道关闭,不再重新注册
+	close(deliveries)
+	waitClosed(t, s.deliveries)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordTopology(t *testing.T) {
+	r := newTestRabbitmqChannel()
+	r.record(binding{exchange: "dcs.api.async", kind: "direct", queue: "msg.dcs.woden", key: "msg.dcs.woden"})
+	r.record(binding{exchange: "dcs.api.async", kind: "direct", queue: "msg.dcs.rudder", key: "msg.dcs.rudder"})
+	r.record(binding{exchange: "dcs.api.async", kind: "direct", queue: "msg.dcs.woden", key: "msg.dcs.woden",
+		queueArg: map[string]interface{}{"x-message-ttl": 1000}})
+	// 自动过期的队列不记录
+	r.record(binding{exchange: "dcs.api.async.delay", kind: "direct", queue: "dcs.api.async.delay.1000",
+		key: "dcs.api.async.delay.1000", queueArg: map[string]interface{}{"x-expires": 61000}})
+	// 相同的绑定保留最后一次的参数及首次声明的顺序
+	if len(r.topology) != 2 || r.topology[0].queue != "msg.dcs.woden" || r.topology[0].queueArg == nil {
+		t.Fatalf("unexpected topology %+v", r.topology)
+	}
+}
@@ -2,13 +2,18 @@ package async
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"sync"
 
 	jsoniter "github.com/json-iterator/go"
 	uuid "github.com/satori/go.uuid"
 	"github.com/streadway/amqp"
+	"go.uber.org/multierr"
 
 	"template/pkg/conc/pool"
+	"template/pkg/idempotent"
 	"template/pkg/logger/gormx"
 	"template/pkg/validator"
 )
@@ -46,6 +51,10 @@ func NewTaskConsumer(opts ...Option) *taskConsumer {
 		validator: o.validator,
 		autoAck:   o.autoAck,
 		from:      o.form,
+		retry:     o.retry,
+		dlx:       o.dlx,
+		guard:     o.guard,
+		queues:    &ttlQueues{expires: defaultDelayExpires},
 	}
 }
 
@@ -57,16 +66,30 @@ type taskConsumer struct {
 	validator validator.Validator
 	autoAck   bool
 	from      func(context.Context) gormx.Logger
+	retry     RetryPolicy
+	dlx       string
+	// 实现 RetryPolicyHandler 的任务的重试策略
+	policies sync.Map
+	queues   *ttlQueues
+	guard    *idempotent.Guard
 }
 
 // Register registers a TaskHandler with name
 func (t *taskConsumer) Register(handlers ...TaskHandler) {
 	t.manager.Register(handlers...)
+	for _, handler := range handlers {
+		if h, ok := handler.(RetryPolicyHandler); ok {
+			t.policies.Store(h.Name(), h.RetryPolicy())
+		}
+	}
 }
 
 // Unregister unregisters a TaskHandler with name
 func (t *taskConsumer) Unregister(names ...string) {
 	t.manager.Unregister(names...)
+	for _, name := range names {
+		t.policies.Delete(name)
+	}
 }
 
 const consumerTagLengthMax = 0xFF // see writeShortstr
@@ -89,9 +112,19 @@ func commandNameBasedUniqueConsumerTag(name string) string {
 	return tagPrefix + tagInfix + tagSuffix
 }
 
+// canceler 支持取消消费者的通道
+type canceler interface {
+	Cancel(consumer string) error
+}
+
 // Subscribe consume message form Channel with queueName
 func (t *taskConsumer) Subscribe(ctx context.Context, channel Channel, queueName string) error {
 	var err error
+	if t.dlx != "" {
+		if err = channel.DeclareAndBind(t.dlx, "direct", DeadLetterQueue(queueName), queueName); err != nil {
+			return fmt.Errorf("declare dead letter queue failed,%w", err)
+		}
+	}
 	p := t.pool.WithContext(ctx).WithCancelOnError()
 	p.Go(func(ctx context.Context) error {
 		for {
@@ -101,10 +134,11 @@ func (t *taskConsumer) Subscribe(ctx context.Context, channel Channel, queueName
 			default:
 			}
 			var deliveries <-chan amqp.Delivery
+			tag := uniqueConsumerTag(queueName)
 			if deliveries, err = channel.Consume(
 				queueName,
 				// 用来区分多个消费者
-				uniqueConsumerTag(queueName),
+				tag,
 				// 是否自动应答(自动应答确认消息，这里设置为否，在下面手动应答确认)
 				t.autoAck,
 				// 是否具有排他性
@@ -118,13 +152,19 @@ func (t *taskConsumer) Subscribe(ctx context.Context, channel Channel, queueName
 			); err != nil {
 				return err
 			}
-			t.handleMessage(ctx, deliveries)
+			t.handleMessage(ctx, channel, queueName, deliveries)
+			// 退出时取消消费者,未处理的消息重新入队
+			if c, ok := channel.(canceler); ok && ctx.Err() != nil {
+				if err := c.Cancel(tag); err != nil {
+					t.from(ctx).Errorf("err:%+v", err)
+				}
+			}
 		}
 	})
 	return p.Wait()
 }
 
-func (t *taskConsumer) handleMessage(ctx context.Context, deliveries <-chan amqp.Delivery) {
+func (t *taskConsumer) handleMessage(ctx context.Context, channel Channel, queue string, deliveries <-chan amqp.Delivery) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -136,12 +176,12 @@ func (t *taskConsumer) handleMessage(ctx context.Context, deliveries <-chan amqp
 			p := t.pool.WithContext(ctx)
 			p.Go(func(ctx context.Context) error {
 				if t.autoAck {
-					if err := t.autoHandle(ctx, v); err != nil {
+					if err := t.autoHandle(ctx, channel, queue, v); err != nil {
 						t.from(ctx).Errorf("err:%+v", err)
 					}
 					return nil
 				}
-				if err := t.manualHandle(ctx, v); err != nil {
+				if err := t.manualHandle(ctx, channel, queue, v); err != nil {
 					t.from(ctx).Errorf("err:%+v", err)
 				}
 				return nil
@@ -150,67 +190,78 @@ func (t *taskConsumer) handleMessage(ctx context.Context, deliveries <-chan amqp
 	}
 }
 
-func (t *taskConsumer) manualHandle(ctx context.Context, d amqp.Delivery) error {
+func (t *taskConsumer) manualHandle(ctx context.Context, channel Channel, queue string, d amqp.Delivery) error {
 	msgStruct, err := t.marshal.Unmarshal(&d)
 	if err != nil {
 		t.from(ctx).Errorf("err:%+v", err)
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil {
-			return err
-		}
-		return nil
+		return t.reject(ctx, channel, queue, &d, "", err, false)
 	}
 	logContext := t.from(ctx)
 	logContext.Debugf("get body %s,uuid:%s", msgStruct.Payload, msgStruct.UUID)
 	param := Get()
+	defer Put(param)
 	if err = t.handler.Unmarshal(msgStruct.Payload, param); err != nil {
 		logContext.Errorf("err:%+v", err)
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil {
-			return err
-		}
-		return nil
+		return t.reject(ctx, channel, queue, &d, param.TaskType, err, false)
 	}
 	if err = t.validator.ValidateStruct(param); err != nil {
 		logContext.Errorf("err:%+v", err)
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil {
-			return err
-		}
-		return nil
+		return t.reject(ctx, channel, queue, &d, param.TaskType, err, false)
 	}
-	err = t.manager.Run(ctx, param)
-	Put(param)
-	if err != nil {
+	if err = t.run(ctx, msgStruct.UUID, param); err != nil {
 		logContext.Errorf("err:%+v", err)
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(true); err != nil {
-			return err
-		}
-		return nil
+		return t.reject(ctx, channel, queue, &d, param.TaskType, err, true)
 	}
 	// 手动确认收到本条消息, true表示回复当前信道所有未回复的ack，用于批量确认。
 	// false表示回复当前条目
 	return d.Ack(false)
 }
 
-func (t *taskConsumer) autoHandle(ctx context.Context, d amqp.Delivery) error {
+// autoHandle 自动确认时消息已确认,失败时仅投递到重试队列或死信交换机
+func (t *taskConsumer) autoHandle(ctx context.Context, channel Channel, queue string, d amqp.Delivery) error {
 	msgStruct, err := t.marshal.Unmarshal(&d)
 	if err != nil {
-		return err
+		_, ferr := t.fail(channel, queue, &d, "", err, false)
+		return multierr.Append(err, ferr)
 	}
 
 	t.from(ctx).Debugf("get body consume:%s,uuid:%s", msgStruct.Payload, msgStruct.UUID)
 	param := Get()
+	defer Put(param)
 	if err = t.handler.Unmarshal(msgStruct.Payload, param); err != nil {
-		return err
+		_, ferr := t.fail(channel, queue, &d, param.TaskType, err, false)
+		return multierr.Append(err, ferr)
 	}
 	if err = t.validator.ValidateStruct(param); err != nil {
-		return err
+		_, ferr := t.fail(channel, queue, &d, param.TaskType, err, false)
+		return multierr.Append(err, ferr)
+	}
+	if err = t.run(ctx, msgStruct.UUID, param); err != nil {
+		_, ferr := t.fail(channel, queue, &d, param.TaskType, err, true)
+		return multierr.Append(err, ferr)
+	}
+	return nil
+}
+
+// idempotentPrefix 消息uuid的幂等key前缀
+const idempotentPrefix = "async:"
+
+// run 执行任务,配置幂等时已执行成功的消息不再执行
+func (t *taskConsumer) run(ctx context.Context, uuid string, param *Param) error {
+	if t.guard == nil || uuid == "" {
+		return t.manager.Run(ctx, param)
+	}
+	_, err := t.guard.Do(ctx, idempotentPrefix+uuid, func(ctx context.Context) (*idempotent.Record, error) {
+		return nil, t.manager.Run(ctx, param)
+	})
+	switch {
+	case errors.Is(err, idempotent.ErrDuplicate):
+		t.from(ctx).Infof("skip duplicate message,uuid:%s", uuid)
+		return nil
+	case errors.Is(err, idempotent.ErrNotRecorded):
+		// 任务已执行成功,仅记录失败
+		t.from(ctx).Errorf("err:%+v", err)
+		return nil
 	}
-	return t.manager.Run(ctx, param)
+	return err
 }
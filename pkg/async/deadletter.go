@@ -0,0 +1,240 @@
+package async
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/streadway/amqp"
+	"go.uber.org/multierr"
+)
+
+var (
+	ErrDeadLetterNotFound      = errors.New("dead letter not found")
+	ErrDeadLetterQueueNotFound = errors.New("dead letter queue not found")
+
+	// errStopScan 停止遍历死信队列
+	errStopScan = errors.New("stop scan")
+)
+
+// DeadLetter 死信队列中的消息
+type DeadLetter struct {
+	// 消息的uuid,不存在时为 MessageId 或消息体的sha1
+	ID    string `json:"id"`
+	Queue string `json:"queue"`
+	// 解析后的任务参数,无法解析时为空并返回原始消息体
+	Param *Param `json:"param,omitempty"`
+	Body  string `json:"body,omitempty"`
+	// 失败原因及已失败的次数
+	Reason   string    `json:"reason"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+	// 第一次失败时消息的交换机及路由
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+// DeadLetterManager 管理消费队列对应的死信队列,查看、重新投递或清除死信
+type DeadLetterManager struct {
+	channel QueueChannel
+	marshal MarshalAPI
+	handler jsoniter.API
+	queues  map[string]struct{}
+	// 遍历时消息在确认前不会重复获取,同一时间仅允许一个遍历
+	mux sync.Mutex
+}
+
+// NewDeadLetterManager 创建死信管理,queues为消费的队列名称,仅允许管理这些队列的死信。
+// 支持 WithMarshalAPI 及 WithJSON 选项
+func NewDeadLetterManager(channel QueueChannel, queues []string, opts ...Option) *DeadLetterManager {
+	o := &option{
+		marshal: DefaultMarshal{},
+		handler: jsoniter.ConfigCompatibleWithStandardLibrary,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	m := &DeadLetterManager{
+		channel: channel,
+		marshal: o.marshal,
+		handler: o.handler,
+		queues:  make(map[string]struct{}, len(queues)),
+	}
+	for _, queue := range queues {
+		m.queues[queue] = struct{}{}
+	}
+	return m
+}
+
+// Queues 可管理的队列名称
+func (m *DeadLetterManager) Queues() []string {
+	queues := make([]string, 0, len(m.queues))
+	for queue := range m.queues {
+		queues = append(queues, queue)
+	}
+	sort.Strings(queues)
+	return queues
+}
+
+// List 获取死信队列头部的消息,limit不大于0时获取全部
+func (m *DeadLetterManager) List(queue string, limit int) ([]*DeadLetter, error) {
+	letters := make([]*DeadLetter, 0)
+	err := m.scan(queue, func(_ *amqp.Delivery, letter *DeadLetter) (bool, error) {
+		letters = append(letters, letter)
+		if limit > 0 && len(letters) >= limit {
+			return false, errStopScan
+		}
+		return false, nil
+	})
+	return letters, err
+}
+
+// Get 获取指定的死信,不存在时返回 ErrDeadLetterNotFound
+func (m *DeadLetterManager) Get(queue, id string) (*DeadLetter, error) {
+	var found *DeadLetter
+	err := m.scan(queue, func(_ *amqp.Delivery, letter *DeadLetter) (bool, error) {
+		if letter.ID != id {
+			return false, nil
+		}
+		found = letter
+		return false, errStopScan
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return found, nil
+}
+
+// Replay 将死信重新投递到原队列并清除失败信息,id为空时投递全部,返回投递的数量
+func (m *DeadLetterManager) Replay(queue, id string) (int, error) {
+	count := 0
+	err := m.scan(queue, func(d *amqp.Delivery, letter *DeadLetter) (bool, error) {
+		if id != "" && letter.ID != id {
+			return false, nil
+		}
+		msg := republish(d, nil)
+		for _, key := range []string{RetryAttemptsHeader, FailureReasonHeader, FailedAtHeader,
+			OriginalExchangeHeader, OriginalRoutingKeyHeader} {
+			delete(msg.Headers, key)
+		}
+		// 通过默认交换机直接投递到原队列
+		if err := m.channel.Publish("", queue, false, false, msg); err != nil {
+			return false, fmt.Errorf("replay dead letter %s failed,%w", letter.ID, err)
+		}
+		count++
+		if id != "" {
+			return true, errStopScan
+		}
+		return true, nil
+	})
+	if err == nil && id != "" && count == 0 {
+		err = ErrDeadLetterNotFound
+	}
+	return count, err
+}
+
+// Purge 清除死信,id为空时清空死信队列,返回清除的数量
+func (m *DeadLetterManager) Purge(queue, id string) (int, error) {
+	if id == "" {
+		if _, ok := m.queues[queue]; !ok {
+			return 0, ErrDeadLetterQueueNotFound
+		}
+		return m.channel.Purge(DeadLetterQueue(queue))
+	}
+	count := 0
+	err := m.scan(queue, func(_ *amqp.Delivery, letter *DeadLetter) (bool, error) {
+		if letter.ID != id {
+			return false, nil
+		}
+		count++
+		return true, errStopScan
+	})
+	if err == nil && count == 0 {
+		err = ErrDeadLetterNotFound
+	}
+	return count, err
+}
+
+// scan 依次获取死信队列的消息,visit返回true时确认消息,其他消息在遍历结束后重新入队,
+// 仅遍历首次获取时队列中已有的消息,重放失败后重新进入死信队列的消息不再遍历
+func (m *DeadLetterManager) scan(queue string, visit func(*amqp.Delivery, *DeadLetter) (bool, error)) (err error) {
+	if _, ok := m.queues[queue]; !ok {
+		return ErrDeadLetterQueueNotFound
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	pending := make([]amqp.Delivery, 0)
+	defer func() {
+		for i := range pending {
+			err = multierr.Append(err, pending[i].Nack(false, true))
+		}
+	}()
+	for total, visited := 1, 0; visited < total; visited++ {
+		d, ok, err := m.channel.Get(DeadLetterQueue(queue), false)
+		if err != nil || !ok {
+			return err
+		}
+		if visited == 0 {
+			// MessageCount 为获取后队列中剩余的消息数
+			total += int(d.MessageCount)
+		}
+		consumed, err := visit(&d, m.decode(queue, &d))
+		if !consumed {
+			pending = append(pending, d)
+		} else if aerr := d.Ack(false); aerr != nil {
+			return aerr
+		}
+		if errors.Is(err, errStopScan) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *DeadLetterManager) decode(queue string, d *amqp.Delivery) *DeadLetter {
+	letter := &DeadLetter{
+		ID:         d.MessageId,
+		Queue:      queue,
+		Attempts:   retryAttempts(d),
+		Exchange:   d.Exchange,
+		RoutingKey: d.RoutingKey,
+	}
+	if v, ok := d.Headers[OriginalExchangeHeader].(string); ok {
+		letter.Exchange = v
+	}
+	if v, ok := d.Headers[OriginalRoutingKeyHeader].(string); ok {
+		letter.RoutingKey = v
+	}
+	letter.Reason, _ = d.Headers[FailureReasonHeader].(string)
+	if v, ok := d.Headers[FailedAtHeader].(string); ok {
+		letter.FailedAt, _ = time.Parse(time.RFC3339, v)
+	}
+	if msg, err := m.marshal.Unmarshal(d); err == nil {
+		if msg.UUID != "" {
+			letter.ID = msg.UUID
+		}
+		param := &Param{}
+		if err = m.handler.Unmarshal(msg.Payload, param); err == nil {
+			letter.Param = param
+		}
+	}
+	if letter.Param == nil {
+		letter.Body = string(d.Body)
+	}
+	if letter.ID == "" {
+		sum := sha1.Sum(d.Body)
+		letter.ID = hex.EncodeToString(sum[:])
+	}
+	return letter
+}
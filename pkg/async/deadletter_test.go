@@ -0,0 +1,109 @@
+package async
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+)
+
+func TestDeadLetterManager(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	ack := &recordAck{}
+	first := newDelivery(t, ack, &Param{TaskType: "fail"}, amqp.Table{
+		DefaultMessageUUIDHeaderKey: "first",
+		RetryAttemptsHeader:         "3",
+		FailureReasonHeader:         "boom",
+		FailedAtHeader:              "2026-10-17T10:00:00Z",
+		OriginalExchangeHeader:      "dcs.api.async",
+		OriginalRoutingKeyHeader:    "msg.dcs.woden",
+	})
+	first.MessageCount = 1
+	second := newDelivery(t, ack, "poison", amqp.Table{DefaultMessageUUIDHeaderKey: "second"})
+	cc := NewMockQueueChannel(ctl)
+	// 每次遍历依次获取两条消息后队列为空
+	gets := 0
+	cc.EXPECT().Get("dcs.woden.dlq", false).DoAndReturn(func(string, bool) (amqp.Delivery, bool, error) {
+		defer func() { gets++ }()
+		switch gets % 3 {
+		case 0:
+			return first, true, nil
+		case 1:
+			return second, true, nil
+		default:
+			return amqp.Delivery{}, false, nil
+		}
+	}).AnyTimes()
+	cc.EXPECT().Publish("", "dcs.woden", false, false, gomock.Any()).
+		DoAndReturn(func(_, _ string, _, _ bool, msg ...amqp.Publishing) error {
+			for _, key := range []string{RetryAttemptsHeader, FailureReasonHeader, FailedAtHeader} {
+				if _, ok := msg[0].Headers[key]; ok {
+					t.Errorf("replay should remove header %s", key)
+				}
+			}
+			return nil
+		})
+	cc.EXPECT().Purge("dcs.woden.dlq").Return(2, nil)
+
+	m := NewDeadLetterManager(cc, []string{"dcs.woden"})
+	letters, err := m.List("dcs.woden", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 2 || ack.nacks != 2 || !ack.requeue {
+		t.Fatalf("unexpected list %v, %+v", letters, ack)
+	}
+	if l := letters[0]; l.ID != "first" || l.Param == nil || l.Param.TaskType != "fail" || l.Reason != "boom" ||
+		l.Attempts != 3 || l.FailedAt.IsZero() || l.Exchange != "dcs.api.async" {
+		t.Fatalf("unexpected dead letter %+v", l)
+	}
+	if l := letters[1]; l.ID != "second" || l.Param != nil || l.Body != `"poison"` {
+		t.Fatalf("unexpected poison dead letter %+v", l)
+	}
+
+	if _, err = m.Get("dcs.woden", "missing"); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err = m.List("other", 0); !errors.Is(err, ErrDeadLetterQueueNotFound) {
+		t.Fatalf("expected queue not found, got %v", err)
+	}
+
+	gets = 0
+	ack.acks, ack.nacks = 0, 0
+	count, err := m.Replay("dcs.woden", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || ack.acks != 1 || ack.nacks != 0 {
+		t.Fatalf("unexpected replay %d, %+v", count, ack)
+	}
+	if count, err = m.Purge("dcs.woden", ""); err != nil || count != 2 {
+		t.Fatalf("unexpected purge %d, %v", count, err)
+	}
+}
+
+func TestDeadLetterManager_replayAll(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	ack := &recordAck{}
+	letter := newDelivery(t, ack, "poison", amqp.Table{DefaultMessageUUIDHeaderKey: "poison"})
+	letter.MessageCount = 2
+	cc := NewMockQueueChannel(ctl)
+	// 重放的消息再次失败后回到死信队列,队列始终不为空
+	cc.EXPECT().Get("dcs.woden.dlq", false).Return(letter, true, nil).Times(3)
+	cc.EXPECT().Publish("", "dcs.woden", false, false, gomock.Any()).Return(nil).Times(3)
+
+	m := NewDeadLetterManager(cc, []string{"dcs.woden"})
+	count, err := m.Replay("dcs.woden", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 仅重放首次获取时队列中已有的消息
+	if count != 3 || ack.acks != 3 {
+		t.Fatalf("unexpected replay %d, %+v", count, ack)
+	}
+}
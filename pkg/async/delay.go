@@ -0,0 +1,173 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+type DelayMode string
+
+const (
+	// DelayTTL 按延迟时间声明带TTL的队列,过期后通过死信交换机投递到目标交换机
+	DelayTTL DelayMode = "ttl"
+	// DelayPlugin 使用 rabbitmq_delayed_message_exchange 插件的延迟交换机
+	DelayPlugin DelayMode = "plugin"
+)
+
+const (
+	delayedExchangeKind = "x-delayed-message"
+	delayHeader         = "x-delay"
+
+	defaultDelayPrecision = time.Second
+	defaultDelayExpires   = 10 * time.Minute
+)
+
+type delayOption struct {
+	mode      DelayMode
+	precision time.Duration
+	expires   time.Duration
+}
+
+type DelayOption func(*delayOption)
+
+// WithDelayMode 设置延迟投递的方式,默认为 DelayTTL
+func WithDelayMode(mode DelayMode) DelayOption {
+	return func(o *delayOption) {
+		o.mode = mode
+	}
+}
+
+// WithDelayPrecision 延迟时间的精度,TTL方式下每个精度的延迟对应一个队列,默认1s
+func WithDelayPrecision(precision time.Duration) DelayOption {
+	return func(o *delayOption) {
+		o.precision = precision
+	}
+}
+
+// WithDelayExpires TTL方式下延迟队列在消息全部过期后保留的时间,默认10m
+func WithDelayExpires(expires time.Duration) DelayOption {
+	return func(o *delayOption) {
+		o.expires = expires
+	}
+}
+
+// DelayedProducer 支持延迟及定时投递的生产者
+type DelayedProducer struct {
+	Producer
+	delayOption
+	queues *ttlQueues
+}
+
+// NewDelayedProducer 在 producer 的基础上支持延迟投递,延迟的消息发送到 <exchange>.delay 交换机
+func NewDelayedProducer(producer Producer, opts ...DelayOption) *DelayedProducer {
+	o := delayOption{
+		mode:      DelayTTL,
+		precision: defaultDelayPrecision,
+		expires:   defaultDelayExpires,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.precision <= 0 {
+		o.precision = time.Millisecond
+	}
+	return &DelayedProducer{
+		Producer:    producer,
+		delayOption: o,
+		queues:      &ttlQueues{expires: o.expires},
+	}
+}
+
+// Bind 声明交换机及队列并绑定,DelayPlugin 方式下同时将队列绑定到延迟交换机
+func (d *DelayedProducer) Bind(channel Channel, exchange, kind, queue, key string) error {
+	if err := channel.DeclareAndBind(exchange, kind, queue, key); err != nil {
+		return err
+	}
+	if d.mode != DelayPlugin {
+		return nil
+	}
+	return channel.DeclareAndBind(DelayExchange(exchange), delayedExchangeKind, queue, key,
+		map[string]interface{}{"x-delayed-type": kind})
+}
+
+// PublishDelay 延迟投递,delay不大于0时立即投递
+func (d *DelayedProducer) PublishDelay(ctx context.Context, channel Channel, exchange, routingKey string,
+	delay time.Duration, param interface{}) error {
+	delay = delay.Round(d.precision)
+	if delay <= 0 {
+		return d.Publish(ctx, channel, exchange, routingKey, param)
+	}
+	return d.Publish(ctx, &delayChannel{Channel: channel, producer: d, delay: delay}, exchange, routingKey, param)
+}
+
+// PublishAt 在指定时间投递,时间已过时立即投递
+func (d *DelayedProducer) PublishAt(ctx context.Context, channel Channel, exchange, routingKey string,
+	at time.Time, param interface{}) error {
+	return d.PublishDelay(ctx, channel, exchange, routingKey, time.Until(at), param)
+}
+
+// DelayExchange 延迟消息的交换机名称
+func DelayExchange(exchange string) string {
+	return exchange + ".delay"
+}
+
+// delayQueue TTL方式下延迟队列的名称
+func delayQueue(exchange, key string, delay time.Duration) string {
+	return fmt.Sprintf("%s.%s.%d", DelayExchange(exchange), key, delay.Milliseconds())
+}
+
+// ttlQueues 声明带TTL的延迟队列,过期的消息通过死信投递到目标交换机及路由
+type ttlQueues struct {
+	// 消息全部过期后队列保留的时间
+	expires time.Duration
+	// 已声明的队列及声明时间
+	declared sync.Map
+}
+
+// declare 声明延迟队列并绑定到交换机,队列名称同时作为绑定的路由。
+// 队列在声明后 ttl+expires 内未重新声明时自动删除,因此超过 expires/2 后重新声明
+func (q *ttlQueues) declare(channel Channel, exchange, queue, dlx, dlk string, ttl time.Duration) error {
+	if v, ok := q.declared.Load(queue); ok && time.Since(v.(time.Time)) < q.expires/2 {
+		return nil
+	}
+	if err := channel.DeclareAndBind(exchange, "direct", queue, queue, nil, map[string]interface{}{
+		"x-message-ttl":             ttl.Milliseconds(),
+		"x-dead-letter-exchange":    dlx,
+		"x-dead-letter-routing-key": dlk,
+		"x-expires":                 (ttl + q.expires).Milliseconds(),
+	}); err != nil {
+		return fmt.Errorf("declare delay queue %s failed,%w", queue, err)
+	}
+	q.declared.Store(queue, time.Now())
+	return nil
+}
+
+// delayChannel 将消息转发到延迟交换机
+type delayChannel struct {
+	Channel
+	producer *DelayedProducer
+	delay    time.Duration
+}
+
+func (c *delayChannel) Publish(exchange, key string, mandatory, immediate bool, msg ...amqp.Publishing) error {
+	if c.producer.mode == DelayPlugin {
+		for i := range msg {
+			headers := make(amqp.Table, len(msg[i].Headers)+1)
+			for k, v := range msg[i].Headers {
+				headers[k] = v
+			}
+			headers[delayHeader] = c.delay.Milliseconds()
+			msg[i].Headers = headers
+		}
+		return c.Channel.Publish(DelayExchange(exchange), key, mandatory, immediate, msg...)
+	}
+	queue := delayQueue(exchange, key, c.delay)
+	if err := c.producer.queues.declare(c.Channel, DelayExchange(exchange), queue, exchange, key, c.delay); err != nil {
+		return err
+	}
+	return c.Channel.Publish(DelayExchange(exchange), queue, mandatory, immediate, msg...)
+}
@@ -0,0 +1,89 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+)
+
+func TestDelayedProducer_ttl(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	queue := "dcs.api.async.delay.msg.dcs.woden.600000"
+	cc := NewMockChannel(ctl)
+	// 相同的延迟只声明一次队列
+	cc.EXPECT().DeclareAndBind("dcs.api.async.delay", "direct", queue, queue, gomock.Nil(), map[string]interface{}{
+		"x-message-ttl":             int64(600000),
+		"x-dead-letter-exchange":    "dcs.api.async",
+		"x-dead-letter-routing-key": "msg.dcs.woden",
+		"x-expires":                 int64(1200000),
+	}).Return(nil).Times(1)
+	cc.EXPECT().Publish("dcs.api.async.delay", queue, false, false, gomock.Any()).Return(nil).Times(2)
+	cc.EXPECT().Publish("dcs.api.async", "msg.dcs.woden", false, false, gomock.Any()).Return(nil).Times(1)
+
+	p := NewDelayedProducer(NewTaskProducer())
+	param := &Param{TaskType: "test"}
+	ctx := context.Background()
+	if err := p.PublishDelay(ctx, cc, "dcs.api.async", "msg.dcs.woden", 10*time.Minute, param); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PublishAt(ctx, cc, "dcs.api.async", "msg.dcs.woden", time.Now().Add(10*time.Minute+100*time.Millisecond), param); err != nil {
+		t.Fatal(err)
+	}
+	// 已过期的时间立即投递
+	if err := p.PublishAt(ctx, cc, "dcs.api.async", "msg.dcs.woden", time.Now().Add(-time.Minute), param); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDelayedProducer_plugin(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	cc := NewMockChannel(ctl)
+	gomock.InOrder(
+		cc.EXPECT().DeclareAndBind("dcs.api.async", "topic", "dcs.woden", "msg.dcs.woden").Return(nil),
+		cc.EXPECT().DeclareAndBind("dcs.api.async.delay", delayedExchangeKind, "dcs.woden", "msg.dcs.woden",
+			map[string]interface{}{"x-delayed-type": "topic"}).Return(nil),
+	)
+	cc.EXPECT().Publish("dcs.api.async.delay", "msg.dcs.woden", false, false, gomock.Any()).
+		DoAndReturn(func(_, _ string, _, _ bool, msg ...amqp.Publishing) error {
+			if len(msg) != 1 || msg[0].Headers[delayHeader] != int64(1500) {
+				t.Errorf("unexpected delay header %v", msg[0].Headers)
+			}
+			if _, ok := msg[0].Headers[DefaultMessageUUIDHeaderKey]; !ok {
+				t.Errorf("message uuid header missing")
+			}
+			return nil
+		})
+
+	p := NewDelayedProducer(NewTaskProducer(), WithDelayMode(DelayPlugin), WithDelayPrecision(100*time.Millisecond))
+	if err := p.Bind(cc, "dcs.api.async", "topic", "dcs.woden", "msg.dcs.woden"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PublishDelay(context.Background(), cc, "dcs.api.async", "msg.dcs.woden", 1520*time.Millisecond, &Param{TaskType: "test"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultMarshal_brokerHeaders(t *testing.T) {
+	msg, err := (DefaultMarshal{}).Unmarshal(&amqp.Delivery{Headers: amqp.Table{
+		DefaultMessageUUIDHeaderKey: "uuid",
+		"x-death":                   []interface{}{amqp.Table{"count": int64(1)}},
+		"x-delay":                   int64(-1000),
+		"trace":                     "id",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.UUID != "uuid" || len(msg.Metadata) != 1 || msg.Metadata["trace"] != "id" {
+		t.Fatalf("unexpected message %#v", msg)
+	}
+	if _, err = (DefaultMarshal{}).Unmarshal(&amqp.Delivery{Headers: amqp.Table{"count": int64(1)}}); err == nil {
+		t.Fatal("expected error for non string metadata")
+	}
+}
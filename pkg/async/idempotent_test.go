@@ -0,0 +1,55 @@
+package async
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+
+	"template/pkg/idempotent"
+)
+
+type countHandler struct {
+	runs int
+}
+
+func (c *countHandler) Name() string {
+	return "count"
+}
+
+func (c *countHandler) Run(ctx context.Context, param *Param) error {
+	c.runs++
+	return nil
+}
+
+func TestTaskConsumer_idempotent(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	handler := &countHandler{}
+	tc := NewTaskConsumer(WithAck(false), WithIdempotent(idempotent.NewGuard(idempotent.NewMemoryStore())))
+	tc.Register(handler)
+	ctx := context.Background()
+	param := &Param{TaskType: "count"}
+	for i := 0; i < 2; i++ {
+		ack := &recordAck{}
+		// 重复投递的消息uuid相同
+		d := newDelivery(t, ack, param, amqp.Table{DefaultMessageUUIDHeaderKey: "same"})
+		if err := tc.manualHandle(ctx, NewMockChannel(ctl), "dcs.woden", d); err != nil {
+			t.Fatal(err)
+		}
+		if ack.acks != 1 {
+			t.Fatalf("message should be acked, %+v", ack)
+		}
+	}
+	if handler.runs != 1 {
+		t.Fatalf("duplicate message run %d times", handler.runs)
+	}
+	if err := tc.manualHandle(ctx, NewMockChannel(ctl), "dcs.woden", newDelivery(t, &recordAck{}, param, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if handler.runs != 2 {
+		t.Fatalf("new message should run, got %d", handler.runs)
+	}
+}
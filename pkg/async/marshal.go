@@ -2,6 +2,7 @@ package async
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/streadway/amqp"
@@ -57,11 +58,15 @@ func (d DefaultMarshal) Unmarshal(amqpMsg *amqp.Delivery) (*message.Message, err
 			continue
 		}
 
-		var ok bool
-		msg.Metadata[key], ok = value.(string)
+		str, ok := value.(string)
 		if !ok {
+			// 忽略rabbitmq添加的头,如死信的 x-death 及延迟交换机的 x-delay
+			if strings.HasPrefix(key, "x-") {
+				continue
+			}
 			return nil, fmt.Errorf("metadata %s is not a string, but %#v", key, value)
 		}
+		msg.Metadata[key] = str
 	}
 	return msg, nil
 }
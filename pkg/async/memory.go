@@ -0,0 +1,668 @@
+package async
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var _ QueueChannel = (*MemoryChannel)(nil)
+
+// MemoryChannel 进程内实现的 Channel,用于单元测试及无rabbitmq的本地开发。
+// 支持direct、fanout、topic及延迟交换机的路由,默认交换机按队列名称投递,消息的确认、拒绝及按原顺序重新入队,
+// 消费者的预取数量,以及 x-message-ttl、x-dead-letter-exchange、x-dead-letter-routing-key 队列参数和消息的过期时间。
+// 所有消费者视为同一连接上的不同通道,Qos的PrefetchSize及Global不生效
+type MemoryChannel struct {
+	qos       *QosOption
+	mu        sync.Mutex
+	cond      *sync.Cond
+	exchanges map[string]*memoryExchange
+	queues    map[string]*memoryQueue
+	consumers map[string]*memoryConsumer
+	// 已投递未确认的消息,按投递序号索引
+	unacked map[uint64]*memoryUnacked
+	tag     uint64
+	seq     uint64
+	closed  bool
+}
+
+// NewMemoryChannel 仅 WithQos 生效
+func NewMemoryChannel(opts ...ChannelOption) *MemoryChannel {
+	o := &clientOption{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	m := &MemoryChannel{
+		qos:       o.qos,
+		exchanges: make(map[string]*memoryExchange),
+		queues:    make(map[string]*memoryQueue),
+		consumers: make(map[string]*memoryConsumer),
+		unacked:   make(map[uint64]*memoryUnacked),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+type memoryExchange struct {
+	kind string
+	// 延迟交换机到期后按该类型路由
+	delayedType string
+	bindings    []memoryBinding
+}
+
+type memoryBinding struct {
+	queue, key string
+}
+
+type memoryQueue struct {
+	name string
+	// 队列中消息的过期时间
+	ttl    time.Duration
+	hasTTL bool
+	// 死信交换机及路由,未设置路由时使用消息原来的路由
+	deadLetter bool
+	dlx, dlk   string
+	ready      []*memoryMessage
+	consumers  []*memoryConsumer
+	// 轮询投递的下一个消费者
+	next int
+}
+
+type memoryMessage struct {
+	amqp.Publishing
+	exchange, key string
+	// 入队的顺序,重新入队时按该顺序插入
+	seq         uint64
+	redelivered bool
+	expiredAt   time.Time
+}
+
+type memoryConsumer struct {
+	tag     string
+	queue   *memoryQueue
+	autoAck bool
+	// 是否独占队列
+	exclusive bool
+	// 已投递未确认的消息数,用于预取限制
+	unacked int
+	// 已分配但未转发的消息
+	buffer     []amqp.Delivery
+	deliveries chan amqp.Delivery
+	done       chan struct{}
+	canceled   bool
+}
+
+type memoryUnacked struct {
+	queue *memoryQueue
+	// 通过Get获取的消息为nil
+	consumer *memoryConsumer
+	message  *memoryMessage
+}
+
+func (m *MemoryChannel) Publish(exchange, key string, mandatory, immediate bool, msg ...amqp.Publishing) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrChannelClosed
+	}
+	for i := range msg {
+		if err := m.route(exchange, key, msg[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrChannelClosed
+	}
+	q, ok := m.queues[queue]
+	if !ok {
+		return nil, fmt.Errorf("queue %s not found", queue)
+	}
+	if consumer == "" {
+		consumer = uniqueConsumerTag(queue)
+	}
+	if _, ok = m.consumers[consumer]; ok {
+		return nil, fmt.Errorf("consumer %s already exists", consumer)
+	}
+	if len(q.consumers) > 0 && (exclusive || q.consumers[0].exclusive) {
+		return nil, fmt.Errorf("queue %s in exclusive use", queue)
+	}
+	c := &memoryConsumer{
+		tag:        consumer,
+		queue:      q,
+		autoAck:    autoAck,
+		exclusive:  exclusive,
+		deliveries: make(chan amqp.Delivery),
+		done:       make(chan struct{}),
+	}
+	m.consumers[consumer] = c
+	q.consumers = append(q.consumers, c)
+	go m.deliver(c)
+	m.dispatch(q)
+	return c.deliveries, nil
+}
+
+// Cancel 取消消费者,已投递未确认的消息可继续确认,未转发的消息重新入队
+func (m *MemoryChannel) Cancel(consumer string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.consumers[consumer]; ok {
+		m.cancel(c)
+	}
+	return nil
+}
+
+func (m *MemoryChannel) DeclareAndBind(exchange, kind, queue, key string, args ...map[string]interface{}) error {
+	if kind != "direct" && kind != "fanout" && kind != "topic" && kind != delayedExchangeKind {
+		return fmt.Errorf("invalid kind %s", kind)
+	}
+	if exchange == "" {
+		return fmt.Errorf("invalid input %s", exchange)
+	}
+	if queue == "" {
+		return fmt.Errorf("invalid input %s", queue)
+	}
+	if key == "" {
+		return fmt.Errorf("invalid input %s", key)
+	}
+	var exchangeArg, queueArg map[string]interface{}
+	for index := range args {
+		switch index {
+		case 0:
+			exchangeArg = args[index]
+		case 1:
+			queueArg = args[index]
+		default:
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrChannelClosed
+	}
+	e, ok := m.exchanges[exchange]
+	if !ok {
+		e = &memoryExchange{kind: kind}
+		if kind == delayedExchangeKind {
+			e.delayedType, _ = exchangeArg["x-delayed-type"].(string)
+			if e.delayedType != "direct" && e.delayedType != "fanout" && e.delayedType != "topic" {
+				return fmt.Errorf("invalid x-delayed-type %s", e.delayedType)
+			}
+		}
+		m.exchanges[exchange] = e
+	} else if e.kind != kind {
+		return fmt.Errorf("exchange %s already declared as %s", exchange, e.kind)
+	}
+	// 队列已存在时忽略参数
+	if _, ok = m.queues[queue]; !ok {
+		q, err := newMemoryQueue(queue, queueArg)
+		if err != nil {
+			return err
+		}
+		m.queues[queue] = q
+	}
+	for _, b := range e.bindings {
+		if b.queue == queue && b.key == key {
+			return nil
+		}
+	}
+	e.bindings = append(e.bindings, memoryBinding{queue: queue, key: key})
+	return nil
+}
+
+func newMemoryQueue(name string, args map[string]interface{}) (*memoryQueue, error) {
+	q := &memoryQueue{name: name}
+	if v, ok := args["x-message-ttl"]; ok {
+		ttl, ok := milliseconds(v)
+		if !ok || ttl < 0 {
+			return nil, fmt.Errorf("invalid x-message-ttl %v", v)
+		}
+		q.ttl, q.hasTTL = ttl, true
+	}
+	if v, ok := args["x-dead-letter-exchange"]; ok {
+		q.dlx, _ = v.(string)
+		q.deadLetter = true
+	}
+	q.dlk, _ = args["x-dead-letter-routing-key"].(string)
+	return q, nil
+}
+
+func (m *MemoryChannel) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return amqp.Delivery{}, false, ErrChannelClosed
+	}
+	q, ok := m.queues[queue]
+	if !ok {
+		return amqp.Delivery{}, false, fmt.Errorf("queue %s not found", queue)
+	}
+	m.expire(q)
+	if len(q.ready) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+	msg := q.ready[0]
+	q.ready = q.ready[1:]
+	m.tag++
+	d := m.delivery(msg, m.tag, "")
+	d.MessageCount = uint32(len(q.ready))
+	if !autoAck {
+		m.unacked[m.tag] = &memoryUnacked{queue: q, message: msg}
+	}
+	return d, true, nil
+}
+
+func (m *MemoryChannel) Purge(queue string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrChannelClosed
+	}
+	q, ok := m.queues[queue]
+	if !ok {
+		return 0, fmt.Errorf("queue %s not found", queue)
+	}
+	count := len(q.ready)
+	q.ready = nil
+	return count, nil
+}
+
+// Close 取消全部消费者,未确认的消息重新入队
+func (m *MemoryChannel) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	for _, c := range m.consumers {
+		m.cancel(c)
+	}
+	for tag, u := range m.unacked {
+		delete(m.unacked, tag)
+		m.requeue(u.queue, u.message)
+	}
+	m.closed = true
+	return nil
+}
+
+// route 按交换机类型投递到匹配的队列,调用方需持有锁
+func (m *MemoryChannel) route(exchange, key string, msg amqp.Publishing) error {
+	if exchange == "" {
+		// 默认交换机按队列名称投递
+		if q, ok := m.queues[key]; ok {
+			m.enqueue(q, exchange, key, msg)
+		}
+		return nil
+	}
+	e, ok := m.exchanges[exchange]
+	if !ok {
+		return fmt.Errorf("exchange %s not found", exchange)
+	}
+	if e.kind != delayedExchangeKind {
+		m.bind(e, e.kind, exchange, key, msg)
+		return nil
+	}
+	delay, _ := milliseconds(msg.Headers[delayHeader])
+	if delay <= 0 {
+		m.bind(e, e.delayedType, exchange, key, msg)
+		return nil
+	}
+	time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if !m.closed {
+			m.bind(e, e.delayedType, exchange, key, msg)
+		}
+	})
+	return nil
+}
+
+// bind 投递到交换机绑定的队列,多个绑定匹配同一队列时仅投递一次
+func (m *MemoryChannel) bind(e *memoryExchange, kind, exchange, key string, msg amqp.Publishing) {
+	routed := make(map[string]struct{})
+	for _, b := range e.bindings {
+		if _, ok := routed[b.queue]; ok || !matchRoutingKey(kind, b.key, key) {
+			continue
+		}
+		routed[b.queue] = struct{}{}
+		m.enqueue(m.queues[b.queue], exchange, key, msg)
+	}
+}
+
+func matchRoutingKey(kind, pattern, key string) bool {
+	switch kind {
+	case "fanout":
+		return true
+	case "topic":
+		return matchTopic(strings.Split(pattern, "."), strings.Split(key, "."))
+	default:
+		return pattern == key
+	}
+}
+
+// matchTopic *匹配一个单词,#匹配零或多个单词
+func matchTopic(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchTopic(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchTopic(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchTopic(pattern[1:], words[1:])
+	}
+}
+
+func (m *MemoryChannel) enqueue(q *memoryQueue, exchange, key string, msg amqp.Publishing) {
+	m.seq++
+	message := &memoryMessage{Publishing: msg, exchange: exchange, key: key, seq: m.seq}
+	// 队列及消息的过期时间取较小值
+	ttl, ok := q.ttl, q.hasTTL
+	if expiration, err := strconv.ParseInt(msg.Expiration, 10, 64); err == nil && expiration >= 0 {
+		if d := time.Duration(expiration) * time.Millisecond; !ok || d < ttl {
+			ttl, ok = d, true
+		}
+	}
+	if ok {
+		message.expiredAt = time.Now().Add(ttl)
+		time.AfterFunc(ttl, func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if !m.closed {
+				m.expire(q)
+			}
+		})
+	}
+	q.ready = append(q.ready, message)
+	m.dispatch(q)
+}
+
+// expire 过期的消息按死信处理
+func (m *MemoryChannel) expire(q *memoryQueue) {
+	now := time.Now()
+	var expired []*memoryMessage
+	ready := make([]*memoryMessage, 0, len(q.ready))
+	for _, msg := range q.ready {
+		if !msg.expiredAt.IsZero() && !now.Before(msg.expiredAt) {
+			expired = append(expired, msg)
+			continue
+		}
+		ready = append(ready, msg)
+	}
+	if len(expired) == 0 {
+		return
+	}
+	// 死信可能再次投递到当前队列,先更新队列
+	q.ready = ready
+	for _, msg := range expired {
+		m.deadLetter(q, msg, "expired")
+	}
+}
+
+// requeue 按入队的顺序重新插入队列
+func (m *MemoryChannel) requeue(q *memoryQueue, msg *memoryMessage) {
+	msg.redelivered = true
+	i := sort.Search(len(q.ready), func(i int) bool {
+		return q.ready[i].seq > msg.seq
+	})
+	q.ready = append(q.ready, nil)
+	copy(q.ready[i+1:], q.ready[i:])
+	q.ready[i] = msg
+	m.dispatch(q)
+}
+
+// deadLetter 投递到队列的死信交换机并添加 x-death 头,未配置死信交换机时丢弃
+func (m *MemoryChannel) deadLetter(q *memoryQueue, msg *memoryMessage, reason string) {
+	if !q.deadLetter {
+		return
+	}
+	key := msg.key
+	if q.dlk != "" {
+		key = q.dlk
+	}
+	headers := make(amqp.Table, len(msg.Headers)+4)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	death := amqp.Table{
+		"count":        int64(1),
+		"reason":       reason,
+		"queue":        q.name,
+		"time":         time.Now(),
+		"exchange":     msg.exchange,
+		"routing-keys": []interface{}{msg.key},
+	}
+	deaths, _ := headers["x-death"].([]interface{})
+	list := make([]interface{}, 0, len(deaths)+1)
+	for _, v := range deaths {
+		// 相同队列及原因的记录累加次数并移到最前
+		if t, ok := v.(amqp.Table); ok && t["queue"] == q.name && t["reason"] == reason {
+			count, _ := t["count"].(int64)
+			death["count"] = count + 1
+			continue
+		}
+		list = append(list, v)
+	}
+	headers["x-death"] = append([]interface{}{death}, list...)
+	if _, ok := headers["x-first-death-reason"]; !ok {
+		headers["x-first-death-reason"] = reason
+		headers["x-first-death-queue"] = q.name
+		headers["x-first-death-exchange"] = msg.exchange
+	}
+	publishing := msg.Publishing
+	publishing.Headers = headers
+	// 避免在死信队列中再次过期
+	publishing.Expiration = ""
+	// 死信交换机不存在时丢弃
+	_ = m.route(q.dlx, key, publishing)
+}
+
+// dispatch 将就绪的消息轮询分配给未达到预取数量的消费者
+func (m *MemoryChannel) dispatch(q *memoryQueue) {
+	m.expire(q)
+	for len(q.ready) > 0 {
+		c := m.nextConsumer(q)
+		if c == nil {
+			break
+		}
+		msg := q.ready[0]
+		q.ready[0] = nil
+		q.ready = q.ready[1:]
+		m.tag++
+		m.unacked[m.tag] = &memoryUnacked{queue: q, consumer: c, message: msg}
+		if !c.autoAck {
+			c.unacked++
+		}
+		c.buffer = append(c.buffer, m.delivery(msg, m.tag, c.tag))
+	}
+	m.cond.Broadcast()
+}
+
+func (m *MemoryChannel) nextConsumer(q *memoryQueue) *memoryConsumer {
+	for i := 0; i < len(q.consumers); i++ {
+		c := q.consumers[(q.next+i)%len(q.consumers)]
+		if c.autoAck || m.qos == nil || m.qos.PrefetchCount <= 0 || c.unacked < m.qos.PrefetchCount {
+			q.next = (q.next + i + 1) % len(q.consumers)
+			return c
+		}
+	}
+	return nil
+}
+
+func (m *MemoryChannel) delivery(msg *memoryMessage, tag uint64, consumer string) amqp.Delivery {
+	return amqp.Delivery{
+		Acknowledger:    memoryAcknowledger{m},
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		ConsumerTag:     consumer,
+		DeliveryTag:     tag,
+		Redelivered:     msg.redelivered,
+		Exchange:        msg.exchange,
+		RoutingKey:      msg.key,
+		Body:            msg.Body,
+	}
+}
+
+// deliver 将分配的消息依次转发给消费者,取消后未转发的消息重新入队
+func (m *MemoryChannel) deliver(c *memoryConsumer) {
+	defer close(c.deliveries)
+	for {
+		m.mu.Lock()
+		for len(c.buffer) == 0 && !c.canceled {
+			m.cond.Wait()
+		}
+		if c.canceled {
+			m.mu.Unlock()
+			return
+		}
+		d := c.buffer[0]
+		c.buffer = c.buffer[1:]
+		m.mu.Unlock()
+		select {
+		case c.deliveries <- d:
+			if c.autoAck {
+				m.mu.Lock()
+				delete(m.unacked, d.DeliveryTag)
+				m.mu.Unlock()
+			}
+		case <-c.done:
+			m.mu.Lock()
+			_ = m.settle(d.DeliveryTag, false, false, true)
+			m.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (m *MemoryChannel) cancel(c *memoryConsumer) {
+	c.canceled = true
+	close(c.done)
+	delete(m.consumers, c.tag)
+	q := c.queue
+	for i := range q.consumers {
+		if q.consumers[i] == c {
+			q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+			break
+		}
+	}
+	if len(q.consumers) > 0 {
+		q.next %= len(q.consumers)
+	} else {
+		q.next = 0
+	}
+	buffer := c.buffer
+	c.buffer = nil
+	for i := range buffer {
+		_ = m.settle(buffer[i].DeliveryTag, false, false, true)
+	}
+	m.cond.Broadcast()
+}
+
+// settle 确认或拒绝消息,拒绝时requeue为true重新入队,否则按死信处理。
+// multiple为true时同时处理同一消费者序号更小的消息
+func (m *MemoryChannel) settle(tag uint64, multiple, ack, requeue bool) error {
+	u, ok := m.unacked[tag]
+	if !ok {
+		return fmt.Errorf("unknown delivery tag %d", tag)
+	}
+	tags := []uint64{tag}
+	if multiple {
+		for t, v := range m.unacked {
+			if t < tag && v.consumer == u.consumer {
+				tags = append(tags, t)
+			}
+		}
+		sort.Slice(tags, func(i, j int) bool {
+			return tags[i] < tags[j]
+		})
+	}
+	for _, t := range tags {
+		v := m.unacked[t]
+		delete(m.unacked, t)
+		if v.consumer != nil && !v.consumer.autoAck {
+			v.consumer.unacked--
+		}
+		switch {
+		case ack:
+		case requeue:
+			m.requeue(v.queue, v.message)
+		default:
+			m.deadLetter(v.queue, v.message, "rejected")
+		}
+		// 消费者的预取数量减少后继续分配
+		m.dispatch(v.queue)
+	}
+	return nil
+}
+
+// milliseconds 解析毫秒数的参数
+func milliseconds(v interface{}) (time.Duration, bool) {
+	var ms int64
+	switch n := v.(type) {
+	case int:
+		ms = int64(n)
+	case int32:
+		ms = int64(n)
+	case int64:
+		ms = n
+	case float64:
+		ms = int64(n)
+	default:
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+type memoryAcknowledger struct {
+	m *MemoryChannel
+}
+
+func (a memoryAcknowledger) Ack(tag uint64, multiple bool) error {
+	return a.settle(tag, multiple, true, false)
+}
+
+func (a memoryAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return a.settle(tag, multiple, false, requeue)
+}
+
+func (a memoryAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.settle(tag, false, false, requeue)
+}
+
+func (a memoryAcknowledger) settle(tag uint64, multiple, ack, requeue bool) error {
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+	if a.m.closed {
+		return ErrChannelClosed
+	}
+	return a.m.settle(tag, multiple, ack, requeue)
+}
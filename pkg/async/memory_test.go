@@ -0,0 +1,323 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not satisfied before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func receive(t *testing.T, deliveries <-chan amqp.Delivery) amqp.Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			t.Fatal("deliveries closed")
+		}
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no delivery")
+	}
+	return amqp.Delivery{}
+}
+
+func queueLength(t *testing.T, c *MemoryChannel, queue string) int {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	q, ok := c.queues[queue]
+	if !ok {
+		t.Fatalf("queue %s not found", queue)
+	}
+	return len(q.ready)
+}
+
+func TestMemoryChannel_route(t *testing.T) {
+	c := NewMemoryChannel()
+	defer c.Close()
+	for _, b := range []struct{ exchange, kind, queue, key string }{
+		{"direct", "direct", "woden", "msg.woden"},
+		{"direct", "direct", "rudder", "msg.rudder"},
+		{"fanout", "fanout", "woden", "any"},
+		{"fanout", "fanout", "rudder", "any"},
+		{"topic", "topic", "woden", "msg.*.woden"},
+		{"topic", "topic", "rudder", "msg.#"},
+	} {
+		if err := c.DeclareAndBind(b.exchange, b.kind, b.queue, b.key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.DeclareAndBind("direct", "topic", "woden", "msg.woden"); err == nil {
+		t.Fatal("expected error for redeclare with different kind")
+	}
+	for _, p := range []struct{ exchange, key string }{
+		{"direct", "msg.woden"},
+		{"direct", "msg.unknown"},
+		{"fanout", "ignored"},
+		{"topic", "msg.dcs.woden"},
+		{"topic", "msg"},
+		// 默认交换机按队列名称投递
+		{"", "rudder"},
+	} {
+		if err := c.Publish(p.exchange, p.key, false, false, amqp.Publishing{Body: []byte(p.exchange + ":" + p.key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Publish("unknown", "key", false, false, amqp.Publishing{}); err == nil {
+		t.Fatal("expected error for unknown exchange")
+	}
+	for queue, want := range map[string][]string{
+		"woden":  {"direct:msg.woden", "fanout:ignored", "topic:msg.dcs.woden"},
+		"rudder": {"fanout:ignored", "topic:msg.dcs.woden", "topic:msg", ":rudder"},
+	} {
+		for _, body := range want {
+			d, ok, err := c.Get(queue, true)
+			if err != nil || !ok {
+				t.Fatalf("get %s failed %v", queue, err)
+			}
+			if string(d.Body) != body {
+				t.Fatalf("queue %s got %s, want %s", queue, d.Body, body)
+			}
+		}
+		if _, ok, _ := c.Get(queue, true); ok {
+			t.Fatalf("unexpected message in %s", queue)
+		}
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, key string
+		match        bool
+	}{
+		{"msg.*", "msg.woden", true},
+		{"msg.*", "msg.dcs.woden", false},
+		{"msg.#", "msg", true},
+		{"msg.#.woden", "msg.a.b.woden", true},
+		{"#", "msg.dcs", true},
+		{"*.woden", "woden", false},
+	} {
+		if got := matchRoutingKey("topic", tt.pattern, tt.key); got != tt.match {
+			t.Errorf("pattern %s key %s: got %v", tt.pattern, tt.key, got)
+		}
+	}
+}
+
+func TestMemoryChannel_prefetch(t *testing.T) {
+	c := NewMemoryChannel(WithQos(QosOption{PrefetchCount: 1}))
+	defer c.Close()
+	if err := c.DeclareAndBind("direct", "direct", "woden", "woden"); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"1", "2"} {
+		if err := c.Publish("direct", "woden", false, false, amqp.Publishing{Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deliveries, err := c.Consume("woden", "ctag.woden", false, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := receive(t, deliveries)
+	// 达到预取数量后不再投递
+	select {
+	case d := <-deliveries:
+		t.Fatalf("unexpected delivery %s", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err = first.Ack(false); err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, deliveries); string(d.Body) != "2" {
+		t.Fatalf("unexpected delivery %s", d.Body)
+	}
+	if err = first.Ack(false); err == nil {
+		t.Fatal("expected error for acked delivery tag")
+	}
+	if err = c.Cancel("ctag.woden"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-deliveries; ok {
+		t.Fatal("deliveries should be closed after cancel")
+	}
+}
+
+func TestMemoryChannel_requeue(t *testing.T) {
+	c := NewMemoryChannel()
+	defer c.Close()
+	if err := c.DeclareAndBind("direct", "direct", "woden", "woden"); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"1", "2", "3"} {
+		if err := c.Publish("direct", "woden", false, false, amqp.Publishing{Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, _, _ := c.Get("woden", false)
+	second, _, _ := c.Get("woden", false)
+	if first.MessageCount != 2 || second.MessageCount != 1 {
+		t.Fatalf("unexpected message count %d, %d", first.MessageCount, second.MessageCount)
+	}
+	// 重新入队后保持原来的顺序
+	if err := first.Nack(false, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Nack(false, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"1", "2", "3"} {
+		d, ok, err := c.Get("woden", false)
+		if err != nil || !ok || string(d.Body) != body || d.Redelivered != (body != "3") {
+			t.Fatalf("unexpected delivery %s, redelivered %v, %v", d.Body, d.Redelivered, err)
+		}
+		if body == "3" {
+			// 批量确认同一消费者之前的消息
+			if err = d.Ack(true); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(c.unacked) != 0 {
+		t.Fatalf("unexpected unacked %d", len(c.unacked))
+	}
+	if n, err := c.Purge("woden"); err != nil || n != 0 {
+		t.Fatalf("unexpected purge %d, %v", n, err)
+	}
+}
+
+func TestMemoryChannel_deadLetter(t *testing.T) {
+	c := NewMemoryChannel()
+	defer c.Close()
+	if err := c.DeclareAndBind("direct", "direct", "woden", "woden", nil, map[string]interface{}{
+		"x-dead-letter-exchange": "dlx",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeclareAndBind("dlx", "direct", "woden.dlq", "woden"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish("direct", "woden", false, false, amqp.Publishing{Body: []byte("rejected")},
+		amqp.Publishing{Body: []byte("expired"), Expiration: "10"}); err != nil {
+		t.Fatal(err)
+	}
+	d, _, _ := c.Get("woden", false)
+	if err := d.Reject(false); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool {
+		return queueLength(t, c, "woden.dlq") == 2
+	})
+	for _, want := range []string{"rejected", "expired"} {
+		d, _, _ = c.Get("woden.dlq", true)
+		deaths, _ := d.Headers["x-death"].([]interface{})
+		if string(d.Body) != want || len(deaths) != 1 || d.Headers["x-first-death-reason"] != want ||
+			deaths[0].(amqp.Table)["queue"] != "woden" || d.Expiration != "" {
+			t.Fatalf("unexpected dead letter %s %v", d.Body, d.Headers)
+		}
+	}
+}
+
+func TestMemoryChannel_delay(t *testing.T) {
+	ctx := context.Background()
+	for _, mode := range []DelayMode{DelayTTL, DelayPlugin} {
+		c := NewMemoryChannel()
+		p := NewDelayedProducer(NewTaskProducer(), WithDelayMode(mode), WithDelayPrecision(time.Millisecond))
+		if err := p.Bind(c, "dcs.api.async", "direct", "woden", "msg.woden"); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		if err := p.PublishDelay(ctx, c, "dcs.api.async", "msg.woden", 30*time.Millisecond,
+			&Param{TaskType: "test"}); err != nil {
+			t.Fatal(err)
+		}
+		if queueLength(t, c, "woden") != 0 {
+			t.Fatalf("mode %v: message delivered before delay", mode)
+		}
+		eventually(t, func() bool {
+			return queueLength(t, c, "woden") == 1
+		})
+		if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+			t.Fatalf("mode %v: delivered after %s", mode, elapsed)
+		}
+		_ = c.Close()
+	}
+}
+
+type flakyHandler struct {
+	failures int32
+	runs     int32
+}
+
+func (f *flakyHandler) Name() string {
+	return "flaky"
+}
+
+func (f *flakyHandler) Run(ctx context.Context, param *Param) error {
+	if atomic.AddInt32(&f.runs, 1) <= f.failures {
+		return errors.New("flaky")
+	}
+	return nil
+}
+
+func TestMemoryChannel_taskFlow(t *testing.T) {
+	c := NewMemoryChannel()
+	defer c.Close()
+	queue := "dcs.woden"
+	if err := c.DeclareAndBind("dcs.api.async", "direct", queue, "msg.dcs.woden"); err != nil {
+		t.Fatal(err)
+	}
+	flaky := &flakyHandler{failures: 2}
+	tc := NewTaskConsumer(WithAck(false), WithDeadLetter("dlx"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialInterval: 10 * time.Millisecond}))
+	tc.Register(flaky, failHandler{policy: RetryPolicy{MaxAttempts: 2, InitialInterval: 10 * time.Millisecond}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- tc.Subscribe(ctx, c, queue)
+	}()
+	tp := NewTaskProducer()
+	for _, taskType := range []string{"flaky", "fail"} {
+		if err := tp.Publish(ctx, c, "dcs.api.async", "msg.dcs.woden", &Param{TaskType: taskType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 失败的消息经重试队列重新投递,成功后不再重试
+	eventually(t, func() bool {
+		return atomic.LoadInt32(&flaky.runs) == 3
+	})
+	// 重试耗尽的消息投递到死信队列
+	manager := NewDeadLetterManager(c, []string{queue})
+	var letters []*DeadLetter
+	eventually(t, func() bool {
+		var err error
+		letters, err = manager.List(queue, 10)
+		return err == nil && len(letters) == 1
+	})
+	if l := letters[0]; l.Param.TaskType != "fail" || l.Attempts != 2 || l.Reason != "boom" ||
+		l.Exchange != "dcs.api.async" || l.RoutingKey != "msg.dcs.woden" {
+		t.Fatalf("unexpected dead letter %+v", l)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&flaky.runs) != 3 {
+		t.Fatalf("unexpected runs %d", flaky.runs)
+	}
+}
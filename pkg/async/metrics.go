@@ -0,0 +1,26 @@
+package async
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	connectionState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "async_rabbitmq_connection_state",
+		Help: "rabbitmq connection state, 1 for connected and 0 for disconnected.",
+	}, []string{"name"})
+	reconnectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "async_rabbitmq_reconnects_total",
+		Help: "rabbitmq reconnections counter.",
+	}, []string{"name"})
+)
+
+// RegisterMetrics 注册rabbitmq连接的指标
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{connectionState, reconnectsTotal} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
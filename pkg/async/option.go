@@ -5,6 +5,7 @@ import (
 
 	jsoniter "github.com/json-iterator/go"
 
+	"template/pkg/idempotent"
 	"template/pkg/logger/gormx"
 	"template/pkg/validator"
 )
@@ -17,6 +18,9 @@ type option struct {
 	autoAck   bool
 	uuid      func(ctx context.Context) string
 	form      func(ctx context.Context) gormx.Logger
+	retry     RetryPolicy
+	dlx       string
+	guard     *idempotent.Guard
 }
 
 type Option func(*option)
@@ -62,3 +66,25 @@ func WithLogFrom(from func(context.Context) gormx.Logger) Option {
 		o.form = from
 	}
 }
+
+// WithRetryPolicy 默认的重试策略,TaskHandler 实现 RetryPolicyHandler 时使用其策略
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *option) {
+		o.retry = policy
+	}
+}
+
+// WithDeadLetter 重试耗尽或无法解析的消息投递到该死信交换机,路由为原队列名称
+func WithDeadLetter(exchange string) Option {
+	return func(o *option) {
+		o.dlx = exchange
+	}
+}
+
+// WithIdempotent 相同uuid的消息仅执行一次,重复投递的消息直接确认。
+// 正在其他消费者中执行的消息按执行失败处理,稍后重试
+func WithIdempotent(guard *idempotent.Guard) Option {
+	return func(o *option) {
+		o.guard = guard
+	}
+}
@@ -0,0 +1,246 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/multierr"
+
+	"template/pkg/logger/gormx"
+)
+
+type OutboxState string
+
+const (
+	// OutboxPending 待发送
+	OutboxPending OutboxState = "pending"
+	// OutboxSent 已发送
+	OutboxSent OutboxState = "sent"
+	// OutboxFailed 无法解析或超过最大发送次数,不再发送
+	OutboxFailed OutboxState = "failed"
+)
+
+const (
+	defaultRelayBatch       = 100
+	defaultRelayMaxAttempts = 10
+	defaultRelayRetention   = 7 * 24 * time.Hour
+	defaultRelayLease       = 5 * time.Minute
+)
+
+// OutboxMessage 发件箱中的消息,与业务数据在同一事务中写入,由 OutboxRelay 发布
+type OutboxMessage struct {
+	ID uint64 `json:"id" gorm:"column:id;primaryKey;autoIncrement;comment:消息ID"`
+	// 消息的uuid,重复发布时保持不变,消费者可用于去重
+	UUID       string      `json:"uuid" gorm:"column:uuid;size:64;comment:消息uuid"`
+	Exchange   string      `json:"exchange" gorm:"column:exchange;comment:交换机"`
+	RoutingKey string      `json:"routing_key" gorm:"column:routing_key;comment:路由"`
+	Payload    []byte      `json:"payload" gorm:"column:payload;comment:任务参数"`
+	State      OutboxState `json:"state" gorm:"column:state;comment:发送状态"`
+	Attempts   int         `json:"attempts" gorm:"column:attempts;comment:发送失败的次数"`
+	LastError  string      `json:"last_error" gorm:"column:last_error;comment:最近一次发送失败的原因"`
+	SentAt     *time.Time  `json:"sent_at" gorm:"column:sent_at;comment:发送时间"`
+	// 领取该消息的发布实例,租约过期前其他实例不发布
+	Owner      string    `json:"owner" gorm:"column:owner;size:64;comment:发布实例"`
+	LeaseUntil time.Time `json:"lease_until" gorm:"column:lease_until;comment:租约过期时间"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;comment:创建时间"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"column:updated_at;comment:更新时间"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "async_outbox"
+}
+
+// NewOutboxMessage 创建待发送的消息
+func NewOutboxMessage(exchange, routingKey string, param *Param) (*OutboxMessage, error) {
+	if param == nil || param.TaskType == "" {
+		return nil, errors.New("outbox param task type is required")
+	}
+	payload, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(param)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxMessage{
+		UUID:       watermill.NewUUID(),
+		Exchange:   exchange,
+		RoutingKey: routingKey,
+		Payload:    payload,
+		State:      OutboxPending,
+	}, nil
+}
+
+// OutboxStore 发件箱的持久化存储
+type OutboxStore interface {
+	// Add 保存待发送的消息,需使用业务数据所在事务创建的存储
+	Add(ctx context.Context, messages ...*OutboxMessage) error
+	// ClaimPending 按写入顺序领取待发送的消息,领取未被其他实例持有或租约在now之前过期的消息,
+	// 租约持有至until。需从主库读取,避免读到已发送的消息
+	ClaimPending(ctx context.Context, owner string, limit int, now, until time.Time) ([]*OutboxMessage, error)
+	// Update 更新消息的发送状态
+	Update(ctx context.Context, message *OutboxMessage) error
+	// DeleteSent 删除发送时间早于before的消息,返回删除的数量
+	DeleteSent(ctx context.Context, before time.Time) (int64, error)
+}
+
+type relayOption struct {
+	batch       int
+	maxAttempts int
+	retention   time.Duration
+	lease       time.Duration
+	from        func(context.Context) gormx.Logger
+}
+
+type RelayOption func(*relayOption)
+
+// WithRelayBatch 每次从发件箱获取的消息数量,默认100
+func WithRelayBatch(batch int) RelayOption {
+	return func(o *relayOption) {
+		o.batch = batch
+	}
+}
+
+// WithRelayMaxAttempts 消息最多发送的次数,超过后标记为失败,不大于0时不限制,默认10
+func WithRelayMaxAttempts(attempts int) RelayOption {
+	return func(o *relayOption) {
+		o.maxAttempts = attempts
+	}
+}
+
+// WithRelayRetention 已发送的消息保留的时间,不大于0时不删除,默认7天
+func WithRelayRetention(retention time.Duration) RelayOption {
+	return func(o *relayOption) {
+		o.retention = retention
+	}
+}
+
+// WithRelayLease 领取的消息的租约时长,需大于发布一批消息的时间,默认5分钟
+func WithRelayLease(lease time.Duration) RelayOption {
+	return func(o *relayOption) {
+		o.lease = lease
+	}
+}
+
+func WithRelayLogFrom(from func(context.Context) gormx.Logger) RelayOption {
+	return func(o *relayOption) {
+		o.from = from
+	}
+}
+
+type outboxUUIDKey struct{}
+
+// outboxUUID 发布发件箱消息时使用消息的uuid
+func outboxUUID(ctx context.Context) string {
+	if uuid, ok := ctx.Value(outboxUUIDKey{}).(string); ok && uuid != "" {
+		return uuid
+	}
+	return watermill.NewUUID()
+}
+
+// OutboxRelay 将发件箱中待发送的消息按写入顺序发布,实现 job.Job,由定时任务周期执行。
+// 发布成功但标记失败时消息会重复发布,即至少一次投递。
+// 消息发布前需先领取,多个实例同时执行时每条消息只由一个实例发布
+type OutboxRelay struct {
+	relayOption
+	owner    string
+	store    OutboxStore
+	channel  Channel
+	producer Producer
+	handler  jsoniter.API
+}
+
+// NewOutboxRelay 创建发件箱的发布任务,channel 建议开启 WithConfirm
+func NewOutboxRelay(store OutboxStore, channel Channel, opts ...RelayOption) *OutboxRelay {
+	o := relayOption{
+		batch:       defaultRelayBatch,
+		maxAttempts: defaultRelayMaxAttempts,
+		retention:   defaultRelayRetention,
+		lease:       defaultRelayLease,
+		from:        gormx.Nop,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.batch <= 0 {
+		o.batch = defaultRelayBatch
+	}
+	if o.lease <= 0 {
+		o.lease = defaultRelayLease
+	}
+	return &OutboxRelay{
+		relayOption: o,
+		owner:       watermill.NewUUID(),
+		store:       store,
+		channel:     channel,
+		producer:    NewTaskProducer(WithUuid(outboxUUID)),
+		handler:     jsoniter.ConfigCompatibleWithStandardLibrary,
+	}
+}
+
+func (r *OutboxRelay) Description() string {
+	return "relay pending outbox messages to rabbitmq"
+}
+
+// Key returns the unique key for the Job.
+func (r *OutboxRelay) Key() string {
+	return "async.outboxRelay"
+}
+
+// Execute is called by a SchedulerRuntime when the Trigger associated with this job fires.
+func (r *OutboxRelay) Execute(ctx context.Context) {
+	if _, err := r.Relay(ctx); err != nil {
+		r.from(ctx).Errorf("relay outbox failed,err:%+v", err)
+	}
+}
+
+// Relay 发布待发送的消息,发布失败时停止以保证顺序,返回发布的数量
+func (r *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		now := time.Now()
+		list, err := r.store.ClaimPending(ctx, r.owner, r.batch, now, now.Add(r.lease))
+		if err != nil {
+			return sent, err
+		}
+		for _, m := range list {
+			if err = r.publish(ctx, m); err != nil {
+				return sent, err
+			}
+			if m.State == OutboxSent {
+				sent++
+			}
+		}
+		if len(list) < r.batch {
+			break
+		}
+	}
+	if r.retention > 0 {
+		if _, err := r.store.DeleteSent(ctx, time.Now().Add(-r.retention)); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, m *OutboxMessage) error {
+	param := Get()
+	defer Put(param)
+	if err := r.handler.Unmarshal(m.Payload, param); err != nil {
+		// 无法解析的消息重试也无法发送,标记为失败后继续发送后续消息
+		m.State, m.LastError = OutboxFailed, truncateReason(err.Error())
+		return r.store.Update(ctx, m)
+	}
+	if err := r.producer.Publish(context.WithValue(ctx, outboxUUIDKey{}, m.UUID),
+		r.channel, m.Exchange, m.RoutingKey, param); err != nil {
+		m.Attempts++
+		m.LastError = truncateReason(err.Error())
+		if r.maxAttempts > 0 && m.Attempts >= r.maxAttempts {
+			m.State = OutboxFailed
+		}
+		return multierr.Append(err, r.store.Update(ctx, m))
+	}
+	now := time.Now()
+	m.State, m.SentAt, m.LastError = OutboxSent, &now, ""
+	return r.store.Update(ctx, m)
+}
@@ -0,0 +1,218 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+)
+
+type memOutboxStore struct {
+	mux      sync.Mutex
+	messages []*OutboxMessage
+}
+
+func (m *memOutboxStore) Add(ctx context.Context, messages ...*OutboxMessage) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for _, msg := range messages {
+		msg.ID = uint64(len(m.messages) + 1)
+		m.messages = append(m.messages, msg)
+	}
+	return nil
+}
+
+func (m *memOutboxStore) ClaimPending(ctx context.Context, owner string, limit int, now, until time.Time) ([]*OutboxMessage, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	list := make([]*OutboxMessage, 0)
+	for _, msg := range m.messages {
+		if msg.State == OutboxPending && (msg.Owner == owner || msg.LeaseUntil.Before(now)) && len(list) < limit {
+			msg.Owner, msg.LeaseUntil = owner, until
+			c := *msg
+			list = append(list, &c)
+		}
+	}
+	return list, nil
+}
+
+func (m *memOutboxStore) Update(ctx context.Context, message *OutboxMessage) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	c := *message
+	m.messages[message.ID-1] = &c
+	return nil
+}
+
+func (m *memOutboxStore) DeleteSent(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	store := &memOutboxStore{}
+	ctx := context.Background()
+	for _, taskType := range []string{"first", "second", "third"} {
+		msg, err := NewOutboxMessage("dcs.api.async", "msg.dcs.woden", &Param{TaskType: taskType})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = store.Add(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := NewOutboxMessage("dcs.api.async", "msg.dcs.woden", &Param{}); err == nil {
+		t.Fatal("expected error for empty task type")
+	}
+
+	cc := NewMockChannel(ctl)
+	uuids := make([]string, 0)
+	publish := func(_, _ string, _, _ bool, msg ...amqp.Publishing) error {
+		uuids = append(uuids, msg[0].Headers[DefaultMessageUUIDHeaderKey].(string))
+		return nil
+	}
+	gomock.InOrder(
+		cc.EXPECT().Publish("dcs.api.async", "msg.dcs.woden", false, false, gomock.Any()).DoAndReturn(publish),
+		// 发布失败时停止,保证顺序
+		cc.EXPECT().Publish("dcs.api.async", "msg.dcs.woden", false, false, gomock.Any()).
+			Return(errors.New("broker down")),
+		cc.EXPECT().Publish("dcs.api.async", "msg.dcs.woden", false, false, gomock.Any()).
+			DoAndReturn(publish).Times(2),
+	)
+
+	relay := NewOutboxRelay(store, cc, WithRelayBatch(2))
+	sent, err := relay.Relay(ctx)
+	if err == nil || sent != 1 {
+		t.Fatalf("expected publish failure after 1 message, sent %d, err %v", sent, err)
+	}
+	if m := store.messages[1]; m.State != OutboxPending || m.Attempts != 1 || m.LastError != "broker down" {
+		t.Fatalf("unexpected failed message %+v", m)
+	}
+	if sent, err = relay.Relay(ctx); err != nil || sent != 2 {
+		t.Fatalf("unexpected relay %d, %v", sent, err)
+	}
+	for i, m := range store.messages {
+		if m.State != OutboxSent || m.SentAt == nil {
+			t.Fatalf("message %d not sent %+v", i, m)
+		}
+		// 发布时使用发件箱中保存的uuid
+		if uuids[i] != m.UUID {
+			t.Fatalf("message %d uuid %s, want %s", i, uuids[i], m.UUID)
+		}
+	}
+}
+
+func TestOutboxRelay_claimed(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	store := &memOutboxStore{}
+	ctx := context.Background()
+	msg, _ := NewOutboxMessage("dcs.api.async", "msg.dcs.woden", &Param{TaskType: "test"})
+	_ = store.Add(ctx, msg)
+
+	cc := NewMockChannel(ctl)
+	cc.EXPECT().Publish("dcs.api.async", "msg.dcs.woden", false, false, gomock.Any()).
+		Return(errors.New("broker down"))
+	cc.EXPECT().Publish("dcs.api.async", "msg.dcs.woden", false, false, gomock.Any()).Return(nil)
+
+	// 发布失败的消息在租约期间仍由领取的实例持有
+	first := NewOutboxRelay(store, cc, WithRelayLease(time.Hour))
+	if _, err := first.Relay(ctx); err == nil {
+		t.Fatal("expected publish failure")
+	}
+	second := NewOutboxRelay(store, cc)
+	if sent, err := second.Relay(ctx); err != nil || sent != 0 {
+		t.Fatalf("claimed message relayed by another instance %d, %v", sent, err)
+	}
+	// 租约过期后由其他实例发布
+	store.messages[0].LeaseUntil = time.Now().Add(-time.Second)
+	if sent, err := second.Relay(ctx); err != nil || sent != 1 {
+		t.Fatalf("unexpected relay %d, %v", sent, err)
+	}
+}
+
+func TestOutboxRelay_maxAttempts(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	store := &memOutboxStore{}
+	ctx := context.Background()
+	_ = store.Add(ctx, &OutboxMessage{State: OutboxPending, Payload: []byte("poison")})
+	msg, _ := NewOutboxMessage("dcs.api.async", "msg.dcs.woden", &Param{TaskType: "test"})
+	_ = store.Add(ctx, msg)
+
+	cc := NewMockChannel(ctl)
+	cc.EXPECT().Publish("dcs.api.async", "msg.dcs.woden", false, false, gomock.Any()).
+		Return(errors.New("broker down")).Times(2)
+
+	relay := NewOutboxRelay(store, cc, WithRelayMaxAttempts(2))
+	for i := 0; i < 2; i++ {
+		if _, err := relay.Relay(ctx); err == nil {
+			t.Fatal("expected publish failure")
+		}
+	}
+	// 无法解析及超过最大次数的消息不再发送
+	if sent, err := relay.Relay(ctx); err != nil || sent != 0 {
+		t.Fatalf("unexpected relay %d, %v", sent, err)
+	}
+	if store.messages[0].State != OutboxFailed || store.messages[1].State != OutboxFailed {
+		t.Fatalf("unexpected states %s, %s", store.messages[0].State, store.messages[1].State)
+	}
+}
+
+// nackChannel 按rabbitmqChannel的退避策略重试,broker始终拒绝确认
+type nackChannel struct {
+	Channel
+	r         *rabbitmqChannel
+	publishes int
+}
+
+func (n *nackChannel) Publish(string, string, bool, bool, ...amqp.Publishing) error {
+	return backoff.Retry(func() error {
+		n.publishes++
+		return ErrPublishNack
+	}, n.r.newBackOff())
+}
+
+func TestOutboxRelay_nack(t *testing.T) {
+	ctx := context.Background()
+	for _, attempts := range []int{0, 1, 3} {
+		store := &memOutboxStore{}
+		msg, _ := NewOutboxMessage("dcs.api.async", "msg.dcs.woden", &Param{TaskType: "test"})
+		_ = store.Add(ctx, msg)
+		r := newTestRabbitmqChannel()
+		r.attempts, r.interval = attempts, time.Millisecond
+		cc := &nackChannel{r: r}
+
+		relay := NewOutboxRelay(store, cc)
+		done := make(chan error, 1)
+		go func() {
+			_, err := relay.Relay(ctx)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrPublishNack) {
+				t.Fatalf("attempts %d: expected nack, got %v", attempts, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("attempts %d: relay blocked by nack", attempts)
+		}
+		// 每次发送最多尝试attempts次,失败的消息在下次发送时重试
+		want := attempts
+		if want < 1 {
+			want = 1
+		}
+		if cc.publishes != want || store.messages[0].Attempts != 1 || store.messages[0].State != OutboxPending {
+			t.Fatalf("attempts %d: unexpected publishes %d, message %+v", attempts, cc.publishes, store.messages[0])
+		}
+	}
+}
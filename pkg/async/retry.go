@@ -0,0 +1,170 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// RetryAttemptsHeader 消息已失败的次数
+	RetryAttemptsHeader = "x-retry-attempts"
+	// FailureReasonHeader 投递到死信交换机的失败原因
+	FailureReasonHeader = "x-failure-reason"
+	// FailedAtHeader 投递到死信交换机的时间
+	FailedAtHeader = "x-failed-at"
+	// OriginalExchangeHeader 第一次失败时消息的交换机
+	OriginalExchangeHeader = "x-original-exchange"
+	// OriginalRoutingKeyHeader 第一次失败时消息的路由
+	OriginalRoutingKeyHeader = "x-original-routing-key"
+
+	defaultRetryInterval   = time.Second
+	defaultRetryMultiplier = 2
+	// maxReasonLength 失败原因的最大长度
+	maxReasonLength = 1024
+)
+
+// RetryPolicy 任务处理失败后的重试策略,失败的消息经过带TTL的重试队列后重新投递到原队列
+type RetryPolicy struct {
+	// 最多执行的次数,包含第一次,不大于1时不重试
+	MaxAttempts int
+	// 第一次重试的间隔,默认1s
+	InitialInterval time.Duration
+	// 间隔的增长倍数,默认2
+	Multiplier float64
+	// 最大间隔,为0时不限制
+	MaxInterval time.Duration
+}
+
+// RetryPolicyHandler 指定重试策略的 TaskHandler
+type RetryPolicyHandler interface {
+	TaskHandler
+	RetryPolicy() RetryPolicy
+}
+
+// Backoff 第 attempt 次失败后的重试间隔
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	interval := p.InitialInterval
+	if interval <= 0 {
+		interval = defaultRetryInterval
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+	backoff := float64(interval) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && backoff > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+	return time.Duration(backoff).Truncate(time.Millisecond)
+}
+
+// DeadLetterQueue 队列对应的死信队列名称
+func DeadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+func retryExchange(queue string) string {
+	return queue + ".retry"
+}
+
+func retryQueue(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%d", queue, delay.Milliseconds())
+}
+
+func retryAttempts(d *amqp.Delivery) int {
+	v, _ := d.Headers[RetryAttemptsHeader].(string)
+	attempts, _ := strconv.Atoi(v)
+	return attempts
+}
+
+// republish 复制消息用于重新投递,去除rabbitmq添加的死信头
+func republish(d *amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	table := make(amqp.Table, len(d.Headers)+len(headers))
+	for k, v := range d.Headers {
+		if k == "x-death" || strings.HasPrefix(k, "x-first-death") || strings.HasPrefix(k, "x-last-death") {
+			continue
+		}
+		table[k] = v
+	}
+	for k, v := range headers {
+		table[k] = v
+	}
+	return amqp.Publishing{
+		Headers:         table,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
+
+func (t *taskConsumer) policy(taskType string) RetryPolicy {
+	if v, ok := t.policies.Load(taskType); ok {
+		return v.(RetryPolicy)
+	}
+	return t.retry
+}
+
+// fail 处理失败的消息,未超过重试次数时投递到重试队列,否则投递到死信交换机。
+// retryable为false时不重试,handled为false表示未配置重试及死信,由调用方处理
+func (t *taskConsumer) fail(channel Channel, queue string, d *amqp.Delivery, taskType string,
+	cause error, retryable bool) (handled bool, err error) {
+	attempts := retryAttempts(d) + 1
+	policy := t.policy(taskType)
+	headers := amqp.Table{RetryAttemptsHeader: strconv.Itoa(attempts)}
+	if _, ok := d.Headers[OriginalExchangeHeader]; !ok {
+		headers[OriginalExchangeHeader] = d.Exchange
+		headers[OriginalRoutingKeyHeader] = d.RoutingKey
+	}
+	if retryable && attempts < policy.MaxAttempts {
+		delay := policy.Backoff(attempts)
+		rq := retryQueue(queue, delay)
+		// 重试队列的消息过期后通过默认交换机投递到原队列
+		if err = t.queues.declare(channel, retryExchange(queue), rq, "", queue, delay); err != nil {
+			return true, err
+		}
+		return true, channel.Publish(retryExchange(queue), rq, false, false, republish(d, headers))
+	}
+	if t.dlx == "" {
+		// 重试耗尽且未配置死信时丢弃
+		return retryable && policy.MaxAttempts > 1, nil
+	}
+	headers[FailureReasonHeader] = truncateReason(cause.Error())
+	headers[FailedAtHeader] = time.Now().Format(time.RFC3339)
+	return true, channel.Publish(t.dlx, queue, false, false, republish(d, headers))
+}
+
+// reject 手动确认时处理失败的消息,未配置重试及死信或投递失败时按 requeue 拒绝
+func (t *taskConsumer) reject(ctx context.Context, channel Channel, queue string, d *amqp.Delivery, taskType string,
+	cause error, requeue bool) error {
+	handled, err := t.fail(channel, queue, d, taskType, cause, requeue)
+	if err != nil {
+		t.from(ctx).Errorf("err:%+v", err)
+	}
+	if !handled || err != nil {
+		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
+		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
+		return d.Reject(requeue)
+	}
+	return d.Ack(false)
+}
+
+// truncateReason 截断过长的失败原因
+func truncateReason(reason string) string {
+	if len(reason) > maxReasonLength {
+		return reason[:maxReasonLength]
+	}
+	return reason
+}
@@ -0,0 +1,187 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+)
+
+// recordAck 记录消息的确认结果
+type recordAck struct {
+	acks, nacks, rejects int
+	requeue              bool
+}
+
+func (r *recordAck) Ack(tag uint64, multiple bool) error {
+	r.acks++
+	return nil
+}
+
+func (r *recordAck) Nack(tag uint64, multiple bool, requeue bool) error {
+	r.nacks++
+	r.requeue = requeue
+	return nil
+}
+
+func (r *recordAck) Reject(tag uint64, requeue bool) error {
+	r.rejects++
+	r.requeue = requeue
+	return nil
+}
+
+type failHandler struct {
+	policy RetryPolicy
+}
+
+func (f failHandler) Name() string {
+	return "fail"
+}
+
+func (f failHandler) Run(ctx context.Context, param *Param) error {
+	return errors.New("boom")
+}
+
+func (f failHandler) RetryPolicy() RetryPolicy {
+	return f.policy
+}
+
+func newDelivery(t *testing.T, ack amqp.Acknowledger, param interface{}, headers amqp.Table) amqp.Delivery {
+	data, err := json.Marshal(param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := (DefaultMarshal{}).Marshal(message.NewMessage("uuid", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		msg.Headers[k] = v
+	}
+	return amqp.Delivery{
+		Acknowledger: ack,
+		Headers:      msg.Headers,
+		Body:         msg.Body,
+		Exchange:     "dcs.api.async",
+		RoutingKey:   "msg.dcs.woden",
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialInterval: 100 * time.Millisecond, Multiplier: 3, MaxInterval: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 300 * time.Millisecond,
+		3: 900 * time.Millisecond,
+		4: time.Second,
+	} {
+		if got := p.Backoff(attempt); got != want {
+			t.Errorf("attempt %d: got %s, want %s", attempt, got, want)
+		}
+	}
+	if got := (RetryPolicy{}).Backoff(2); got != 2*time.Second {
+		t.Errorf("default backoff got %s", got)
+	}
+}
+
+func TestTaskConsumer_retry(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	queue := "dcs.woden"
+	cc := NewMockChannel(ctl)
+	cc.EXPECT().DeclareAndBind("dcs.woden.retry", "direct", "dcs.woden.retry.1000", "dcs.woden.retry.1000",
+		gomock.Nil(), map[string]interface{}{
+			"x-message-ttl":             int64(1000),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+			"x-expires":                 int64(601000),
+		}).Return(nil)
+	cc.EXPECT().Publish("dcs.woden.retry", "dcs.woden.retry.1000", false, false, gomock.Any()).
+		DoAndReturn(func(_, _ string, _, _ bool, msg ...amqp.Publishing) error {
+			h := msg[0].Headers
+			if h[RetryAttemptsHeader] != "1" || h[OriginalExchangeHeader] != "dcs.api.async" ||
+				h[OriginalRoutingKeyHeader] != "msg.dcs.woden" {
+				t.Errorf("unexpected retry headers %v", h)
+			}
+			return nil
+		})
+	cc.EXPECT().Publish("dlx", queue, false, false, gomock.Any()).
+		DoAndReturn(func(_, _ string, _, _ bool, msg ...amqp.Publishing) error {
+			h := msg[0].Headers
+			if h[RetryAttemptsHeader] != "3" || h[FailureReasonHeader] != "boom" || h[FailedAtHeader] == nil {
+				t.Errorf("unexpected dead letter headers %v", h)
+			}
+			if _, ok := h["x-death"]; ok {
+				t.Errorf("x-death should be removed")
+			}
+			return nil
+		})
+
+	tc := NewTaskConsumer(WithAck(false), WithDeadLetter("dlx"))
+	tc.Register(failHandler{policy: RetryPolicy{MaxAttempts: 3}})
+	ctx := context.Background()
+	param := &Param{TaskType: "fail"}
+
+	ack := &recordAck{}
+	if err := tc.manualHandle(ctx, cc, queue, newDelivery(t, ack, param, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if ack.acks != 1 || ack.rejects != 0 {
+		t.Fatalf("retried message should be acked, %+v", ack)
+	}
+	// 重试耗尽后投递到死信交换机
+	ack = &recordAck{}
+	d := newDelivery(t, ack, param, amqp.Table{
+		RetryAttemptsHeader:      "2",
+		OriginalExchangeHeader:   "dcs.api.async",
+		OriginalRoutingKeyHeader: "msg.dcs.woden",
+		"x-death":                []interface{}{amqp.Table{"count": int64(1)}},
+	})
+	if err := tc.manualHandle(ctx, cc, queue, d); err != nil {
+		t.Fatal(err)
+	}
+	if ack.acks != 1 || ack.rejects != 0 {
+		t.Fatalf("dead letter should be acked, %+v", ack)
+	}
+}
+
+func TestTaskConsumer_poison(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	cc := NewMockChannel(ctl)
+	// 无法解析的消息不重试,直接投递到死信交换机
+	cc.EXPECT().Publish("dlx", "dcs.woden", false, false, gomock.Any()).Return(nil)
+
+	tc := NewTaskConsumer(WithAck(false), WithDeadLetter("dlx"), WithRetryPolicy(RetryPolicy{MaxAttempts: 5}))
+	ack := &recordAck{}
+	if err := tc.manualHandle(context.Background(), cc, "dcs.woden", newDelivery(t, ack, "poison", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if ack.acks != 1 {
+		t.Fatalf("poison message should be acked, %+v", ack)
+	}
+}
+
+func TestTaskConsumer_requeue(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	// 未配置重试及死信时保持原有的拒绝行为
+	tc := NewTaskConsumer(WithAck(false))
+	tc.Register(failHandler{})
+	ack := &recordAck{}
+	if err := tc.manualHandle(context.Background(), NewMockChannel(ctl), "dcs.woden",
+		newDelivery(t, ack, &Param{TaskType: "fail"}, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if ack.rejects != 1 || !ack.requeue {
+		t.Fatalf("failed message should be requeued, %+v", ack)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"template/pkg/async"
+	e "template/pkg/code"
+	"template/pkg/resp"
+)
+
+// defaultDeadLetterLimit 默认获取的死信数量
+const defaultDeadLetterLimit = 20
+
+// DeadLetterListReq 获取死信列表的参数
+type DeadLetterListReq struct {
+	// 获取的数量,默认为20
+	// in: query
+	Limit int `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// DeadLetterResult 重新投递或清除死信的结果
+type DeadLetterResult struct {
+	Count int `json:"count"`
+}
+
+// RegisterAPI 注册死信管理接口
+func RegisterAPI(router gin.IRouter, manager *async.DeadLetterManager) {
+	api := &deadLetterAPI{manager: manager}
+	g := router.Group("/dead-letters")
+	g.GET("", api.ListQueues)
+	g.GET("/:queue/messages", api.ListDeadLetters)
+	g.GET("/:queue/messages/:id", api.GetDeadLetter)
+	g.POST("/:queue/replay", api.ReplayDeadLetters)
+	g.POST("/:queue/messages/:id/replay", api.ReplayDeadLetters)
+	g.DELETE("/:queue/messages", api.PurgeDeadLetters)
+	g.DELETE("/:queue/messages/:id", api.PurgeDeadLetters)
+}
+
+type deadLetterAPI struct {
+	manager *async.DeadLetterManager
+}
+
+func (a *deadLetterAPI) ListQueues(c *gin.Context) {
+	queues := a.manager.Queues()
+	resp.List(c, queues, 1, len(queues), int64(len(queues)))
+}
+
+func (a *deadLetterAPI) ListDeadLetters(c *gin.Context) {
+	var req DeadLetterListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.ErrorParam(c, err)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultDeadLetterLimit
+	}
+	letters, err := a.manager.List(c.Param("queue"), req.Limit)
+	if err != nil {
+		resp.Error(c, a.convertError(err))
+		return
+	}
+	resp.List(c, letters, 1, req.Limit, int64(len(letters)))
+}
+
+func (a *deadLetterAPI) GetDeadLetter(c *gin.Context) {
+	letter, err := a.manager.Get(c.Param("queue"), c.Param("id"))
+	if err != nil {
+		resp.Error(c, a.convertError(err))
+		return
+	}
+	resp.Success(c, letter)
+}
+
+func (a *deadLetterAPI) ReplayDeadLetters(c *gin.Context) {
+	a.action(c, a.manager.Replay)
+}
+
+func (a *deadLetterAPI) PurgeDeadLetters(c *gin.Context) {
+	a.action(c, a.manager.Purge)
+}
+
+// action 处理单个或全部死信,id参数为空时处理全部
+func (a *deadLetterAPI) action(c *gin.Context, f func(queue, id string) (int, error)) {
+	count, err := f(c.Param("queue"), c.Param("id"))
+	if err != nil {
+		resp.Error(c, a.convertError(err))
+		return
+	}
+	resp.Success(c, &DeadLetterResult{Count: count})
+}
+
+func (a *deadLetterAPI) convertError(err error) error {
+	if errors.Is(err, async.ErrDeadLetterQueueNotFound) {
+		return e.ErrCodeNotFound.WithResult("Queue")
+	}
+	if errors.Is(err, async.ErrDeadLetterNotFound) {
+		return e.ErrCodeNotFound.WithResult("DeadLetter")
+	}
+	return err
+}
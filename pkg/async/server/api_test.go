@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"template/pkg/async"
+)
+
+func TestRegisterAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	cc := async.NewMockQueueChannel(ctl)
+	cc.EXPECT().Get("dcs.woden.dlq", false).Return(amqp.Delivery{}, false, nil).AnyTimes()
+	cc.EXPECT().Purge("dcs.woden.dlq").Return(3, nil)
+
+	router := gin.New()
+	RegisterAPI(router, async.NewDeadLetterManager(cc, []string{"dcs.woden"}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dead-letters/dcs.woden/messages?limit=10", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dead-letters/dcs.woden/messages/missing", nil))
+	assert.NotEqual(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dead-letters/unknown/replay", nil))
+	assert.NotEqual(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/dead-letters/dcs.woden/messages", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":3`)
+}
@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"template/pkg/async"
+	"template/pkg/storage"
+)
+
+// NewOutboxStore 基于数据库的发件箱存储,db为事务时消息与业务数据一同提交
+func NewOutboxStore(db *gorm.DB) async.OutboxStore {
+	return &outboxStore{
+		DB: db,
+	}
+}
+
+type outboxStore struct {
+	*gorm.DB
+}
+
+// Add 保存待发送的消息
+func (s *outboxStore) Add(ctx context.Context, messages ...*async.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	if err := s.WithContext(ctx).Create(messages).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ClaimPending 按写入顺序领取待发送的消息,先更新租约再读取领取成功的消息,
+// 多个实例同时领取时只有一个实例更新成功
+func (s *outboxStore) ClaimPending(ctx context.Context, owner string, limit int, now, until time.Time) ([]*async.OutboxMessage, error) {
+	// 从主库读取,从库延迟时会读到已发送的消息
+	db := s.WithContext(storage.ForcePrimary(ctx))
+	var ids []uint64
+	if err := db.Model(&async.OutboxMessage{}).Where("state = ? AND (owner = ? OR lease_until < ?)", async.OutboxPending, owner, now).
+		Order("id").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := db.Model(&async.OutboxMessage{}).
+		Where("id IN ? AND state = ? AND (owner = ? OR lease_until < ?)", ids, async.OutboxPending, owner, now).
+		Updates(map[string]interface{}{"owner": owner, "lease_until": until}).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var list []*async.OutboxMessage
+	if err := db.Where("id IN ? AND state = ? AND owner = ?", ids, async.OutboxPending, owner).
+		Order("id").Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+// Update 更新消息的发送状态
+func (s *outboxStore) Update(ctx context.Context, message *async.OutboxMessage) error {
+	if err := s.WithContext(ctx).Model(message).Select("state", "attempts", "last_error", "sent_at").
+		Updates(message).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// DeleteSent 删除发送时间早于before的消息
+func (s *outboxStore) DeleteSent(ctx context.Context, before time.Time) (int64, error) {
+	result := s.WithContext(ctx).Where("state = ? AND sent_at < ?", async.OutboxSent, before).
+		Delete(&async.OutboxMessage{})
+	if result.Error != nil {
+		return 0, errors.WithStack(result.Error)
+	}
+	return result.RowsAffected, nil
+}
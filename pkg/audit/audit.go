@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"template/pkg/audit/request"
+	"template/pkg/audit/store"
+	e "template/pkg/code"
+	"template/pkg/resp"
+)
+
+type ClientFunc func(context.Context) *gorm.DB
+
+var auditLogStore store.AuditLog
+
+// InitAuditLogDBClient 初始化审计日志查询使用的数据库连接
+func InitAuditLogDBClient(ctx context.Context, f ClientFunc) {
+	auditLogStore = store.NewAuditLogStore(f(ctx))
+}
+
+func RegisterAPI(router *gin.Engine) {
+	router.GET("/audit-logs/:id", GetAuditLog)
+	router.GET("/audit-logs", ListAuditLog)
+}
+
+func GetAuditLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if id == "" {
+		resp.Error(c, e.ErrCodeInvalidParam)
+		return
+	}
+
+	auditLog, err := auditLogStore.Get(ctx, id)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, auditLog)
+}
+
+func ListAuditLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.QueryAuditLogReq
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.ErrorParam(c, err)
+		return
+	}
+	list, err := auditLogStore.List(ctx, &req)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	resp.CursorList(c, list, req.PageSize, req.NextCursor, req.Total)
+}
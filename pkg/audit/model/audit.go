@@ -0,0 +1,38 @@
+package model
+
+import (
+	"strconv"
+	"time"
+
+	"gorm.io/datatypes"
+
+	database "template/pkg/storage"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// AuditLog 数据变更审计日志表
+type AuditLog struct {
+	database.SnowID
+	CreatedAt    time.Time      `json:"created_at" gorm:"column:created_at;not null;comment:创建时间"`
+	ResourceType string         `json:"resource_type" gorm:"column:resource_type;type:varchar(64);not null;index:idx_audit_resource,priority:1;comment:资源类型"` // nolint:lll
+	ResourceID   string         `json:"resource_id" gorm:"column:resource_id;type:varchar(64);not null;index:idx_audit_resource,priority:2;comment:资源ID"`     // nolint:lll
+	Action       string         `json:"action" gorm:"column:action;type:varchar(16);not null;comment:操作类型"`
+	Before       datatypes.JSON `json:"before,omitempty" gorm:"column:before;comment:变更前的值"`
+	After        datatypes.JSON `json:"after,omitempty" gorm:"column:after;comment:变更后的值"`
+	AccountID    string         `json:"account_id" gorm:"column:account_id;type:varchar(64);index:idx_audit_account_id;comment:账户ID"`
+	UserID       string         `json:"user_id" gorm:"column:user_id;type:varchar(64);comment:用户ID"`
+	TraceID      string         `json:"trace_id" gorm:"column:trace_id;type:varchar(64);index:idx_audit_trace_id;comment:追踪ID"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+func (a *AuditLog) PK() string {
+	return strconv.FormatUint(a.ID, 10)
+}
@@ -0,0 +1,330 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"template/pkg/audit/model"
+)
+
+// beforeKey 更新或删除前记录的快照
+const beforeKey = "audit:before"
+
+// Auditable 需要记录审计日志的模型实现该接口,返回资源类型
+type Auditable interface {
+	AuditResourceType() string
+}
+
+var auditableType = reflect.TypeOf((*Auditable)(nil)).Elem()
+
+type Option func(*Plugin)
+
+// WithAccountID 设置从context中获取账户ID的方法
+func WithAccountID(f func(ctx context.Context) string) Option {
+	return func(p *Plugin) {
+		p.getAccountID = f
+	}
+}
+
+// WithUserID 设置从context中获取用户ID的方法
+func WithUserID(f func(ctx context.Context) string) Option {
+	return func(p *Plugin) {
+		p.getUserID = f
+	}
+}
+
+// WithTraceID 设置从context中获取traceID的方法
+func WithTraceID(f func(ctx context.Context) string) Option {
+	return func(p *Plugin) {
+		p.getTraceID = f
+	}
+}
+
+// NewPlugin 审计日志插件,通过 storage.WithPlugins 注册。
+// 对实现了 Auditable 的模型,在创建、更新、删除时于同一事务中写入变更前后的差异,
+// 原生sql及未指定模型的操作不会记录
+func NewPlugin(opts ...Option) *Plugin {
+	p := &Plugin{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+type Plugin struct {
+	getAccountID func(ctx context.Context) string
+	getUserID    func(ctx context.Context) string
+	getTraceID   func(ctx context.Context) string
+}
+
+func (p *Plugin) Name() string {
+	return "Audit"
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("audit:after_create", p.afterCreate); err != nil {
+		return err
+	}
+	updateCallback := db.Callback().Update()
+	if err := updateCallback.Before("gorm:update").Register("audit:before_update", p.before); err != nil {
+		return err
+	}
+	if err := updateCallback.After("gorm:update").Register("audit:after_update", p.afterUpdate); err != nil {
+		return err
+	}
+	deleteCallback := db.Callback().Delete()
+	if err := deleteCallback.Before("gorm:delete").Register("audit:before_delete", p.before); err != nil {
+		return err
+	}
+	return deleteCallback.After("gorm:delete").Register("audit:after_delete", p.afterDelete)
+}
+
+func (p *Plugin) afterCreate(db *gorm.DB) {
+	resourceType, ok := auditable(db)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+	var logs []*model.AuditLog
+	for _, value := range values(db.Statement.ReflectValue) {
+		after, err := json.Marshal(snapshot(db.Statement, value))
+		if err != nil {
+			_ = db.AddError(errors.WithStack(err))
+			return
+		}
+		logs = append(logs, p.newLog(db.Statement.Context, resourceType, primaryKey(db.Statement, value),
+			model.ActionCreate, nil, after))
+	}
+	p.save(db, logs)
+}
+
+// before 查询即将被更新或删除的记录
+func (p *Plugin) before(db *gorm.DB) {
+	if _, ok := auditable(db); !ok || db.Error != nil {
+		return
+	}
+	records, err := p.query(db, func(tx *gorm.DB) *gorm.DB {
+		if c, ok := db.Statement.Clauses["WHERE"]; ok {
+			if where, ok := c.Expression.(clause.Where); ok {
+				tx.Statement.AddClause(where)
+			}
+		}
+		// Model中携带主键时gorm会在执行时追加主键条件
+		if db.Statement.Model != nil {
+			_, queryValues := schema.GetIdentityFieldValuesMap(db.Statement.Context,
+				reflect.ValueOf(db.Statement.Model), db.Statement.Schema.PrimaryFields)
+			column, values := schema.ToQueryValues(db.Statement.Table, db.Statement.Schema.PrimaryFieldDBNames, queryValues)
+			if len(values) > 0 {
+				tx = tx.Where(clause.IN{Column: column, Values: values})
+			}
+		}
+		if _, ok := tx.Statement.Clauses["WHERE"]; !ok && !db.AllowGlobalUpdate {
+			return nil
+		}
+		return tx
+	})
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	db.InstanceSet(beforeKey, records)
+}
+
+func (p *Plugin) afterUpdate(db *gorm.DB) {
+	resourceType, ok := auditable(db)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+	before := p.snapshots(db)
+	if len(before) == 0 {
+		return
+	}
+	ids := make([]interface{}, 0, len(before))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	after, err := p.query(db, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.IN{
+			Column: clause.Column{Table: clause.CurrentTable, Name: db.Statement.Schema.PrioritizedPrimaryField.DBName},
+			Values: ids,
+		})
+	})
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	var logs []*model.AuditLog
+	for id, old := range before {
+		current, ok := after[id]
+		if !ok {
+			continue
+		}
+		oldDiff, newDiff := diff(old, current)
+		if len(newDiff) == 0 {
+			continue
+		}
+		oldData, err := json.Marshal(oldDiff)
+		if err != nil {
+			_ = db.AddError(errors.WithStack(err))
+			return
+		}
+		newData, err := json.Marshal(newDiff)
+		if err != nil {
+			_ = db.AddError(errors.WithStack(err))
+			return
+		}
+		logs = append(logs, p.newLog(db.Statement.Context, resourceType, id, model.ActionUpdate, oldData, newData))
+	}
+	p.save(db, logs)
+}
+
+func (p *Plugin) afterDelete(db *gorm.DB) {
+	resourceType, ok := auditable(db)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+	var logs []*model.AuditLog
+	for id, old := range p.snapshots(db) {
+		data, err := json.Marshal(old)
+		if err != nil {
+			_ = db.AddError(errors.WithStack(err))
+			return
+		}
+		logs = append(logs, p.newLog(db.Statement.Context, resourceType, id, model.ActionDelete, data, nil))
+	}
+	p.save(db, logs)
+}
+
+// snapshots 获取更新或删除前记录的快照,以主键为key
+func (p *Plugin) snapshots(db *gorm.DB) map[string]map[string]interface{} {
+	value, ok := db.InstanceGet(beforeKey)
+	if !ok {
+		return nil
+	}
+	records, _ := value.(map[string]map[string]interface{})
+	return records
+}
+
+// query 在同一连接(事务)中查询记录的快照,build返回nil时不查询
+func (p *Plugin) query(db *gorm.DB, build func(tx *gorm.DB) *gorm.DB) (map[string]map[string]interface{}, error) {
+	tx := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(db.Statement.Table)
+	if tx = build(tx); tx == nil {
+		return nil, nil
+	}
+	if db.Statement.Unscoped {
+		tx = tx.Unscoped()
+	}
+	rows := reflect.New(reflect.SliceOf(reflect.PtrTo(db.Statement.Schema.ModelType)))
+	if err := tx.Find(rows.Interface()).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	records := make(map[string]map[string]interface{}, rows.Elem().Len())
+	for _, value := range values(rows.Elem()) {
+		records[primaryKey(db.Statement, value)] = snapshot(db.Statement, value)
+	}
+	return records, nil
+}
+
+func (p *Plugin) newLog(ctx context.Context, resourceType, resourceID, action string,
+	before, after []byte) *model.AuditLog {
+	log := &model.AuditLog{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Action:       action,
+		Before:       before,
+		After:        after,
+	}
+	if p.getAccountID != nil {
+		log.AccountID = p.getAccountID(ctx)
+	}
+	if p.getUserID != nil {
+		log.UserID = p.getUserID(ctx)
+	}
+	if p.getTraceID != nil {
+		log.TraceID = p.getTraceID(ctx)
+	}
+	return log
+}
+
+// save 与变更在同一事务中写入审计日志,写入失败时变更一并回滚
+func (p *Plugin) save(db *gorm.DB, logs []*model.AuditLog) {
+	if len(logs) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&logs).Error; err != nil {
+		_ = db.AddError(errors.WithStack(err))
+	}
+}
+
+// auditable 判断操作的模型是否需要记录审计日志
+func auditable(db *gorm.DB) (string, bool) {
+	s := db.Statement.Schema
+	if s == nil || s.PrioritizedPrimaryField == nil {
+		return "", false
+	}
+	if !s.ModelType.Implements(auditableType) && !reflect.PtrTo(s.ModelType).Implements(auditableType) {
+		return "", false
+	}
+	return reflect.New(s.ModelType).Interface().(Auditable).AuditResourceType(), true
+}
+
+// values 展开struct或slice为struct列表
+func values(rv reflect.Value) []reflect.Value {
+	rv = reflect.Indirect(rv)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		result := make([]reflect.Value, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if elem := reflect.Indirect(rv.Index(i)); elem.Kind() == reflect.Struct {
+				result = append(result, elem)
+			}
+		}
+		return result
+	case reflect.Struct:
+		return []reflect.Value{rv}
+	default:
+		return nil
+	}
+}
+
+func primaryKey(stmt *gorm.Statement, value reflect.Value) string {
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, value)
+	return fmt.Sprint(id)
+}
+
+// snapshot 记录的列及值,忽略json中隐藏的字段
+func snapshot(stmt *gorm.Statement, value reflect.Value) map[string]interface{} {
+	result := make(map[string]interface{}, len(stmt.Schema.DBNames))
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || field.StructField.Tag.Get("json") == "-" {
+			continue
+		}
+		v, _ := field.ValueOf(stmt.Context, value)
+		result[field.DBName] = v
+	}
+	return result
+}
+
+// diff 返回发生变化的列在变更前后的值,忽略更新时间
+func diff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	oldValues := make(map[string]interface{})
+	newValues := make(map[string]interface{})
+	for column, value := range after {
+		if column == "updated_at" {
+			continue
+		}
+		old := before[column]
+		if reflect.DeepEqual(old, value) {
+			continue
+		}
+		oldValues[column] = old
+		newValues[column] = value
+	}
+	return oldValues, newValues
+}
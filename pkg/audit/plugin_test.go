@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"template/pkg/audit/model"
+	"template/pkg/json"
+	"template/pkg/storage"
+)
+
+type testArea struct {
+	storage.Base
+	AreaName string          `gorm:"column:area_name"`
+	Secret   string          `gorm:"column:secret" json:"-"`
+	Deleted  storage.Deleted `gorm:"column:deleted" json:"-"`
+}
+
+func (testArea) AuditResourceType() string {
+	return "area"
+}
+
+type testSite struct {
+	storage.Base
+	SiteName string `gorm:"column:site_name"`
+}
+
+type userKey struct{}
+
+func TestPlugin(t *testing.T) {
+	ctx := context.WithValue(context.Background(), userKey{}, "user-1")
+	db, err := storage.New(ctx,
+		storage.WithDialect(storage.DialectSQLite),
+		storage.WithDatabase("file:"+t.Name()+"?mode=memory&cache=shared"),
+		storage.WithMaxOpenConn(1),
+		storage.WithPlugins(NewPlugin(
+			WithUserID(func(ctx context.Context) string {
+				userID, _ := ctx.Value(userKey{}).(string)
+				return userID
+			}),
+			WithTraceID(func(context.Context) string {
+				return "trace-1"
+			}),
+		)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	require.NoError(t, db.AutoMigrate(&testArea{}, &testSite{}, &model.AuditLog{}))
+
+	area := &testArea{AreaName: "east", Secret: "s"}
+	area.ID = 1
+	require.NoError(t, db.Create(area).Error)
+	site := &testSite{SiteName: "hz"}
+	site.ID = 2
+	require.NoError(t, db.Create(site).Error)
+	require.NoError(t, db.Model(&testArea{}).Where("id = ?", area.ID).Update("area_name", "west").Error)
+	// 未发生变化的更新不记录
+	require.NoError(t, db.Model(&testArea{}).Where("id = ?", area.ID).Update("area_name", "west").Error)
+	// 事务回滚时审计日志一并回滚
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(area).Update("area_name", "north").Error; err != nil {
+			return err
+		}
+		return assert.AnError
+	})
+	require.NoError(t, db.Where("id = ?", area.ID).Delete(&testArea{}).Error)
+
+	var logs []*model.AuditLog
+	require.NoError(t, db.Order("id asc").Find(&logs).Error)
+	require.Len(t, logs, 3)
+	for _, log := range logs {
+		assert.Equal(t, "area", log.ResourceType)
+		assert.Equal(t, "1", log.ResourceID)
+		assert.Equal(t, "user-1", log.UserID)
+		assert.Equal(t, "trace-1", log.TraceID)
+	}
+
+	assert.Equal(t, model.ActionCreate, logs[0].Action)
+	var after map[string]interface{}
+	require.NoError(t, json.Unmarshal(logs[0].After, &after))
+	assert.Equal(t, "east", after["area_name"])
+	assert.NotContains(t, after, "secret")
+
+	assert.Equal(t, model.ActionUpdate, logs[1].Action)
+	assert.JSONEq(t, `{"area_name":"east"}`, string(logs[1].Before))
+	assert.JSONEq(t, `{"area_name":"west"}`, string(logs[1].After))
+
+	assert.Equal(t, model.ActionDelete, logs[2].Action)
+	var before map[string]interface{}
+	require.NoError(t, json.Unmarshal(logs[2].Before, &before))
+	assert.Equal(t, "west", before["area_name"])
+	assert.Empty(t, logs[2].After)
+}
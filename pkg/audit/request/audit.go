@@ -0,0 +1,16 @@
+package request
+
+import (
+	database "template/pkg/storage"
+)
+
+// QueryAuditLogReq 审计日志查询,使用游标分页,默认按created_at倒序
+type QueryAuditLogReq struct {
+	database.CursorPagination
+	ResourceType string `form:"resource_type"`                                         // 资源类型
+	ResourceID   string `form:"resource_id"`                                           // 资源ID
+	Action       string `form:"action" binding:"omitempty,oneof=create update delete"` // 操作类型
+	AccountID    string `form:"account_id"`                                            // 账户ID
+	UserID       string `form:"user_id"`                                               // 用户ID
+	TraceID      string `form:"trace_id"`                                              // traceID
+}
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"template/pkg/audit/model"
+	"template/pkg/audit/request"
+	"template/pkg/audit/store/mysql"
+)
+
+func NewAuditLogStore(db *gorm.DB) AuditLog {
+	return mysql.NewAuditLog(db)
+}
+
+type AuditLog interface {
+	Get(ctx context.Context, id string) (*model.AuditLog, error)
+	// List 游标分页查询,查询后会设置 req.NextCursor
+	List(ctx context.Context, req *request.QueryAuditLogReq) ([]*model.AuditLog, error)
+}
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"template/pkg/audit/model"
+	"template/pkg/audit/request"
+	e "template/pkg/code"
+	database "template/pkg/storage"
+)
+
+func NewAuditLog(db *gorm.DB) *auditLog {
+	return &auditLog{
+		DB: db,
+	}
+}
+
+type auditLog struct {
+	*gorm.DB
+}
+
+// Get 根据id获取审计日志
+func (a *auditLog) Get(ctx context.Context, id string) (*model.AuditLog, error) {
+	var obj model.AuditLog
+	if err := a.WithContext(ctx).Model(&model.AuditLog{}).Where("id = ?", id).First(&obj).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(e.ErrCodeNotFound.WithResult("AuditLog"))
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &obj, nil
+}
+
+// List 按条件游标分页查询
+func (a *auditLog) List(ctx context.Context, data *request.QueryAuditLogReq) ([]*model.AuditLog, error) {
+	// 以模型的列作为白名单校验排序条件,未知的列返回参数错误
+	if _, err := database.NewFilter(&model.AuditLog{}, "", data.SortField); err != nil {
+		return nil, errors.WithStack(e.ErrCodeInvalidParam.WithResult(err.Error()))
+	}
+	var list []*model.AuditLog
+	query := a.WithContext(ctx).Model(&model.AuditLog{})
+	if data.ResourceType != "" {
+		query = query.Where("resource_type = ?", data.ResourceType)
+	}
+	if data.ResourceID != "" {
+		query = query.Where("resource_id = ?", data.ResourceID)
+	}
+	if data.Action != "" {
+		query = query.Where("action = ?", data.Action)
+	}
+	if data.AccountID != "" {
+		query = query.Where("account_id = ?", data.AccountID)
+	}
+	if data.UserID != "" {
+		query = query.Where("user_id = ?", data.UserID)
+	}
+	if data.TraceID != "" {
+		query = query.Where("trace_id = ?", data.TraceID)
+	}
+
+	if err := data.Build(ctx, query).Find(&list).Error; err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			return nil, errors.WithStack(e.ErrCodeInvalidParam.WithResult(err.Error()))
+		}
+		return nil, errors.WithStack(err)
+	}
+	if err := data.Next(&list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -76,30 +76,24 @@ func (d deleteDeletedJob) Execute(ctx context.Context) {
 		return
 	}
 	deadlineTime := time.Now().UTC().Add(-policy.Retention)
-	var hasDeletedTables []string
-	if err := d.connector(ctx).
-		Raw("SELECT table_name  FROM information_schema.columns  WHERE table_schema = ? AND COLUMN_NAME ='deleted'",
-			policy.Name).Find(&hasDeletedTables).Error; err != nil {
+	db := d.connector(ctx)
+	querier, err := newTableQuerier(db.Dialect())
+	if err != nil {
 		logger.From(ctx).Error("", zap.Error(err))
 		return
 	}
-	var hasDeletedAtTables []string
-	if err := d.connector(ctx).
-		Raw("SELECT table_name  FROM information_schema.columns  WHERE table_schema = ? AND COLUMN_NAME ='deleted_at'",
-			policy.Name).Find(&hasDeletedAtTables).Error; err != nil {
+	hasDeletedTables, err := querier.ColumnTables(db.DB, policy.Name, "deleted")
+	if err != nil {
 		logger.From(ctx).Error("", zap.Error(err))
 		return
 	}
-
-	var notOverLimitTables []string
-	if err := d.connector(ctx).
-		Raw(`SELECT a.table_name FROM (
-            SELECT
-            table_name,
-            table_rows,
-            TRUNCATE ( data_length / 1024 / 1024, 2 ) AS data_cap
-            FROM information_schema.tables WHERE table_schema = ?) AS a WHERE a.data_cap <  ?  AND  a.table_rows < ?;`,
-			policy.Name, policy.Capacity, policy.Rows).Find(&notOverLimitTables).Error; err != nil {
+	hasDeletedAtTables, err := querier.ColumnTables(db.DB, policy.Name, "deleted_at")
+	if err != nil {
+		logger.From(ctx).Error("", zap.Error(err))
+		return
+	}
+	notOverLimitTables, err := querier.NotOverLimitTables(db.DB, policy)
+	if err != nil {
 		logger.From(ctx).Error("", zap.Error(err))
 		return
 	}
@@ -134,8 +128,8 @@ func (d deleteDeletedJob) Execute(ctx context.Context) {
 		if skipTableName(name) {
 			continue
 		}
-		if err := d.connector(ctx).Exec(fmt.Sprintf("DELETE FROM %s.%s  WHERE deleted != 0  AND deleted_at <  ?",
-			policy.Name, name),
+		if err := d.connector(ctx).Exec(fmt.Sprintf("DELETE FROM %s  WHERE deleted != 0  AND deleted_at <  ?",
+			querier.TableName(db.DB, policy.Name, name)),
 			deadlineTime).Error; err != nil {
 			logger.From(ctx).Error("", zap.Error(err))
 			return
@@ -145,8 +139,8 @@ func (d deleteDeletedJob) Execute(ctx context.Context) {
 		if skipTableName(name) {
 			continue
 		}
-		if err := d.connector(ctx).Exec(fmt.Sprintf("DELETE FROM %s.%s  WHERE deleted_at IS NOT NULL  AND deleted_at <  ?",
-			policy.Name, name),
+		if err := d.connector(ctx).Exec(fmt.Sprintf("DELETE FROM %s  WHERE deleted_at IS NOT NULL  AND deleted_at <  ?",
+			querier.TableName(db.DB, policy.Name, name)),
 			deadlineTime).Error; err != nil {
 			logger.From(ctx).Error("", zap.Error(err))
 			return
@@ -0,0 +1,115 @@
+package clean
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"template/pkg/storage"
+)
+
+// tableQuerier 查询表信息,屏蔽不同数据库之间元数据查询的差异
+type tableQuerier interface {
+	// ColumnTables 查询包含指定列的表
+	ColumnTables(db *gorm.DB, name, column string) ([]string, error)
+	// NotOverLimitTables 查询容量及条数均未超出限制的表
+	NotOverLimitTables(db *gorm.DB, policy *Policy) ([]string, error)
+	// TableName 返回可用于DELETE语句的表名
+	TableName(db *gorm.DB, name, table string) string
+}
+
+func newTableQuerier(dialect storage.Dialect) (tableQuerier, error) {
+	switch dialect {
+	case storage.DialectMySQL:
+		return mysqlQuerier{}, nil
+	case storage.DialectPostgres:
+		return postgresQuerier{}, nil
+	case storage.DialectSQLite:
+		return sqliteQuerier{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported dialect %s", dialect)
+	}
+}
+
+type mysqlQuerier struct{}
+
+func (mysqlQuerier) ColumnTables(db *gorm.DB, name, column string) ([]string, error) {
+	var tables []string
+	err := db.Raw("SELECT table_name  FROM information_schema.columns  WHERE table_schema = ? AND COLUMN_NAME = ?",
+		name, column).Find(&tables).Error
+	return tables, err
+}
+
+func (mysqlQuerier) NotOverLimitTables(db *gorm.DB, policy *Policy) ([]string, error) {
+	var tables []string
+	err := db.Raw(`SELECT a.table_name FROM (
+            SELECT
+            table_name,
+            table_rows,
+            TRUNCATE ( data_length / 1024 / 1024, 2 ) AS data_cap
+            FROM information_schema.tables WHERE table_schema = ?) AS a WHERE a.data_cap <  ?  AND  a.table_rows < ?;`,
+		policy.Name, policy.Capacity, policy.Rows).Find(&tables).Error
+	return tables, err
+}
+
+func (mysqlQuerier) TableName(db *gorm.DB, name, table string) string {
+	return db.Statement.Quote(name + "." + table)
+}
+
+type postgresQuerier struct{}
+
+func (postgresQuerier) ColumnTables(db *gorm.DB, name, column string) ([]string, error) {
+	var tables []string
+	err := db.Raw(`SELECT table_name FROM information_schema.columns
+            WHERE table_catalog = ? AND table_schema = current_schema() AND column_name = ?`,
+		name, column).Find(&tables).Error
+	return tables, err
+}
+
+func (postgresQuerier) NotOverLimitTables(db *gorm.DB, policy *Policy) ([]string, error) {
+	var tables []string
+	err := db.Raw(`SELECT relname AS table_name FROM pg_stat_user_tables
+            WHERE schemaname = current_schema() AND current_database() = ?
+            AND pg_total_relation_size(relid) / 1024 / 1024 < ? AND n_live_tup < ?`,
+		policy.Name, policy.Capacity, policy.Rows).Find(&tables).Error
+	return tables, err
+}
+
+func (postgresQuerier) TableName(db *gorm.DB, _, table string) string {
+	return db.Statement.Quote(table)
+}
+
+// sqliteQuerier SQLite为单文件数据库,忽略数据库名称
+type sqliteQuerier struct{}
+
+func (sqliteQuerier) ColumnTables(db *gorm.DB, _, column string) ([]string, error) {
+	var tables []string
+	err := db.Raw(`SELECT m.name FROM sqlite_master AS m JOIN pragma_table_info(m.name) AS p
+            WHERE m.type = 'table' AND p.name = ?`,
+		column).Find(&tables).Error
+	return tables, err
+}
+
+// NotOverLimitTables SQLite没有表容量的统计信息,仅按条数判断
+func (sqliteQuerier) NotOverLimitTables(db *gorm.DB, policy *Policy) ([]string, error) {
+	var names []string
+	if err := db.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'").
+		Find(&names).Error; err != nil {
+		return nil, err
+	}
+	tables := make([]string, 0, len(names))
+	for _, name := range names {
+		var rows int64
+		if err := db.Table(name).Count(&rows).Error; err != nil {
+			return nil, err
+		}
+		if rows < int64(policy.Rows) {
+			tables = append(tables, name)
+		}
+	}
+	return tables, nil
+}
+
+func (sqliteQuerier) TableName(db *gorm.DB, _, table string) string {
+	return db.Statement.Quote(table)
+}
@@ -18,6 +18,10 @@ var (
 	ErrCodeUnknown          = Froze("5000000008", "未知错误")
 	ErrCodeRedisCacheOption = Froze("5000000009", "Redis缓存操作失败")
 	ErrTooManyRequests      = Froze("4290000010", "请求频率过高")
+	ErrVersionConflict      = Froze("4090000011", "资源已被修改,请刷新后重试")
+	ErrRequestInProgress    = Froze("4090000012", "相同的请求正在处理,请稍后重试")
+	ErrNotLeader            = Froze("4090000013", "当前实例不是leader,请稍后重试")
+	ErrIdempotencyMismatch  = Froze("4220000014", "幂等key已用于不同的请求内容")
 
 	// woslo 错误
 	ErrCodeInvalidParam        = Froze("400-1000000", "请求参数不正确")
@@ -44,6 +48,10 @@ func AddCode(m map[ErrorCode]struct{}) error {
 		ErrCodeUnknown:          {},
 		ErrCodeRedisCacheOption: {},
 		ErrTooManyRequests:      {},
+		ErrVersionConflict:      {},
+		ErrRequestInProgress:    {},
+		ErrNotLeader:            {},
+		ErrIdempotencyMismatch:  {},
 
 		ErrCodeInvalidParam:        {},
 		ErrCodeNotFound:            {},
@@ -0,0 +1,136 @@
+package idempotent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type State string
+
+const (
+	// Processing 正在处理,处理方异常退出时在锁定时间后过期
+	Processing State = "processing"
+	// Done 已处理完成
+	Done State = "done"
+)
+
+const (
+	defaultTTL     = 24 * time.Hour
+	defaultLockTTL = 5 * time.Minute
+)
+
+var (
+	// ErrDuplicate 相同的key已处理完成
+	ErrDuplicate = errors.New("duplicate idempotency key")
+	// ErrInProgress 相同的key正在处理
+	ErrInProgress = errors.New("idempotency key in progress")
+	// ErrNotRecorded 处理成功但保存结果失败,重复的请求可能再次处理
+	ErrNotRecorded = errors.New("idempotency result not recorded")
+)
+
+// Record key的处理状态及结果
+type Record struct {
+	State State `json:"state"`
+	// 处理结果,例如http请求的响应
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+	// 请求内容的摘要,相同key的请求内容不同时由调用方拒绝
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Store 保存key的处理状态
+type Store interface {
+	// Begin key不存在或已过期时保存为处理中并返回true,否则返回已有的记录及false
+	Begin(ctx context.Context, key string, ttl time.Duration) (*Record, bool, error)
+	// Complete 保存处理完成的记录
+	Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error
+	// Release 删除key,处理失败时调用以允许重试
+	Release(ctx context.Context, key string) error
+}
+
+type option struct {
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+type Option func(*option)
+
+// WithTTL 处理完成的记录保留的时间,默认24h
+func WithTTL(ttl time.Duration) Option {
+	return func(o *option) {
+		o.ttl = ttl
+	}
+}
+
+// WithLockTTL 处理中的记录保留的时间,应大于处理的最长时间,默认5m
+func WithLockTTL(ttl time.Duration) Option {
+	return func(o *option) {
+		o.lockTTL = ttl
+	}
+}
+
+// Guard 保证相同的key仅处理一次
+type Guard struct {
+	option
+	store Store
+}
+
+func NewGuard(store Store, opts ...Option) *Guard {
+	o := option{
+		ttl:     defaultTTL,
+		lockTTL: defaultLockTTL,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Guard{option: o, store: store}
+}
+
+// Do 执行fn并保存其返回的记录,fn返回错误时删除key以允许重试。
+// key已处理完成时不执行fn,返回已保存的记录及 ErrDuplicate;正在处理时返回 ErrInProgress
+func (g *Guard) Do(ctx context.Context, key string, fn func(ctx context.Context) (*Record, error)) (*Record, error) {
+	record, ok, err := g.store.Begin(ctx, key, g.lockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if record.State == Done {
+			return record, ErrDuplicate
+		}
+		return nil, ErrInProgress
+	}
+	if record, err = fn(ctx); err != nil {
+		if rerr := g.store.Release(ctx, key); rerr != nil {
+			return record, fmt.Errorf("%w,release idempotency key failed,%v", err, rerr)
+		}
+		return record, err
+	}
+	if record == nil {
+		record = &Record{}
+	}
+	record.State = Done
+	if err = g.store.Complete(ctx, key, record, g.ttl); err != nil {
+		return record, fmt.Errorf("%w,%v", ErrNotRecorded, err)
+	}
+	return record, nil
+}
+
+// StoredRecord 数据库中保存的记录,过期的记录在相同key再次使用时覆盖
+type StoredRecord struct {
+	Key         string    `json:"key" gorm:"column:idempotency_key;primaryKey;size:191;comment:幂等key"`
+	State       State     `json:"state" gorm:"column:state;comment:处理状态"`
+	Status      int       `json:"status" gorm:"column:status;comment:响应状态码"`
+	ContentType string    `json:"content_type" gorm:"column:content_type;comment:响应类型"`
+	Body        []byte    `json:"body" gorm:"column:body;comment:响应内容"`
+	Fingerprint string    `json:"fingerprint" gorm:"column:fingerprint;size:64;comment:请求摘要"`
+	ExpiredAt   time.Time `json:"expired_at" gorm:"column:expired_at;comment:过期时间"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at;comment:创建时间"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"column:updated_at;comment:更新时间"`
+}
+
+func (StoredRecord) TableName() string {
+	return "idempotency_record"
+}
@@ -0,0 +1,69 @@
+package idempotent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard_Do(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(NewMemoryStore())
+	runs := 0
+	fn := func(ctx context.Context) (*Record, error) {
+		runs++
+		return &Record{Status: 201, Body: []byte("created")}, nil
+	}
+	record, err := guard.Do(ctx, "key", fn)
+	require.NoError(t, err)
+	assert.Equal(t, Done, record.State)
+
+	// 重复的key返回已保存的记录
+	record, err = guard.Do(ctx, "key", fn)
+	assert.ErrorIs(t, err, ErrDuplicate)
+	assert.Equal(t, 201, record.Status)
+	assert.Equal(t, "created", string(record.Body))
+	assert.Equal(t, 1, runs)
+}
+
+func TestGuard_release(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(NewMemoryStore())
+	boom := errors.New("boom")
+	_, err := guard.Do(ctx, "key", func(ctx context.Context) (*Record, error) {
+		// 处理中的重复请求
+		_, err := guard.Do(ctx, "key", func(ctx context.Context) (*Record, error) {
+			t.Fatal("should not run while in progress")
+			return nil, nil
+		})
+		assert.ErrorIs(t, err, ErrInProgress)
+		return nil, boom
+	})
+	assert.ErrorIs(t, err, boom)
+
+	// 失败后允许重试
+	_, err = guard.Do(ctx, "key", func(ctx context.Context) (*Record, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestGuard_ttl(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(NewMemoryStore(), WithTTL(10*time.Millisecond))
+	runs := 0
+	fn := func(ctx context.Context) (*Record, error) {
+		runs++
+		return nil, nil
+	}
+	_, err := guard.Do(ctx, "key", fn)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = guard.Do(ctx, "key", fn)
+	require.NoError(t, err)
+	assert.Equal(t, 2, runs)
+}
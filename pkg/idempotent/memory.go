@@ -0,0 +1,60 @@
+package idempotent
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cleanupInterval 每处理该数量的key清理一次过期的记录
+const cleanupInterval = 1024
+
+// NewMemoryStore 基于内存的存储,仅适用于单实例部署及测试
+func NewMemoryStore() Store {
+	return &memoryStore{records: make(map[string]memoryRecord)}
+}
+
+type memoryRecord struct {
+	record    Record
+	expiredAt time.Time
+}
+
+type memoryStore struct {
+	mux     sync.Mutex
+	records map[string]memoryRecord
+	begins  int
+}
+
+func (s *memoryStore) Begin(ctx context.Context, key string, ttl time.Duration) (*Record, bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now()
+	if r, ok := s.records[key]; ok && now.Before(r.expiredAt) {
+		record := r.record
+		return &record, false, nil
+	}
+	// 定期清理过期的记录
+	if s.begins++; s.begins%cleanupInterval == 0 {
+		for k, r := range s.records {
+			if !now.Before(r.expiredAt) {
+				delete(s.records, k)
+			}
+		}
+	}
+	s.records[key] = memoryRecord{record: Record{State: Processing}, expiredAt: now.Add(ttl)}
+	return nil, true, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.records[key] = memoryRecord{record: *record, expiredAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, key string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,63 @@
+package idempotent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"template/pkg/json"
+	"template/pkg/redis"
+)
+
+// NewRedisStore 基于redis的存储,key保存为 prefix+key
+func NewRedisStore(client *redis.Client, prefix string) Store {
+	return &redisStore{client: client, prefix: prefix}
+}
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func (s *redisStore) Begin(ctx context.Context, key string, ttl time.Duration) (*Record, bool, error) {
+	data, err := json.Marshal(&Record{State: Processing})
+	if err != nil {
+		return nil, false, err
+	}
+	key = s.prefix + key
+	// 获取已有记录时key可能恰好过期,重新保存一次
+	for i := 0; i < 2; i++ {
+		ok, err := s.client.SetNX(key, data, ttl)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return nil, true, nil
+		}
+		value, err := s.client.GetByte(key)
+		if errors.Is(err, redis.NilErr) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		var record Record
+		if err = json.Unmarshal(value, &record); err != nil {
+			return nil, false, err
+		}
+		return &record, false, nil
+	}
+	return &Record{State: Processing}, false, nil
+}
+
+func (s *redisStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.prefix+key, data, ttl)
+}
+
+func (s *redisStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(s.prefix + key)
+}
@@ -0,0 +1,100 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"template/pkg/idempotent"
+)
+
+// NewRecordStore 基于数据库的幂等记录存储
+func NewRecordStore(db *gorm.DB) idempotent.Store {
+	return &recordStore{
+		DB: db,
+	}
+}
+
+type recordStore struct {
+	*gorm.DB
+}
+
+// Begin key不存在或已过期时保存为处理中
+func (s *recordStore) Begin(ctx context.Context, key string, ttl time.Duration) (*idempotent.Record, bool, error) {
+	now := time.Now()
+	obj := &idempotent.StoredRecord{
+		Key:       key,
+		State:     idempotent.Processing,
+		ExpiredAt: now.Add(ttl),
+	}
+	result := s.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(obj)
+	if result.Error != nil {
+		return nil, false, errors.WithStack(result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return nil, true, nil
+	}
+	// 已过期的记录以条件更新的方式抢占
+	result = s.WithContext(ctx).Model(&idempotent.StoredRecord{}).
+		Where("idempotency_key = ? AND expired_at < ?", key, now).
+		Select("state", "status", "content_type", "body", "expired_at").
+		Updates(obj)
+	if result.Error != nil {
+		return nil, false, errors.WithStack(result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return nil, true, nil
+	}
+	var stored idempotent.StoredRecord
+	if err := s.WithContext(ctx).Where("idempotency_key = ?", key).First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 记录恰好被删除,视为处理中由调用方稍后重试
+			return &idempotent.Record{State: idempotent.Processing}, false, nil
+		}
+		return nil, false, errors.WithStack(err)
+	}
+	return &idempotent.Record{
+		State:       stored.State,
+		Status:      stored.Status,
+		ContentType: stored.ContentType,
+		Body:        stored.Body,
+		Fingerprint: stored.Fingerprint,
+	}, false, nil
+}
+
+// Complete 保存处理完成的记录
+func (s *recordStore) Complete(ctx context.Context, key string, record *idempotent.Record, ttl time.Duration) error {
+	if err := s.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&idempotent.StoredRecord{
+		Key:         key,
+		State:       record.State,
+		Status:      record.Status,
+		ContentType: record.ContentType,
+		Body:        record.Body,
+		Fingerprint: record.Fingerprint,
+		ExpiredAt:   time.Now().Add(ttl),
+	}).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Release 删除key
+func (s *recordStore) Release(ctx context.Context, key string) error {
+	if err := s.WithContext(ctx).Where("idempotency_key = ?", key).
+		Delete(&idempotent.StoredRecord{}).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// DeleteExpired 删除过期的记录,可由定时任务周期执行
+func DeleteExpired(ctx context.Context, db *gorm.DB) (int64, error) {
+	result := db.WithContext(ctx).Where("expired_at < ?", time.Now()).Delete(&idempotent.StoredRecord{})
+	if result.Error != nil {
+		return 0, errors.WithStack(result.Error)
+	}
+	return result.RowsAffected, nil
+}
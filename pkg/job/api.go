@@ -0,0 +1,192 @@
+package job
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	e "template/pkg/code"
+	"template/pkg/resp"
+)
+
+// defaultPreview 默认预览的触发次数
+const defaultPreview = 5
+
+// JobInfo 已调度任务的信息
+type JobInfo struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	Trigger     string `json:"trigger"`
+	Paused      bool   `json:"paused"`
+	// 下次触发时间
+	NextFireTime time.Time `json:"next_fire_time"`
+	// 正在执行的实例数
+	Running int `json:"running"`
+	// 最近一次结束的执行记录
+	LastRun *JobRun `json:"last_run,omitempty"`
+	// 接下来的触发时间,仅查询单个任务时返回
+	Preview []time.Time `json:"preview,omitempty"`
+}
+
+// PreviewReq 预览触发时间的参数
+type PreviewReq struct {
+	// 预览的触发次数,默认为5
+	// in: query
+	Preview int `form:"preview" binding:"omitempty,min=1,max=100"`
+}
+
+// RescheduleReq 修改任务触发器的参数
+type RescheduleReq struct {
+	// 触发器描述,支持crontab及 @every、@at、@delay 等描述符
+	// in: body
+	Spec string `json:"spec" binding:"required"`
+}
+
+// RegisterAPI 注册任务管理接口
+func RegisterAPI(router gin.IRouter, scheduler SchedulerRuntime) {
+	api := &jobAPI{scheduler: scheduler}
+	g := router.Group("/jobs")
+	g.GET("", api.ListJobs)
+	g.GET("/:key", api.GetJob)
+	g.GET("/:key/runs", api.ListJobRuns)
+	g.POST("/:key/pause", api.PauseJob)
+	g.POST("/:key/resume", api.ResumeJob)
+	g.POST("/:key/trigger", api.TriggerJob)
+	g.PUT("/:key/schedule", api.RescheduleJob)
+}
+
+type jobAPI struct {
+	scheduler SchedulerRuntime
+}
+
+func (a *jobAPI) ListJobs(c *gin.Context) {
+	keys := a.scheduler.GetJobKeys()
+	sort.Strings(keys)
+	list := make([]*JobInfo, 0, len(keys))
+	for _, key := range keys {
+		info, err := a.info(key, 0)
+		if err != nil {
+			continue
+		}
+		list = append(list, info)
+	}
+	resp.List(c, list, 1, len(list), int64(len(list)))
+}
+
+func (a *jobAPI) GetJob(c *gin.Context) {
+	var req PreviewReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.ErrorParam(c, err)
+		return
+	}
+	if req.Preview == 0 {
+		req.Preview = defaultPreview
+	}
+	info, err := a.info(c.Param("key"), req.Preview)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	resp.Success(c, info)
+}
+
+func (a *jobAPI) ListJobRuns(c *gin.Context) {
+	key := c.Param("key")
+	if !a.scheduler.Has(key) {
+		resp.Error(c, e.ErrCodeNotFound.WithResult("Job"))
+		return
+	}
+	runs := a.scheduler.GetJobRuns(key)
+	resp.List(c, runs, 1, len(runs), int64(len(runs)))
+}
+
+func (a *jobAPI) PauseJob(c *gin.Context) {
+	a.action(c, a.scheduler.Pause)
+}
+
+func (a *jobAPI) ResumeJob(c *gin.Context) {
+	a.action(c, a.scheduler.Resume)
+}
+
+func (a *jobAPI) TriggerJob(c *gin.Context) {
+	a.action(c, a.scheduler.TriggerNow)
+}
+
+func (a *jobAPI) RescheduleJob(c *gin.Context) {
+	var req RescheduleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.ErrorParam(c, err)
+		return
+	}
+	trigger, err := ParseTrigger(req.Spec)
+	if err != nil {
+		resp.Error(c, e.ErrCodeInvalidParam.WithResult(err.Error()))
+		return
+	}
+	key := c.Param("key")
+	if err = a.scheduler.Reschedule(c.Request.Context(), key, trigger); err != nil {
+		resp.Error(c, a.convertError(err))
+		return
+	}
+	info, err := a.info(key, defaultPreview)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	resp.Success(c, info)
+}
+
+func (a *jobAPI) action(c *gin.Context, f func(ctx context.Context, key string) error) {
+	if err := f(c.Request.Context(), c.Param("key")); err != nil {
+		resp.Error(c, a.convertError(err))
+		return
+	}
+	resp.Success(c)
+}
+
+func (a *jobAPI) convertError(err error) error {
+	if errors.Is(err, ErrJobNotFound) {
+		return e.ErrCodeNotFound.WithResult("Job")
+	}
+	if errors.Is(err, ErrNotLeader) {
+		return e.ErrNotLeader
+	}
+	return err
+}
+
+// info 获取任务信息,preview大于0时返回接下来的触发时间
+func (a *jobAPI) info(key string, preview int) (*JobInfo, error) {
+	if !a.scheduler.Has(key) {
+		return nil, e.ErrCodeNotFound.WithResult("Job")
+	}
+	scheduled, err := a.scheduler.GetScheduledJob(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	info := &JobInfo{
+		Key:          key,
+		Description:  scheduled.Job.Description(),
+		Trigger:      scheduled.Trigger.Description(),
+		Paused:       scheduled.Paused,
+		NextFireTime: time.Unix(0, scheduled.NextFireTime),
+	}
+	if spec, ok := scheduled.Trigger.(Specifier); ok {
+		info.Trigger = spec.Spec()
+	}
+	for _, run := range a.scheduler.GetJobRuns(key) {
+		run := run
+		if run.Status == RunRunning {
+			info.Running++
+			continue
+		}
+		info.LastRun = &run
+		break
+	}
+	for _, fire := range NextFireTimes(scheduled.Trigger, scheduled.NextFireTime, preview) {
+		info.Preview = append(info.Preview, time.Unix(0, fire))
+	}
+	return info, nil
+}
@@ -0,0 +1,369 @@
+package job
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxExcludedFires 跳过被排除的触发时间的最大次数,避免日历排除所有时间时无限循环
+const maxExcludedFires = 1 << 20
+
+const dateLayout = "2006-01-02"
+
+// Calendar 排除不允许触发的时间
+type Calendar interface {
+	// Excludes 返回该时间是否不允许触发
+	Excludes(t time.Time) bool
+}
+
+// CalendarFunc 函数形式的日历
+type CalendarFunc func(t time.Time) bool
+
+func (f CalendarFunc) Excludes(t time.Time) bool {
+	return f(t)
+}
+
+// Window 排除的时间段 [Start, End)
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (w Window) Excludes(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// HolidayCalendar 按日期排除,例如节假日
+type HolidayCalendar struct {
+	loc   *time.Location
+	dates map[string]struct{}
+}
+
+// NewHolidayCalendar 创建节假日日历,日期格式为 2006-01-02,loc为空时使用本地时区
+func NewHolidayCalendar(loc *time.Location, dates ...string) (*HolidayCalendar, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	c := &HolidayCalendar{loc: loc, dates: make(map[string]struct{}, len(dates))}
+	for _, date := range dates {
+		if _, err := time.ParseInLocation(dateLayout, date, loc); err != nil {
+			return nil, errors.Wrapf(err, "invalid holiday %s", date)
+		}
+		c.dates[date] = struct{}{}
+	}
+	return c, nil
+}
+
+func (c *HolidayCalendar) Excludes(t time.Time) bool {
+	_, ok := c.dates[t.In(c.loc).Format(dateLayout)]
+	return ok
+}
+
+// BusinessHours 仅允许在工作时间触发,排除其他时间
+type BusinessHours struct {
+	// 工作日,默认为星期一至星期五
+	Weekdays []time.Weekday
+	// 每天的开始及结束时间,自0点起的时长,[Start, End)
+	Start, End time.Duration
+	// 时区,默认为本地时区
+	Location *time.Location
+}
+
+func (b BusinessHours) Excludes(t time.Time) bool {
+	loc := b.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	weekdays := b.Weekdays
+	if len(weekdays) == 0 {
+		weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	}
+	workday := false
+	for _, weekday := range weekdays {
+		if t.Weekday() == weekday {
+			workday = true
+			break
+		}
+	}
+	if !workday {
+		return true
+	}
+	offset := t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc))
+	return offset < b.Start || offset >= b.End
+}
+
+// CalendarConfig 日历配置,用于从配置文件加载排除的节假日及维护窗口
+type CalendarConfig struct {
+	// 时区,默认为本地时区
+	Location string `mapstructure:"location" json:"location"`
+	// 排除的日期,格式为 2006-01-02
+	Holidays []string `mapstructure:"holidays" json:"holidays"`
+	// 排除的维护窗口
+	Windows []WindowConfig `mapstructure:"windows" json:"windows"`
+	// 工作时间,为空时不限制
+	BusinessHours *BusinessHoursConfig `mapstructure:"business_hours" json:"business_hours"`
+}
+
+// WindowConfig 维护窗口配置,时间格式为 RFC3339
+type WindowConfig struct {
+	Start string `mapstructure:"start" json:"start"`
+	End   string `mapstructure:"end" json:"end"`
+}
+
+// BusinessHoursConfig 工作时间配置,时间格式为 15:04
+type BusinessHoursConfig struct {
+	// 工作日,0为星期日,默认为星期一至星期五
+	Weekdays []int  `mapstructure:"weekdays" json:"weekdays"`
+	Start    string `mapstructure:"start" json:"start"`
+	End      string `mapstructure:"end" json:"end"`
+}
+
+// Calendars 根据配置创建日历
+func (c CalendarConfig) Calendars() ([]Calendar, error) {
+	loc := time.Local
+	if c.Location != "" {
+		var err error
+		if loc, err = time.LoadLocation(c.Location); err != nil {
+			return nil, errors.Wrapf(err, "invalid location %s", c.Location)
+		}
+	}
+	calendars := make([]Calendar, 0, len(c.Windows)+2)
+	if len(c.Holidays) > 0 {
+		holidays, err := NewHolidayCalendar(loc, c.Holidays...)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, holidays)
+	}
+	for _, w := range c.Windows {
+		start, err := time.Parse(time.RFC3339, w.Start)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid window start %s", w.Start)
+		}
+		end, err := time.Parse(time.RFC3339, w.End)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid window end %s", w.End)
+		}
+		calendars = append(calendars, Window{Start: start, End: end})
+	}
+	if b := c.BusinessHours; b != nil {
+		hours := BusinessHours{Location: loc}
+		for _, weekday := range b.Weekdays {
+			if weekday < 0 || weekday > 6 {
+				return nil, errors.Errorf("invalid weekday %d", weekday)
+			}
+			hours.Weekdays = append(hours.Weekdays, time.Weekday(weekday))
+		}
+		var err error
+		if hours.Start, err = parseClock(b.Start); err != nil {
+			return nil, err
+		}
+		if hours.End, err = parseClock(b.End); err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, hours)
+	}
+	return calendars, nil
+}
+
+// parseClock 解析 15:04 格式的时间为自0点起的时长
+func parseClock(clock string) (time.Duration, error) {
+	if clock == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid clock %s", clock)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// calendarTrigger 跳过被日历排除的触发时间
+type calendarTrigger struct {
+	trigger   Trigger
+	calendars []Calendar
+}
+
+// WithCalendar 跳过被任一日历排除的触发时间,
+// 日历无法用触发器描述表示,因此返回的触发器不实现 Specifier,持久化调度器不会持久化使用该触发器的任务
+func WithCalendar(trigger Trigger, calendars ...Calendar) Trigger {
+	return &calendarTrigger{trigger: trigger, calendars: calendars}
+}
+
+// WithBusinessHours 仅在工作时间内触发
+func WithBusinessHours(trigger Trigger, hours BusinessHours) Trigger {
+	return WithCalendar(trigger, hours)
+}
+
+func (c *calendarTrigger) NextFireTime(prev int64) (int64, error) {
+	next, err := c.trigger.NextFireTime(prev)
+	for i := 0; err == nil && c.excludes(next); i++ {
+		if i == maxExcludedFires {
+			return 0, errors.Errorf("no fire time found in %d fires", maxExcludedFires)
+		}
+		next, err = c.trigger.NextFireTime(next)
+	}
+	return next, err
+}
+
+func (c *calendarTrigger) excludes(next int64) bool {
+	t := time.Unix(0, next)
+	for _, calendar := range c.calendars {
+		if calendar.Excludes(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *calendarTrigger) copy() (Trigger, bool) {
+	trigger, ok := copyTrigger(c.trigger)
+	if !ok {
+		return nil, false
+	}
+	return WithCalendar(trigger, c.calendars...), true
+}
+
+func (c *calendarTrigger) Description() string {
+	return fmt.Sprintf("%s with %d calendars", c.trigger.Description(), len(c.calendars))
+}
+
+// jitterTrigger 在触发时间上增加随机延迟
+type jitterTrigger struct {
+	trigger Trigger
+	max     time.Duration
+
+	mux sync.Mutex
+	rnd *rand.Rand
+	// 上次增加的延迟,计算下次触发时间时去除,避免延迟累积
+	last int64
+}
+
+// WithJitter 在每次触发时间上增加 [0, max) 的随机延迟,避免多个实例同时触发,
+// 返回的触发器不实现 Specifier,不会被持久化
+func WithJitter(trigger Trigger, max time.Duration) Trigger {
+	return &jitterTrigger{
+		trigger: trigger,
+		max:     max,
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (j *jitterTrigger) NextFireTime(prev int64) (int64, error) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	next, err := j.trigger.NextFireTime(prev - j.last)
+	if err != nil || j.max <= 0 {
+		return next, err
+	}
+	j.last = j.rnd.Int63n(j.max.Nanoseconds())
+	return next + j.last, nil
+}
+
+// copy 副本使用新的随机延迟
+func (j *jitterTrigger) copy() (Trigger, bool) {
+	trigger, ok := copyTrigger(j.trigger)
+	if !ok {
+		return nil, false
+	}
+	return WithJitter(trigger, j.max), true
+}
+
+func (j *jitterTrigger) Description() string {
+	return fmt.Sprintf("%s with jitter %s", j.trigger.Description(), j.max)
+}
+
+type BoundOption func(*boundedTrigger)
+
+// StartAt 不早于该时间触发
+func StartAt(start time.Time) BoundOption {
+	return func(b *boundedTrigger) {
+		b.start = start.UnixNano()
+	}
+}
+
+// EndAt 晚于该时间后不再触发
+func EndAt(end time.Time) BoundOption {
+	return func(b *boundedTrigger) {
+		b.end = end.UnixNano()
+	}
+}
+
+// MaxFires 最多触发的次数
+func MaxFires(n int) BoundOption {
+	return func(b *boundedTrigger) {
+		b.maxFires = n
+	}
+}
+
+// boundedTrigger 限制触发的时间范围及次数
+type boundedTrigger struct {
+	trigger    Trigger
+	start, end int64
+	maxFires   int
+
+	mux   sync.Mutex
+	fires int
+}
+
+// Bounded 限制触发的时间范围及次数,超出后不再调度,
+// 已触发的次数无法用触发器描述表示,因此返回的触发器不实现 Specifier,不会被持久化
+func Bounded(trigger Trigger, opts ...BoundOption) Trigger {
+	b := &boundedTrigger{trigger: trigger}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *boundedTrigger) NextFireTime(prev int64) (int64, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.maxFires > 0 && b.fires >= b.maxFires {
+		return 0, ErrSkipScheduleJob
+	}
+	// 直接从开始时间计算,避免逐次推进内部触发器
+	if prev < b.start {
+		prev = b.start - 1
+	}
+	next, err := b.trigger.NextFireTime(prev)
+	if err != nil {
+		return 0, err
+	}
+	if b.end > 0 && next > b.end {
+		return 0, ErrSkipScheduleJob
+	}
+	b.fires++
+	return next, nil
+}
+
+// copy 副本保留已触发的次数
+func (b *boundedTrigger) copy() (Trigger, bool) {
+	trigger, ok := copyTrigger(b.trigger)
+	if !ok {
+		return nil, false
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return &boundedTrigger{trigger: trigger, start: b.start, end: b.end, maxFires: b.maxFires, fires: b.fires}, true
+}
+
+func (b *boundedTrigger) Description() string {
+	bounds := make([]string, 0, 3)
+	if b.start > 0 {
+		bounds = append(bounds, "start: "+time.Unix(0, b.start).Format(time.RFC3339))
+	}
+	if b.end > 0 {
+		bounds = append(bounds, "end: "+time.Unix(0, b.end).Format(time.RFC3339))
+	}
+	if b.maxFires > 0 {
+		bounds = append(bounds, fmt.Sprintf("max fires: %d", b.maxFires))
+	}
+	return fmt.Sprintf("%s bounded by (%s)", b.trigger.Description(), strings.Join(bounds, ", "))
+}
@@ -0,0 +1,134 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCalendar(t *testing.T) {
+	loc := time.UTC
+	// 2024-01-01 为星期一
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, loc)
+	holidays, err := NewHolidayCalendar(loc, "2024-01-02")
+	require.NoError(t, err)
+	window := Window{Start: start.Add(81 * time.Hour), End: start.Add(83 * time.Hour)}
+	hours := BusinessHours{Start: 9 * time.Hour, End: 18 * time.Hour, Location: loc}
+
+	trigger := WithCalendar(Every(time.Hour), holidays, window, hours)
+	var fires []time.Time
+	prev := start.UnixNano()
+	for i := 0; i < 40; i++ {
+		next, err := trigger.NextFireTime(prev)
+		require.NoError(t, err)
+		fires = append(fires, time.Unix(0, next).In(loc))
+		prev = next
+	}
+	assert.Equal(t, time.Date(2024, 1, 1, 9, 0, 0, 0, loc), fires[0])
+	assert.Equal(t, time.Date(2024, 1, 1, 17, 0, 0, 0, loc), fires[8])
+	// 跳过节假日
+	assert.Equal(t, time.Date(2024, 1, 3, 9, 0, 0, 0, loc), fires[9])
+	// 跳过维护窗口
+	assert.Equal(t, time.Date(2024, 1, 4, 11, 0, 0, 0, loc), fires[18])
+	// 跳过周末
+	assert.Equal(t, time.Date(2024, 1, 8, 9, 0, 0, 0, loc), fires[34])
+
+	never := WithCalendar(Every(time.Hour), CalendarFunc(func(time.Time) bool { return true }))
+	_, err = never.NextFireTime(start.UnixNano())
+	assert.Error(t, err)
+}
+
+func TestWithJitter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	trigger := WithJitter(Every(time.Minute), 10*time.Second)
+	prev := start
+	for i := int64(1); i <= 10; i++ {
+		next, err := trigger.NextFireTime(prev)
+		require.NoError(t, err)
+		base := start + i*int64(time.Minute)
+		assert.GreaterOrEqual(t, next, base)
+		assert.Less(t, next, base+int64(10*time.Second))
+		prev = next
+	}
+}
+
+func TestBounded(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	minutely, err := ParseStandard("0 * * * * *")
+	require.NoError(t, err)
+	trigger := Bounded(minutely, StartAt(start.Add(10*time.Minute)), EndAt(start.Add(time.Hour)), MaxFires(3))
+	prev := start.UnixNano()
+	for i := 10; i < 13; i++ {
+		next, err := trigger.NextFireTime(prev)
+		require.NoError(t, err)
+		assert.Equal(t, start.Add(time.Duration(i)*time.Minute).UnixNano(), next)
+		prev = next
+	}
+	_, err = trigger.NextFireTime(prev)
+	assert.ErrorIs(t, err, ErrSkipScheduleJob)
+
+	// 开始时间较远时无需逐次推进内部触发器
+	trigger = Bounded(Every(time.Second), StartAt(start.Add(13*24*time.Hour)))
+	next, err := trigger.NextFireTime(start.UnixNano())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, next, start.Add(13*24*time.Hour).UnixNano())
+	assert.Less(t, next, start.Add(13*24*time.Hour+time.Second).UnixNano())
+
+	trigger = Bounded(Every(time.Minute), EndAt(start.Add(time.Minute)))
+	_, err = trigger.NextFireTime(start.UnixNano())
+	require.NoError(t, err)
+	_, err = trigger.NextFireTime(start.Add(time.Minute).UnixNano())
+	assert.ErrorIs(t, err, ErrSkipScheduleJob)
+	assert.Contains(t, trigger.Description(), "end: ")
+}
+
+func TestNextFireTimes_decorated(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bounded := Bounded(Every(time.Hour), MaxFires(3))
+	next, err := bounded.NextFireTime(start.UnixNano())
+	require.NoError(t, err)
+	// 预览不影响调度中的触发器,保留已触发的次数
+	assert.Len(t, NextFireTimes(bounded, next, 5), 3)
+	assert.Len(t, NextFireTimes(bounded, next, 5), 3)
+
+	weekend := CalendarFunc(func(t time.Time) bool {
+		return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+	})
+	// 2024-01-05 为星期五
+	friday := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	fires := NextFireTimes(WithJitter(WithCalendar(Every(24*time.Hour), weekend), time.Minute), friday.UnixNano(), 2)
+	require.Len(t, fires, 2)
+	monday := friday.Add(72 * time.Hour).UnixNano()
+	assert.GreaterOrEqual(t, fires[1], monday)
+	assert.Less(t, fires[1], monday+int64(time.Minute))
+
+	// 无法复制的触发器仅返回下次触发时间
+	assert.Len(t, NextFireTimes(WithCalendar(RunOnce(time.Minute), weekend), next, 5), 1)
+}
+
+func TestCalendarConfig(t *testing.T) {
+	calendars, err := CalendarConfig{
+		Location: "Asia/Shanghai",
+		Holidays: []string{"2024-01-01"},
+		Windows:  []WindowConfig{{Start: "2024-01-02T00:00:00+08:00", End: "2024-01-02T02:00:00+08:00"}},
+		BusinessHours: &BusinessHoursConfig{
+			Start: "09:00",
+			End:   "18:00",
+		},
+	}.Calendars()
+	require.NoError(t, err)
+	require.Len(t, calendars, 3)
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	assert.True(t, calendars[0].Excludes(time.Date(2024, 1, 1, 10, 0, 0, 0, loc)))
+	assert.True(t, calendars[1].Excludes(time.Date(2024, 1, 2, 1, 0, 0, 0, loc)))
+	assert.False(t, calendars[2].Excludes(time.Date(2024, 1, 2, 9, 0, 0, 0, loc)))
+	assert.True(t, calendars[2].Excludes(time.Date(2024, 1, 6, 10, 0, 0, 0, loc)))
+
+	_, err = CalendarConfig{Holidays: []string{"2024/01/01"}}.Calendars()
+	assert.Error(t, err)
+	_, err = CalendarConfig{BusinessHours: &BusinessHoursConfig{Weekdays: []int{7}}}.Calendars()
+	assert.Error(t, err)
+}
@@ -0,0 +1,301 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"template/pkg/conc/pool"
+	"template/pkg/logger"
+	"template/pkg/syncx"
+)
+
+// Policy 任务在集群中的执行策略
+type Policy int
+
+const (
+	// PolicyOnce 每次触发仅由集群中的leader执行
+	PolicyOnce Policy = iota
+	// PolicyEverywhere 每次触发在所有实例上执行
+	PolicyEverywhere
+)
+
+// Lease 集群中的租约,同一时刻仅有一个实例持有
+type Lease interface {
+	// Acquire 获取租约,成功时返回单调递增的fencing token,被其他实例持有时返回 syncx.ErrNotObtained
+	Acquire(ctx context.Context) (int64, error)
+	// Renew 续约,租约丢失时返回错误
+	Renew(ctx context.Context) error
+	// Release 释放租约
+	Release(ctx context.Context) error
+	// TTL 租约的有效期
+	TTL() time.Duration
+}
+
+// ErrNotLeader 非leader的实例上修改仅由leader执行的任务
+var ErrNotLeader = errors.New("scheduler is not leader")
+
+type fencingTokenKey struct{}
+
+// FencingToken 获取leader执行任务时的fencing token,
+// 任务写入外部存储时携带该值,存储端拒绝小于已见过的token的写入,以防止过期的leader覆盖数据
+func FencingToken(ctx context.Context) (int64, bool) {
+	token, ok := ctx.Value(fencingTokenKey{}).(int64)
+	return token, ok
+}
+
+type policyJob struct {
+	Job
+	policy Policy
+}
+
+// WithPolicy 指定任务在集群中的执行策略,未指定时使用集群调度器的默认策略
+func WithPolicy(job Job, policy Policy) Job {
+	return &policyJob{Job: job, policy: policy}
+}
+
+func (p *policyJob) Unwrap() Job {
+	return p.Job
+}
+
+type ClusterOption func(*clusterScheduler)
+
+// WithDefaultPolicy 设置任务默认的执行策略,默认为 PolicyOnce
+func WithDefaultPolicy(policy Policy) ClusterOption {
+	return func(c *clusterScheduler) {
+		c.defaultPolicy = policy
+	}
+}
+
+// WithRenewInterval 设置续约及竞选的间隔,默认为租约有效期的1/3
+func WithRenewInterval(interval time.Duration) ClusterOption {
+	return func(c *clusterScheduler) {
+		c.renewInterval = interval
+	}
+}
+
+// NewClusterScheduler 集群感知的调度器,各实例通过租约选举leader,
+// PolicyOnce 的任务仅在leader上执行,leader丢失租约时正在执行的任务会被取消。
+// 续约间隔需大于0且小于租约的有效期
+func NewClusterScheduler(runtime SchedulerRuntime, lease Lease, opts ...ClusterOption) (SchedulerRuntime, error) {
+	c := &clusterScheduler{
+		SchedulerRuntime: runtime,
+		lease:            lease,
+		defaultPolicy:    PolicyOnce,
+		renewInterval:    lease.TTL() / 3,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if ttl := lease.TTL(); c.renewInterval <= 0 || c.renewInterval >= ttl {
+		return nil, errors.Errorf("invalid renew interval %s for lease ttl %s", c.renewInterval, ttl)
+	}
+	return c, nil
+}
+
+type clusterScheduler struct {
+	SchedulerRuntime
+	lease         Lease
+	defaultPolicy Policy
+	renewInterval time.Duration
+
+	mux    sync.RWMutex
+	leader *leadership
+}
+
+// leadership 一次任期
+type leadership struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	token  int64
+}
+
+func (c *clusterScheduler) Start(ctx context.Context) error {
+	g := pool.New().WithContext(ctx).WithCancelOnError()
+	g.Go(func(ctx context.Context) error {
+		c.elect(ctx)
+		return nil
+	})
+	g.Go(func(ctx context.Context) error {
+		return c.SchedulerRuntime.Start(ctx)
+	})
+	return g.Wait()
+}
+
+// elect 定时竞选或续约
+func (c *clusterScheduler) elect(ctx context.Context) {
+	ticker := time.NewTicker(c.renewInterval)
+	defer ticker.Stop()
+	for {
+		c.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			c.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *clusterScheduler) campaign(ctx context.Context) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.leader != nil {
+		if err := c.lease.Renew(ctx); err != nil {
+			logger.From(ctx).Warn("lost scheduler leadership", zap.Int64("token", c.leader.token), zap.Error(err))
+			c.leader.cancel()
+			c.leader = nil
+		}
+		return
+	}
+	token, err := c.lease.Acquire(ctx)
+	if err != nil {
+		// 被其他实例持有时为正常的竞选失败,其他错误(例如redis不可用)需记录
+		if !errors.Is(err, syncx.ErrNotObtained) && ctx.Err() == nil {
+			logger.From(ctx).Warn("acquire scheduler lease failed", zap.Error(err))
+		}
+		return
+	}
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	c.leader = &leadership{ctx: leaderCtx, cancel: cancel, token: token}
+	logger.From(ctx).Info("became scheduler leader", zap.Int64("token", token))
+}
+
+func (c *clusterScheduler) resign() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.leader == nil {
+		return
+	}
+	c.leader.cancel()
+	c.leader = nil
+	ctx, cancel := context.WithTimeout(context.Background(), c.renewInterval)
+	defer cancel()
+	_ = c.lease.Release(ctx)
+}
+
+// leadership 当前的任期,非leader时返回nil
+func (c *clusterScheduler) leadership() *leadership {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.leader
+}
+
+func (c *clusterScheduler) ScheduleJob(ctx context.Context, job Job, trigger Trigger) error {
+	policy := c.defaultPolicy
+	if p, ok := unwrapJob(job, func(j Job) bool {
+		_, ok := j.(*policyJob)
+		return ok
+	}); ok {
+		policy = p.(*policyJob).policy
+	}
+	if p, ok := job.(*policyJob); ok {
+		job = p.Job
+	}
+	return c.SchedulerRuntime.ScheduleJob(ctx, &clusterJob{Job: job, policy: policy, scheduler: c}, trigger)
+}
+
+func (c *clusterScheduler) GetScheduledJob(key string) (*ScheduledJob, error) {
+	scheduledJob, err := c.SchedulerRuntime.GetScheduledJob(key)
+	if err != nil {
+		return nil, err
+	}
+	if j, ok := scheduledJob.Job.(*clusterJob); ok {
+		scheduledJob.Job = j.Job
+	}
+	return scheduledJob, nil
+}
+
+// Pause 仅由leader执行的任务只能在leader上暂停,
+// 暂停等状态不在实例间同步,leader切换后新的leader按原触发器调度
+func (c *clusterScheduler) Pause(ctx context.Context, key string) error {
+	if err := c.checkLeader(key); err != nil {
+		return err
+	}
+	return c.SchedulerRuntime.Pause(ctx, key)
+}
+
+func (c *clusterScheduler) Resume(ctx context.Context, key string) error {
+	if err := c.checkLeader(key); err != nil {
+		return err
+	}
+	return c.SchedulerRuntime.Resume(ctx, key)
+}
+
+// TriggerNow 非leader上触发的执行会被丢弃,因此直接拒绝
+func (c *clusterScheduler) TriggerNow(ctx context.Context, key string) error {
+	if err := c.checkLeader(key); err != nil {
+		return err
+	}
+	return c.SchedulerRuntime.TriggerNow(ctx, key)
+}
+
+func (c *clusterScheduler) Reschedule(ctx context.Context, key string, trigger Trigger) error {
+	if err := c.checkLeader(key); err != nil {
+		return err
+	}
+	return c.SchedulerRuntime.Reschedule(ctx, key, trigger)
+}
+
+// active 所有实例上执行的任务或本实例为leader时返回true
+func (c *clusterScheduler) active(key string) bool {
+	if scheduledJob, err := c.SchedulerRuntime.GetScheduledJob(key); err == nil {
+		if j, ok := scheduledJob.Job.(*clusterJob); ok && j.policy == PolicyEverywhere {
+			return true
+		}
+	}
+	return c.leadership() != nil
+}
+
+// checkLeader 仅由leader执行的任务在非leader上返回 ErrNotLeader
+func (c *clusterScheduler) checkLeader(key string) error {
+	scheduledJob, err := c.SchedulerRuntime.GetScheduledJob(key)
+	if err != nil {
+		// 任务不存在时由后续的操作返回 ErrJobNotFound
+		return nil
+	}
+	if j, ok := scheduledJob.Job.(*clusterJob); ok && j.policy == PolicyEverywhere {
+		return nil
+	}
+	if c.leadership() == nil {
+		return errors.Wrapf(ErrNotLeader, "key %s", key)
+	}
+	return nil
+}
+
+type clusterJob struct {
+	Job
+	policy    Policy
+	scheduler *clusterScheduler
+}
+
+func (c *clusterJob) Unwrap() Job {
+	return c.Job
+}
+
+func (c *clusterJob) Execute(ctx context.Context) {
+	if c.policy == PolicyEverywhere {
+		c.Job.Execute(ctx)
+		return
+	}
+	leader := c.scheduler.leadership()
+	if leader == nil {
+		discardRun(ctx)
+		return
+	}
+	// 任期结束时取消正在执行的任务
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, fencingTokenKey{}, leader.token))
+	defer cancel()
+	go func() {
+		select {
+		case <-leader.ctx.Done():
+			logger.From(ctx).Warn("cancel job since scheduler leadership is lost", zap.String("key", c.Key()))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	c.Job.Execute(ctx)
+}
@@ -0,0 +1,254 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"template/pkg/conc/pool"
+	"template/pkg/syncx"
+)
+
+// memLease 进程内的租约,模拟集群中共享的redis
+type memLease struct {
+	mux    *sync.Mutex
+	holder *string
+	token  *int64
+	id     string
+}
+
+func newMemLeases(ids ...string) []*memLease {
+	var (
+		mux    sync.Mutex
+		holder string
+		token  int64
+	)
+	leases := make([]*memLease, 0, len(ids))
+	for _, id := range ids {
+		leases = append(leases, &memLease{mux: &mux, holder: &holder, token: &token, id: id})
+	}
+	return leases
+}
+
+func (m *memLease) Acquire(context.Context) (int64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if *m.holder != "" && *m.holder != m.id {
+		return 0, syncx.ErrNotObtained
+	}
+	if *m.holder != m.id {
+		*m.holder = m.id
+		*m.token++
+	}
+	return *m.token, nil
+}
+
+func (m *memLease) Renew(context.Context) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if *m.holder != m.id {
+		return errors.New("lost")
+	}
+	return nil
+}
+
+func (m *memLease) Release(context.Context) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if *m.holder == m.id {
+		*m.holder = ""
+	}
+	return nil
+}
+
+// expire 模拟租约过期
+func (m *memLease) expire() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	*m.holder = ""
+}
+
+func (m *memLease) TTL() time.Duration {
+	return 30 * time.Millisecond
+}
+
+type countJob struct {
+	key    string
+	count  int64
+	tokens sync.Map
+}
+
+func (c *countJob) Description() string {
+	return c.key
+}
+
+func (c *countJob) Key() string {
+	return c.key
+}
+
+func (c *countJob) Execute(ctx context.Context) {
+	atomic.AddInt64(&c.count, 1)
+	if token, ok := FencingToken(ctx); ok {
+		c.tokens.Store(token, struct{}{})
+	}
+}
+
+func TestClusterScheduler(t *testing.T) {
+	leases := newMemLeases("a", "b")
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+
+	g := pool.New().WithContext(ctx)
+	onceJobs := make([]*countJob, len(leases))
+	everywhereJobs := make([]*countJob, len(leases))
+	for i, lease := range leases {
+		s, err := NewClusterScheduler(NewTimeWheel(WithInterval(5*time.Millisecond), WithSlot(64)), lease,
+			WithRenewInterval(10*time.Millisecond))
+		require.NoError(t, err)
+		onceJobs[i] = &countJob{key: "once"}
+		everywhereJobs[i] = &countJob{key: "everywhere"}
+		require.NoError(t, s.ScheduleJob(ctx, onceJobs[i], Every(20*time.Millisecond)))
+		require.NoError(t, s.ScheduleJob(ctx, WithPolicy(everywhereJobs[i], PolicyEverywhere), Every(20*time.Millisecond)))
+
+		scheduled, err := s.GetScheduledJob("once")
+		require.NoError(t, err)
+		assert.Equal(t, onceJobs[i], scheduled.Job)
+		g.Go(s.Start)
+	}
+
+	// 运行一段时间后使leader的租约过期,由另一个实例接替
+	time.Sleep(300 * time.Millisecond)
+	leader := 0
+	if atomic.LoadInt64(&onceJobs[1].count) > 0 {
+		leader = 1
+	}
+	assert.Zero(t, atomic.LoadInt64(&onceJobs[1-leader].count))
+	leases[leader].expire()
+	_, _ = leases[1-leader].Acquire(context.Background())
+	before := atomic.LoadInt64(&onceJobs[leader].count)
+
+	_ = g.Wait()
+	for i := range leases {
+		assert.Positive(t, atomic.LoadInt64(&everywhereJobs[i].count))
+	}
+	assert.Positive(t, atomic.LoadInt64(&onceJobs[1-leader].count))
+	// 丢失租约后不再执行,允许续约检测前的一次触发
+	assert.LessOrEqual(t, atomic.LoadInt64(&onceJobs[leader].count), before+1)
+	// 新的leader使用更大的fencing token
+	onceJobs[1-leader].tokens.Range(func(key, _ interface{}) bool {
+		assert.Equal(t, int64(2), key)
+		return true
+	})
+}
+
+func TestClusterScheduler_follower(t *testing.T) {
+	leases := newMemLeases("a", "b")
+	ctx, cancel := context.WithCancel(context.Background())
+	g := pool.New().WithContext(ctx)
+	defer func() {
+		cancel()
+		_ = g.Wait()
+	}()
+
+	schedulers := make([]*clusterScheduler, len(leases))
+	jobs := make([]*countJob, len(leases))
+	for i, lease := range leases {
+		s, err := NewClusterScheduler(NewTimeWheel(WithInterval(5*time.Millisecond), WithSlot(64)), lease,
+			WithRenewInterval(10*time.Millisecond))
+		require.NoError(t, err)
+		schedulers[i] = s.(*clusterScheduler)
+		jobs[i] = &countJob{key: "once"}
+		require.NoError(t, schedulers[i].ScheduleJob(ctx, jobs[i], Every(time.Hour)))
+		require.NoError(t, schedulers[i].ScheduleJob(ctx, WithPolicy(&countJob{key: "everywhere"}, PolicyEverywhere),
+			Every(time.Hour)))
+		g.Go(schedulers[i].Start)
+	}
+	require.Eventually(t, func() bool {
+		return schedulers[0].leadership() != nil || schedulers[1].leadership() != nil
+	}, time.Second, 5*time.Millisecond)
+	leader, follower := 0, 1
+	if schedulers[1].leadership() != nil {
+		leader, follower = 1, 0
+	}
+
+	// 非leader上拒绝修改仅由leader执行的任务
+	s := schedulers[follower]
+	assert.ErrorIs(t, s.Pause(ctx, "once"), ErrNotLeader)
+	assert.ErrorIs(t, s.Resume(ctx, "once"), ErrNotLeader)
+	assert.ErrorIs(t, s.TriggerNow(ctx, "once"), ErrNotLeader)
+	assert.ErrorIs(t, s.Reschedule(ctx, "once", Every(time.Minute)), ErrNotLeader)
+	assert.ErrorIs(t, s.Pause(ctx, "unknown"), ErrJobNotFound)
+	// 所有实例上执行的任务不受限制
+	assert.NoError(t, s.Pause(ctx, "everywhere"))
+	assert.NoError(t, s.Resume(ctx, "everywhere"))
+
+	// leader上立即触发的任务会被执行
+	require.NoError(t, schedulers[leader].TriggerNow(ctx, "once"))
+	require.NoError(t, schedulers[leader].Reschedule(ctx, "once", Every(time.Minute)))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&jobs[leader].count) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Zero(t, atomic.LoadInt64(&jobs[follower].count))
+}
+
+func TestNewClusterScheduler_invalid(t *testing.T) {
+	lease := newMemLeases("a")[0]
+	for _, interval := range []time.Duration{-time.Second, lease.TTL()} {
+		_, err := NewClusterScheduler(NewTimeWheel(), lease, WithRenewInterval(interval))
+		assert.Error(t, err, interval)
+	}
+	// 有效期过短导致默认的续约间隔为0
+	_, err := NewClusterScheduler(NewTimeWheel(), &shortLease{memLease: lease})
+	assert.Error(t, err)
+}
+
+type shortLease struct {
+	*memLease
+}
+
+func (s *shortLease) TTL() time.Duration {
+	return 2 * time.Nanosecond
+}
+
+func TestClusterScheduler_persistent(t *testing.T) {
+	leases := newMemLeases("leader", "follower")
+	// 先获取租约使第一个实例成为leader
+	_, err := leases[0].Acquire(context.Background())
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	g := pool.New().WithContext(ctx)
+	stores := []*memStore{newMemStore(), newMemStore()}
+	for i, lease := range leases {
+		c, err := NewClusterScheduler(NewTimeWheel(WithInterval(5*time.Millisecond), WithSlot(64)), lease,
+			WithRenewInterval(10*time.Millisecond))
+		require.NoError(t, err)
+		s := NewPersistentScheduler(c, stores[i])
+		require.NoError(t, s.ScheduleJob(ctx, &persistCountJob{key: "every"}, Every(20*time.Millisecond)))
+		require.NoError(t, s.ScheduleJob(ctx, &persistCountJob{key: "once"}, RunOnce(20*time.Millisecond)))
+		g.Go(s.Start)
+	}
+	_ = g.Wait()
+
+	leader, err := stores[0].Get(context.Background(), "every")
+	require.NoError(t, err)
+	assert.Positive(t, leader.LastFireTime)
+	assert.Positive(t, leader.NextFireTime)
+	_, err = stores[0].Get(context.Background(), "once")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	// 非leader丢弃的触发不更新存储中的触发时间,一次性任务仍保留
+	follower, err := stores[1].Get(context.Background(), "every")
+	require.NoError(t, err)
+	assert.Zero(t, follower.LastFireTime)
+	assert.Zero(t, follower.NextFireTime)
+	_, err = stores[1].Get(context.Background(), "once")
+	assert.NoError(t, err)
+}
@@ -3,6 +3,7 @@ package job
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +25,7 @@ const (
 	Dow                                    // Day of week field, default *
 	DowOptional                            // Optional day of week field, default *
 	Descriptor                             // Allow descriptors such as @monthly, @weekly, etc.
+	YearOptional                           // Optional year field after all other fields, default *
 )
 
 var places = []ParseOption{
@@ -111,6 +113,14 @@ func (p Parser) Parse(spec string) (Trigger, error) {
 	// Split on whitespace.
 	fields := strings.Fields(spec)
 
+	// The year field is only recognized when all other fields are provided,
+	// otherwise it would be ambiguous with the optional fields.
+	yearField := "*"
+	if p.options&YearOptional > 0 && len(fields) == fieldCount(p.options)+1 {
+		yearField = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
 	// Validate & fill in any omitted or optional fields
 	var err error
 	fields, err = normalizeFields(fields, p.options)
@@ -128,16 +138,26 @@ func (p Parser) Parse(spec string) (Trigger, error) {
 	}
 
 	var (
-		second     = field(fields[0], seconds)
-		minute     = field(fields[1], minutes)
-		hour       = field(fields[2], hours)
-		dayofmonth = field(fields[3], dom)
-		month      = field(fields[4], months)
-		dayofweek  = field(fields[5], dow)
+		second = field(fields[0], seconds)
+		minute = field(fields[1], minutes)
+		hour   = field(fields[2], hours)
+		month  = field(fields[4], months)
 	)
 	if err != nil {
 		return nil, err
 	}
+	dayofmonth, domRules, err := getDomField(fields[3])
+	if err != nil {
+		return nil, err
+	}
+	dayofweek, dowRules, err := getDowField(fields[5])
+	if err != nil {
+		return nil, err
+	}
+	years, err := getYears(yearField)
+	if err != nil {
+		return nil, err
+	}
 
 	return &specTrigger{
 		Second:   second,
@@ -146,11 +166,31 @@ func (p Parser) Parse(spec string) (Trigger, error) {
 		Dom:      dayofmonth,
 		Month:    month,
 		Dow:      dayofweek,
+		Years:    years,
 		Location: loc,
 		spec:     spec,
+		domRules: domRules,
+		dowRules: dowRules,
 	}, nil
 }
 
+// fieldCount returns the number of fields when all optional fields are provided.
+func fieldCount(options ParseOption) int {
+	if options&SecondOptional > 0 {
+		options |= Second
+	}
+	if options&DowOptional > 0 {
+		options |= Dow
+	}
+	count := 0
+	for _, place := range places {
+		if options&place > 0 {
+			count++
+		}
+	}
+	return count
+}
+
 // normalizeFields takes a subset set of the time fields and returns the full set
 // with defaults (zeroes) populated for unset fields.
 //
@@ -214,7 +254,7 @@ func normalizeFields(fields []string, options ParseOption) ([]string, error) {
 }
 
 var standardParser = NewParser(
-	SecondOptional | Minute | Hour | Dom | Month | Dow | Descriptor,
+	SecondOptional | Minute | Hour | Dom | Month | Dow | Descriptor | YearOptional,
 )
 
 // ParseStandard returns a new crontab schedule representing the given
@@ -224,6 +264,7 @@ var standardParser = NewParser(
 //
 // It accepts
 //   - Standard crontab specs, e.g. "* * * * ?"
+//   - Quartz style specs with L, W, # and year, e.g. "0 0 10 ? * 6#2 2024-2026"
 //   - Descriptors, e.g. "@midnight", "@every 1h30m"
 func ParseStandard(standardSpec string) (Trigger, error) {
 	return standardParser.Parse(standardSpec)
@@ -318,6 +359,168 @@ func getRange(expr string, r bounds) (uint64, error) {
 	return getBits(start, end, step) | extra, nil
 }
 
+// getDomField returns the bits and rules of the day of month field, which
+// accepts the following expressions besides ranges:
+//
+//	L      the last day of the month
+//	L-n    n days before the last day of the month
+//	nW     the nearest weekday to the nth day of the month, within the same month
+//	LW     the last weekday of the month
+func getDomField(field string) (uint64, []domRule, error) {
+	var (
+		bits  uint64
+		rules []domRule
+	)
+	for _, expr := range strings.FieldsFunc(field, func(r rune) bool { return r == ',' }) {
+		upper := strings.ToUpper(expr)
+		switch {
+		case upper == "LW":
+			rules = append(rules, domRule{kind: lastWeekday})
+		case upper == "L":
+			rules = append(rules, domRule{kind: lastDay})
+		case strings.HasPrefix(upper, "L-"):
+			offset, err := mustParseInt(upper[2:])
+			if err != nil {
+				return 0, nil, err
+			}
+			if offset >= dom.max {
+				return 0, nil, fmt.Errorf("offset from last day (%d) above maximum (%d): %s", offset, dom.max-1, expr)
+			}
+			rules = append(rules, domRule{kind: lastDay, value: int(offset)})
+		case strings.HasSuffix(upper, "W"):
+			day, err := mustParseInt(upper[:len(upper)-1])
+			if err != nil {
+				return 0, nil, err
+			}
+			if day < dom.min || day > dom.max {
+				return 0, nil, fmt.Errorf("day of nearest weekday (%d) out of range [%d, %d]: %s", day, dom.min, dom.max, expr)
+			}
+			rules = append(rules, domRule{kind: nearestWeekday, value: int(day)})
+		default:
+			bit, err := getRange(expr, dom)
+			if err != nil {
+				return 0, nil, err
+			}
+			bits |= bit
+		}
+	}
+	if bits&starBit > 0 && len(rules) > 0 {
+		return 0, nil, fmt.Errorf("wildcard can not be combined with L or W: %s", field)
+	}
+	return bits, rules, nil
+}
+
+// getDowField returns the bits and rules of the day of week field, which
+// accepts the following expressions besides ranges:
+//
+//	L      the last day of the week, Saturday
+//	dL     the last day d of the week in the month, e.g. 5L is the last Friday
+//	d#n    the nth day d of the week in the month, e.g. 5#2 is the second Friday
+func getDowField(field string) (uint64, []dowRule, error) {
+	var (
+		bits  uint64
+		rules []dowRule
+	)
+	for _, expr := range strings.FieldsFunc(field, func(r rune) bool { return r == ',' }) {
+		upper := strings.ToUpper(expr)
+		switch {
+		case upper == "L":
+			bits |= 1 << dow.max
+		case strings.Contains(upper, "#"):
+			parts := strings.SplitN(upper, "#", 2)
+			day, err := parseIntOrName(parts[0], dow.names)
+			if err != nil {
+				return 0, nil, err
+			}
+			nth, err := mustParseInt(parts[1])
+			if err != nil {
+				return 0, nil, err
+			}
+			if day > dow.max || nth < 1 || nth > 5 {
+				return 0, nil, fmt.Errorf("invalid nth day of week: %s", expr)
+			}
+			rules = append(rules, dowRule{weekday: time.Weekday(day), nth: int(nth)})
+		case len(upper) > 1 && strings.HasSuffix(upper, "L"):
+			day, err := parseIntOrName(upper[:len(upper)-1], dow.names)
+			if err != nil {
+				return 0, nil, err
+			}
+			if day > dow.max {
+				return 0, nil, fmt.Errorf("end of range (%d) above maximum (%d): %s", day, dow.max, expr)
+			}
+			rules = append(rules, dowRule{weekday: time.Weekday(day), nth: -1})
+		default:
+			bit, err := getRange(expr, dow)
+			if err != nil {
+				return 0, nil, err
+			}
+			bits |= bit
+		}
+	}
+	if bits&starBit > 0 && len(rules) > 0 {
+		return 0, nil, fmt.Errorf("wildcard can not be combined with L or #: %s", field)
+	}
+	return bits, rules, nil
+}
+
+// getYears returns the sorted years of the year field, nil means every year.
+func getYears(field string) ([]int, error) {
+	if field == "*" || field == "?" {
+		return nil, nil
+	}
+	set := make(map[int]struct{})
+	for _, expr := range strings.FieldsFunc(field, func(r rune) bool { return r == ',' }) {
+		var (
+			start, end, step uint
+			rangeAndStep     = strings.Split(expr, "/")
+			lowAndHigh       = strings.Split(rangeAndStep[0], "-")
+			err              error
+		)
+		if lowAndHigh[0] == "*" {
+			start, end = years.min, years.max
+		} else {
+			if start, err = mustParseInt(lowAndHigh[0]); err != nil {
+				return nil, err
+			}
+			end = start
+			switch len(lowAndHigh) {
+			case 1:
+			case 2:
+				if end, err = mustParseInt(lowAndHigh[1]); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("too many hyphens: %s", expr)
+			}
+		}
+		step = 1
+		switch len(rangeAndStep) {
+		case 1:
+		case 2:
+			if step, err = mustParseInt(rangeAndStep[1]); err != nil {
+				return nil, err
+			}
+			if len(lowAndHigh) == 1 {
+				end = years.max
+			}
+		default:
+			return nil, fmt.Errorf("too many slashes: %s", expr)
+		}
+		if start < years.min || end > years.max || start > end || step == 0 {
+			return nil, fmt.Errorf("invalid year range [%d, %d]: %s", years.min, years.max, expr)
+		}
+		for year := start; year <= end; year += step {
+			set[int(year)] = struct{}{}
+		}
+	}
+	list := make([]int, 0, len(set))
+	for year := range set {
+		list = append(list, year)
+	}
+	sort.Ints(list)
+	return list, nil
+}
+
 // parseIntOrName returns the (possibly-named) integer contained in expr.
 func parseIntOrName(expr string, names map[string]uint) (uint, error) {
 	if names != nil {
@@ -7,9 +7,103 @@ import (
 )
 
 type CycleValue struct {
-	Day   int   `json:"day,omitempty" binding:"excluded_with=Weeks,omitempty,min=1,max=30"`
-	Weeks []int `json:"weeks" binding:"excluded_with=Day,dive,min=0,max=6"`
-	Hours []int `json:"hours" binding:"required,dive,min=0,max=23"`
+	Day   int   `json:"day,omitempty" binding:"excluded_with=Weeks Monthly,omitempty,min=1,max=30"`
+	Weeks []int `json:"weeks" binding:"excluded_with=Day Monthly,dive,min=0,max=6"`
+	// 按月执行,与 Day、Weeks 互斥
+	Monthly *MonthlyCycle `json:"monthly,omitempty" binding:"excluded_with=Day Weeks,omitempty"`
+	Hours   []int         `json:"hours" binding:"required,dive,min=0,max=23"`
+}
+
+// MonthlyCycle 每月执行的日期,按日期或按星期二选一,至少指定一项
+type MonthlyCycle struct {
+	// 每月的第几天,当月没有该日期时不执行
+	Days []int `json:"days,omitempty" binding:"required_without_all=LastDay NearestWeekday LastWeekday Weekday,dive,min=1,max=31"`
+	// 每月最后一天
+	LastDay bool `json:"last_day,omitempty"`
+	// 距离每月该日期最近的工作日,不跨月
+	NearestWeekday int `json:"nearest_weekday,omitempty" binding:"omitempty,min=1,max=31"`
+	// 每月最后一个工作日
+	LastWeekday bool `json:"last_weekday,omitempty"`
+	// 每月第 Nth 个星期几,0为星期日,与日期互斥
+	Weekday *int `json:"weekday,omitempty" binding:"omitempty,min=0,max=6"`
+	// 第几个,1-5,-1表示最后一个
+	Nth int `json:"nth,omitempty" binding:"required_with=Weekday,omitempty,min=-1,max=5"`
+}
+
+// fields 返回日及星期的表达式
+func (m *MonthlyCycle) fields() (string, string) {
+	if m.Weekday != nil {
+		if m.Nth < 0 {
+			return "?", strconv.Itoa(*m.Weekday) + "L"
+		}
+		return "?", strconv.Itoa(*m.Weekday) + "#" + strconv.Itoa(m.Nth)
+	}
+	days := make([]string, 0, len(m.Days)+3)
+	for _, day := range m.Days {
+		days = append(days, strconv.Itoa(day))
+	}
+	if m.LastDay {
+		days = append(days, "L")
+	}
+	if m.NearestWeekday != 0 {
+		days = append(days, strconv.Itoa(m.NearestWeekday)+"W")
+	}
+	if m.LastWeekday {
+		days = append(days, "LW")
+	}
+	return strings.Join(days, ","), "?"
+}
+
+// parse 解析日及星期的表达式
+func (m *MonthlyCycle) parse(dom, dow string) error {
+	if dom == "?" {
+		var (
+			day string
+			err error
+		)
+		if strings.HasSuffix(dow, "L") {
+			day, m.Nth = strings.TrimSuffix(dow, "L"), -1
+		} else {
+			parts := strings.SplitN(dow, "#", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("%s is not nth weekday", dow)
+			}
+			day = parts[0]
+			if m.Nth, err = strconv.Atoi(parts[1]); err != nil {
+				return fmt.Errorf("parse nth failed,%w", err)
+			}
+		}
+		weekday, err := strconv.Atoi(day)
+		if err != nil {
+			return fmt.Errorf("parse weekday failed,%w", err)
+		}
+		m.Weekday = &weekday
+		return nil
+	}
+	if dow != "?" {
+		return fmt.Errorf("%s is not ?", dow)
+	}
+	for _, day := range strings.Split(dom, ",") {
+		switch {
+		case day == "L":
+			m.LastDay = true
+		case day == "LW":
+			m.LastWeekday = true
+		case strings.HasSuffix(day, "W"):
+			num, err := strconv.Atoi(strings.TrimSuffix(day, "W"))
+			if err != nil {
+				return fmt.Errorf("parse nearest weekday failed,%w", err)
+			}
+			m.NearestWeekday = num
+		default:
+			num, err := strconv.Atoi(day)
+			if err != nil {
+				return fmt.Errorf("parse day failed,%w", err)
+			}
+			m.Days = append(m.Days, num)
+		}
+	}
+	return nil
 }
 
 func (c CycleValue) ToCronJobExpr() string {
@@ -21,11 +115,15 @@ func (c CycleValue) ToCronJobExpr() string {
 		hours = append(hours, strconv.Itoa(hour))
 	}
 	fields[2] = strings.Join(hours, ",")
-	if c.Day != 0 {
+	switch {
+	case c.Monthly != nil:
+		fields[3], fields[5] = c.Monthly.fields()
+		fields[4] = "*"
+	case c.Day != 0:
 		fields[3] = "*/" + strconv.Itoa(c.Day)
 		fields[4] = "*"
 		fields[5] = "?"
-	} else {
+	default:
 		fields[3] = "?"
 		fields[4] = "*"
 		weeks := make([]string, 0, len(c.Weeks))
@@ -65,7 +163,11 @@ func (c *CycleValue) Parse(v string) error {
 		c.Hours = append(c.Hours, num)
 	}
 
-	if fields[3] == "?" {
+	switch {
+	case strings.ContainsAny(fields[5], "L#"):
+		c.Monthly = &MonthlyCycle{}
+		return c.Monthly.parse(fields[3], fields[5])
+	case fields[3] == "?":
 		weeks := strings.Split(fields[5], ",")
 		for _, week := range weeks {
 			num, err := strconv.Atoi(week)
@@ -74,10 +176,7 @@ func (c *CycleValue) Parse(v string) error {
 			}
 			c.Weeks = append(c.Weeks, num)
 		}
-	} else {
-		if !strings.Contains(fields[3], "*/") {
-			return fmt.Errorf("%s not found */", fields[3])
-		}
+	case strings.Contains(fields[3], "*/"):
 		day := strings.TrimLeft(fields[3], "*/")
 		var err error
 		if c.Day, err = strconv.Atoi(day); err != nil {
@@ -86,6 +185,9 @@ func (c *CycleValue) Parse(v string) error {
 		if fields[5] != "?" {
 			return fmt.Errorf("%s is not *", fields[5])
 		}
+	default:
+		c.Monthly = &MonthlyCycle{}
+		return c.Monthly.parse(fields[3], fields[5])
 	}
 	return nil
 }
@@ -0,0 +1,47 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"template/pkg/validator"
+)
+
+func TestCycleValue(t *testing.T) {
+	friday := 5
+	tests := []struct {
+		value CycleValue
+		expr  string
+	}{
+		{CycleValue{Day: 2, Hours: []int{1, 13}}, "0 0 1,13 */2 * ?"},
+		{CycleValue{Weeks: []int{1, 3}, Hours: []int{8}}, "0 0 8 ? * 1,3"},
+		{CycleValue{Monthly: &MonthlyCycle{Days: []int{1, 15}, LastDay: true}, Hours: []int{8}}, "0 0 8 1,15,L * ?"},
+		{CycleValue{Monthly: &MonthlyCycle{NearestWeekday: 15, LastWeekday: true}, Hours: []int{8}}, "0 0 8 15W,LW * ?"},
+		{CycleValue{Monthly: &MonthlyCycle{Weekday: &friday, Nth: 2}, Hours: []int{8}}, "0 0 8 ? * 5#2"},
+		{CycleValue{Monthly: &MonthlyCycle{Weekday: &friday, Nth: -1}, Hours: []int{8}}, "0 0 8 ? * 5L"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			assert.Equal(t, tt.expr, tt.value.ToCronJobExpr())
+			_, err := ParseStandard(tt.expr)
+			require.NoError(t, err)
+
+			var value CycleValue
+			require.NoError(t, value.Parse(tt.expr))
+			assert.Equal(t, tt.value, value)
+		})
+	}
+}
+
+func TestCycleValue_emptyMonthly(t *testing.T) {
+	friday := 5
+	v := validator.NewValidator()
+	assert.Error(t, v.ValidateStruct(CycleValue{Monthly: &MonthlyCycle{}, Hours: []int{8}}))
+	assert.NoError(t, v.ValidateStruct(CycleValue{Monthly: &MonthlyCycle{LastDay: true}, Hours: []int{8}}))
+	assert.NoError(t, v.ValidateStruct(CycleValue{Monthly: &MonthlyCycle{Weekday: &friday, Nth: 1}, Hours: []int{8}}))
+
+	var value CycleValue
+	assert.Error(t, value.Parse(CycleValue{Monthly: &MonthlyCycle{}, Hours: []int{8}}.ToCronJobExpr()))
+}
@@ -0,0 +1,296 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"template/pkg/conc/panics"
+	"template/pkg/logger"
+)
+
+// ConcurrencyPolicy 上次执行尚未结束时再次触发的处理策略,与 Kubernetes CronJob 一致
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow 允许同时执行
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencyForbid 跳过本次触发
+	ConcurrencyForbid ConcurrencyPolicy = "forbid"
+	// ConcurrencyReplace 取消正在执行的实例后执行
+	ConcurrencyReplace ConcurrencyPolicy = "replace"
+)
+
+// RunStatus 任务单次执行的状态
+type RunStatus string
+
+const (
+	RunRunning  RunStatus = "running"
+	RunSuccess  RunStatus = "success"
+	RunFailed   RunStatus = "failed"
+	RunTimeout  RunStatus = "timeout"
+	RunCanceled RunStatus = "canceled"
+	RunSkipped  RunStatus = "skipped"
+)
+
+// defaultHistorySize 每个任务保留的执行记录数
+const defaultHistorySize = 20
+
+// JobRun 任务的一次执行记录
+type JobRun struct {
+	ID        int64     `json:"id"`
+	Key       string    `json:"key"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time,omitempty"`
+	// 执行耗时,单位毫秒
+	Duration int64     `json:"duration"`
+	Status   RunStatus `json:"status"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type jobOptions struct {
+	timeout     time.Duration
+	concurrency ConcurrencyPolicy
+}
+
+type JobOption func(*jobOptions)
+
+// WithTimeout 设置任务的最大执行时间,超时后取消执行的context
+func WithTimeout(timeout time.Duration) JobOption {
+	return func(o *jobOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithConcurrency 设置任务的并发策略,默认为 ConcurrencyAllow
+func WithConcurrency(policy ConcurrencyPolicy) JobOption {
+	return func(o *jobOptions) {
+		o.concurrency = policy
+	}
+}
+
+type optionsJob struct {
+	Job
+	options jobOptions
+}
+
+// WithOptions 设置任务的执行选项
+func WithOptions(job Job, opts ...JobOption) Job {
+	j := &optionsJob{Job: job, options: jobOptions{concurrency: ConcurrencyAllow}}
+	for _, opt := range opts {
+		opt(&j.options)
+	}
+	return j
+}
+
+func (j *optionsJob) Unwrap() Job {
+	return j.Job
+}
+
+func optionsOf(job Job) jobOptions {
+	if j, ok := unwrapJob(job, func(j Job) bool {
+		_, ok := j.(*optionsJob)
+		return ok
+	}); ok {
+		return j.(*optionsJob).options
+	}
+	return jobOptions{concurrency: ConcurrencyAllow}
+}
+
+type runKey struct{}
+
+// discardRun 本次执行无需记录,例如非leader的实例跳过执行
+func discardRun(ctx context.Context) {
+	if e, ok := ctx.Value(runKey{}).(*execution); ok {
+		atomic.StoreUint32(&e.discarded, 1)
+	}
+}
+
+// execution 正在执行的任务实例
+type execution struct {
+	run       JobRun
+	cancel    context.CancelFunc
+	replaced  uint32
+	discarded uint32
+}
+
+// executor 执行任务,记录执行历史及指标
+type executor struct {
+	size int
+	seq  int64
+
+	mux     sync.RWMutex
+	running map[string][]*execution
+	// 每个任务最近的执行记录,按时间由旧到新循环写入
+	history map[string]*runRing
+}
+
+func newExecutor(size int) *executor {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &executor{
+		size:    size,
+		running: make(map[string][]*execution),
+		history: make(map[string]*runRing),
+	}
+}
+
+func (e *executor) execute(ctx context.Context, job Job) {
+	key, options := job.Key(), optionsOf(job)
+	var cancel context.CancelFunc
+	if options.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	exec, ok := e.begin(key, options.concurrency, cancel)
+	if !ok {
+		jobRunsTotal.WithLabelValues(key, string(RunSkipped)).Inc()
+		return
+	}
+
+	jobRunning.WithLabelValues(key).Inc()
+	var catcher panics.Catcher
+	catcher.Try(func() {
+		job.Execute(context.WithValue(ctx, runKey{}, exec))
+	})
+	jobRunning.WithLabelValues(key).Dec()
+
+	status, errMsg := RunSuccess, ""
+	switch recovered := catcher.Recovered(); {
+	case recovered != nil:
+		status, errMsg = RunFailed, recovered.String()
+		logger.From(ctx).Error("job panic", zap.String("key", key), zap.String("panic", errMsg))
+	case atomic.LoadUint32(&exec.replaced) == 1:
+		status = RunCanceled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status, errMsg = RunTimeout, ctx.Err().Error()
+	case errors.Is(ctx.Err(), context.Canceled):
+		status, errMsg = RunCanceled, ctx.Err().Error()
+	}
+	run := e.end(exec, status, errMsg)
+	if atomic.LoadUint32(&exec.discarded) == 1 {
+		return
+	}
+	jobRunsTotal.WithLabelValues(key, string(status)).Inc()
+	jobRunDuration.WithLabelValues(key).Observe(run.EndTime.Sub(run.StartTime).Seconds())
+}
+
+// begin 按并发策略开始一次执行,跳过时记录执行历史并返回false。
+// cancel与执行在同一临界区内登记,保证被替换的执行一定能被取消
+func (e *executor) begin(key string, policy ConcurrencyPolicy, cancel context.CancelFunc) (*execution, bool) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	exec := &execution{cancel: cancel, run: JobRun{
+		ID:        atomic.AddInt64(&e.seq, 1),
+		Key:       key,
+		StartTime: time.Now(),
+		Status:    RunRunning,
+	}}
+	running := e.running[key]
+	switch {
+	case len(running) == 0:
+	case policy == ConcurrencyForbid:
+		exec.run.EndTime, exec.run.Status = exec.run.StartTime, RunSkipped
+		exec.run.Error = "previous run is still running"
+		e.ring(key).push(exec.run)
+		return nil, false
+	case policy == ConcurrencyReplace:
+		for _, r := range running {
+			atomic.StoreUint32(&r.replaced, 1)
+			r.cancel()
+		}
+	}
+	e.running[key] = append(running, exec)
+	return exec, true
+}
+
+func (e *executor) end(exec *execution, status RunStatus, errMsg string) JobRun {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	key := exec.run.Key
+	running := e.running[key]
+	for i, r := range running {
+		if r == exec {
+			running = append(running[:i], running[i+1:]...)
+			break
+		}
+	}
+	if len(running) == 0 {
+		delete(e.running, key)
+	} else {
+		e.running[key] = running
+	}
+	run := exec.run
+	run.EndTime = time.Now()
+	run.Duration = run.EndTime.Sub(run.StartTime).Milliseconds()
+	run.Status, run.Error = status, errMsg
+	if atomic.LoadUint32(&exec.discarded) == 0 {
+		e.ring(key).push(run)
+	}
+	return run
+}
+
+func (e *executor) ring(key string) *runRing {
+	r, ok := e.history[key]
+	if !ok {
+		r = &runRing{runs: make([]JobRun, 0, e.size)}
+		e.history[key] = r
+	}
+	return r
+}
+
+// runs 返回正在执行及最近的执行记录,按开始时间由新到旧排列
+func (e *executor) runs(key string) []JobRun {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	running := e.running[key]
+	runs := make([]JobRun, 0, len(running)+e.size)
+	for i := len(running) - 1; i >= 0; i-- {
+		if atomic.LoadUint32(&running[i].discarded) == 0 {
+			runs = append(runs, running[i].run)
+		}
+	}
+	if r, ok := e.history[key]; ok {
+		runs = append(runs, r.list()...)
+	}
+	return runs
+}
+
+// forget 删除任务的执行历史
+func (e *executor) forget(key string) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	delete(e.history, key)
+}
+
+// runRing 定长的执行记录环形缓冲区
+type runRing struct {
+	runs []JobRun
+	next int
+}
+
+func (r *runRing) push(run JobRun) {
+	if len(r.runs) < cap(r.runs) {
+		r.runs = append(r.runs, run)
+		return
+	}
+	r.runs[r.next] = run
+	r.next = (r.next + 1) % len(r.runs)
+}
+
+// list 由新到旧返回执行记录
+func (r *runRing) list() []JobRun {
+	runs := make([]JobRun, 0, len(r.runs))
+	for i := 0; i < len(r.runs); i++ {
+		idx := (r.next - 1 - i + 2*len(r.runs)) % len(r.runs)
+		runs = append(runs, r.runs[idx])
+	}
+	return runs
+}
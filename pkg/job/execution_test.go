@@ -0,0 +1,247 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type blockJob struct {
+	key  string
+	wait time.Duration
+}
+
+func (b *blockJob) Description() string { return b.key }
+
+func (b *blockJob) Key() string { return b.key }
+
+func (b *blockJob) Execute(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(b.wait):
+	}
+}
+
+func TestExecutor_timeout(t *testing.T) {
+	e := newExecutor(0)
+	e.execute(context.Background(), WithOptions(&blockJob{key: "timeout", wait: time.Second}, WithTimeout(10*time.Millisecond)))
+	e.execute(context.Background(), &blockJob{key: "timeout"})
+
+	runs := e.runs("timeout")
+	require.Len(t, runs, 2)
+	assert.Equal(t, RunSuccess, runs[0].Status)
+	assert.Equal(t, RunTimeout, runs[1].Status)
+	assert.Less(t, runs[1].Duration, int64(500))
+}
+
+func TestExecutor_panic(t *testing.T) {
+	e := newExecutor(0)
+	e.execute(context.Background(), NewFuncJob(func(context.Context) {
+		panic("boom")
+	}))
+	var runs []JobRun
+	for key := range e.history {
+		runs = e.runs(key)
+	}
+	require.Len(t, runs, 1)
+	assert.Equal(t, RunFailed, runs[0].Status)
+	assert.Contains(t, runs[0].Error, "boom")
+}
+
+func TestExecutor_concurrency(t *testing.T) {
+	tests := []struct {
+		policy ConcurrencyPolicy
+		want   []RunStatus
+	}{
+		{ConcurrencyAllow, []RunStatus{RunSuccess, RunSuccess}},
+		{ConcurrencyForbid, []RunStatus{RunSuccess, RunSkipped}},
+		{ConcurrencyReplace, []RunStatus{RunSuccess, RunCanceled}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			e := newExecutor(0)
+			job := WithOptions(&blockJob{key: "job", wait: 100 * time.Millisecond}, WithConcurrency(tt.policy))
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e.execute(context.Background(), job)
+			}()
+			require.Eventually(t, func() bool {
+				runs := e.runs("job")
+				return len(runs) == 1 && runs[0].Status == RunRunning
+			}, time.Second, time.Millisecond)
+			e.execute(context.Background(), job)
+			wg.Wait()
+
+			runs := e.runs("job")
+			require.Len(t, runs, 2)
+			statuses := []RunStatus{runs[0].Status, runs[1].Status}
+			assert.ElementsMatch(t, tt.want, statuses)
+		})
+	}
+}
+
+func TestExecutor_replaceCancel(t *testing.T) {
+	e := newExecutor(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, ok := e.begin("job", ConcurrencyReplace, cancel)
+	require.True(t, ok)
+	// 开始执行后立即被替换,之前的执行同样被取消
+	_, ok = e.begin("job", ConcurrencyReplace, func() {})
+	require.True(t, ok)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestRunRing(t *testing.T) {
+	r := &runRing{runs: make([]JobRun, 0, 3)}
+	for i := int64(1); i <= 5; i++ {
+		r.push(JobRun{ID: i})
+	}
+	ids := make([]int64, 0, 3)
+	for _, run := range r.list() {
+		ids = append(ids, run.ID)
+	}
+	assert.Equal(t, []int64{5, 4, 3}, ids)
+}
+
+func TestRegisterAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	scheduler := NewTimeWheel(WithInterval(5 * time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, scheduler.ScheduleJob(ctx, &blockJob{key: "api"}, Every(10*time.Millisecond)))
+	go func() {
+		_ = scheduler.Start(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(scheduler.GetJobRuns("api")) > 0
+	}, time.Second, 5*time.Millisecond)
+
+	router := gin.New()
+	RegisterAPI(router, scheduler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var jobs struct {
+		Result struct {
+			List []JobInfo `json:"list"`
+		} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jobs))
+	require.Len(t, jobs.Result.List, 1)
+	assert.Equal(t, "api", jobs.Result.List[0].Key)
+	assert.Equal(t, "@every 10ms", jobs.Result.List[0].Trigger)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/api/runs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/unknown/runs", nil))
+	assert.NotEqual(t, http.StatusOK, w.Code)
+}
+
+func TestTimeWheel_admin(t *testing.T) {
+	scheduler := NewTimeWheel(WithInterval(5 * time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	job := &countJob{key: "admin"}
+	require.NoError(t, scheduler.ScheduleJob(ctx, job, Every(time.Hour)))
+	require.Error(t, scheduler.TriggerNow(ctx, "admin"))
+	go func() {
+		_ = scheduler.Start(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return scheduler.TriggerNow(ctx, "admin") == nil
+	}, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&job.count) == 1
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, scheduler.TriggerNow(ctx, "unknown"), ErrJobNotFound)
+
+	// 暂停期间不执行,恢复后执行错过的触发
+	require.NoError(t, scheduler.Pause(ctx, "admin"))
+	require.NoError(t, scheduler.Reschedule(ctx, "admin", Every(10*time.Millisecond)))
+	scheduled, err := scheduler.GetScheduledJob("admin")
+	require.NoError(t, err)
+	assert.True(t, scheduled.Paused)
+	assert.Equal(t, "@every 10ms", scheduled.Trigger.(Specifier).Spec())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&job.count))
+
+	require.NoError(t, scheduler.Resume(ctx, "admin"))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&job.count) > 2
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, scheduler.Pause(ctx, "unknown"), ErrJobNotFound)
+	assert.ErrorIs(t, scheduler.Reschedule(ctx, "unknown", Every(time.Second)), ErrJobNotFound)
+}
+
+func TestNextFireTimes(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local).UnixNano()
+	assert.Equal(t, []int64{start, start + int64(time.Minute), start + 2*int64(time.Minute)},
+		NextFireTimes(Every(time.Minute), start, 3))
+
+	trigger, err := ParseTrigger("0 0 * * *")
+	require.NoError(t, err)
+	times := NextFireTimes(trigger, start, 3)
+	require.Len(t, times, 3)
+	assert.Equal(t, time.Date(2023, 1, 3, 0, 0, 0, 0, time.Local).UnixNano(), times[2])
+
+	assert.Equal(t, []int64{start}, NextFireTimes(RunOnce(time.Minute), start, 3))
+	assert.Empty(t, NextFireTimes(Every(time.Minute), start, 0))
+}
+
+func TestRegisterAPI_admin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	scheduler := NewTimeWheel(WithInterval(5 * time.Millisecond))
+	ctx := context.Background()
+	require.NoError(t, scheduler.ScheduleJob(ctx, &blockJob{key: "admin"}, Every(time.Hour)))
+	router := gin.New()
+	RegisterAPI(router, scheduler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/jobs/admin/pause", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/jobs/admin/schedule",
+		strings.NewReader(`{"spec":"0 0 * * *"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var job struct {
+		Result JobInfo `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, "0 0 * * *", job.Result.Trigger)
+	assert.True(t, job.Result.Paused)
+	assert.Len(t, job.Result.Preview, defaultPreview)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/admin?preview=2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Len(t, job.Result.Preview, 2)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/jobs/admin/schedule",
+		strings.NewReader(`{"spec":"bad spec"}`)))
+	assert.NotEqual(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/jobs/unknown/resume", nil))
+	assert.NotEqual(t, http.StatusNoContent, w.Code)
+}
@@ -0,0 +1,31 @@
+package job
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_runs_total",
+		Help: "scheduled job runs counter.",
+	}, []string{"key", "status"})
+	jobRunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_run_duration_seconds",
+		Help:    "scheduled job run duration in seconds.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"key"})
+	jobRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "job_running",
+		Help: "scheduled job running instances.",
+	}, []string{"key"})
+)
+
+// RegisterMetrics 注册任务执行的指标
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{jobRunsTotal, jobRunDuration, jobRunning} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
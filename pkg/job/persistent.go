@@ -0,0 +1,401 @@
+package job
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"template/pkg/conc/pool"
+	"template/pkg/logger"
+)
+
+type PersistentOption func(*persistentScheduler)
+
+// WithMisfirePolicy 设置默认的错过触发处理策略,默认为 MisfireFireNow
+func WithMisfirePolicy(policy MisfirePolicy) PersistentOption {
+	return func(p *persistentScheduler) {
+		p.misfirePolicy = policy
+	}
+}
+
+// WithMisfireThreshold 超过下次触发时间该时长后视为错过触发,默认为1秒
+func WithMisfireThreshold(threshold time.Duration) PersistentOption {
+	return func(p *persistentScheduler) {
+		p.misfireThreshold = threshold
+	}
+}
+
+// NewPersistentScheduler 持久化任务的调度器,实现 PersistentJob 且触发器实现 Specifier 的任务
+// 会保存至 store,启动时重新加载未调度的任务,并按错过触发的处理策略补偿执行
+func NewPersistentScheduler(runtime SchedulerRuntime, store JobStore, opts ...PersistentOption) SchedulerRuntime {
+	p := &persistentScheduler{
+		SchedulerRuntime: runtime,
+		store:            store,
+		misfirePolicy:    MisfireFireNow,
+		misfireThreshold: time.Second,
+		nowFunc:          func() int64 { return time.Now().UnixNano() },
+		pending:          make(map[string]*fireTime),
+		notify:           make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+type persistentScheduler struct {
+	SchedulerRuntime
+	store            JobStore
+	misfirePolicy    MisfirePolicy
+	misfireThreshold time.Duration
+	nowFunc          func() int64
+
+	// 待写入存储的触发时间,避免在时间轮的调度循环中访问存储
+	mux     sync.Mutex
+	pending map[string]*fireTime
+	notify  chan struct{}
+}
+
+// fireTime 任务触发时间的变更
+type fireTime struct {
+	last, next int64
+	// 一次性任务已触发完成
+	done bool
+}
+
+func (p *persistentScheduler) Start(ctx context.Context) error {
+	if err := p.load(ctx); err != nil {
+		return err
+	}
+	g := pool.New().WithContext(ctx).WithCancelOnError()
+	g.Go(func(ctx context.Context) error {
+		p.sync(ctx)
+		return nil
+	})
+	g.Go(func(ctx context.Context) error {
+		return p.SchedulerRuntime.Start(ctx)
+	})
+	return g.Wait()
+}
+
+// load 加载存储中尚未调度的任务
+func (p *persistentScheduler) load(ctx context.Context) error {
+	records, err := p.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if p.SchedulerRuntime.Has(record.Key) {
+			continue
+		}
+		factory, ok := lookupJobFactory(record.Factory)
+		if !ok {
+			logger.From(ctx).Warn("job factory not registered", zap.String("key", record.Key),
+				zap.String("factory", record.Factory))
+			continue
+		}
+		job, err := factory(record.Key, record.Data)
+		if err != nil {
+			return errors.Wrapf(err, "restore job %s", record.Key)
+		}
+		trigger, err := p.restoreTrigger(record)
+		if err != nil {
+			return errors.Wrapf(err, "restore trigger of job %s", record.Key)
+		}
+		if trigger == nil {
+			if err = p.store.Delete(ctx, record.Key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = p.SchedulerRuntime.ScheduleJob(ctx, p.wrapJob(job), p.wrapTrigger(record.Key, trigger)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreTrigger 根据存储的触发器描述重建触发器,并应用错过触发的处理策略,
+// 返回nil表示任务无需再调度
+func (p *persistentScheduler) restoreTrigger(record *StoredJob) (Trigger, error) {
+	if isOneShot(record.Trigger) {
+		// 一次性任务已触发过,或无法确定触发时间
+		if record.NextFireTime == 0 {
+			return nil, nil
+		}
+		trigger := RunAt(record.NextFireTime)
+		if p.misfired(record) && record.Misfire == MisfireSkip {
+			return nil, nil
+		}
+		return trigger, nil
+	}
+	trigger, err := ParseTrigger(record.Trigger)
+	if err != nil {
+		return nil, err
+	}
+	return p.misfire(record, trigger), nil
+}
+
+// misfire 错过触发时按策略在前面追加立即执行的次数
+func (p *persistentScheduler) misfire(record *StoredJob, trigger Trigger) Trigger {
+	if !p.misfired(record) {
+		return trigger
+	}
+	switch record.Misfire {
+	case MisfireSkip:
+		return trigger
+	case MisfireFireAll:
+		now, missed := p.nowFunc(), 0
+		for next := record.NextFireTime; next <= now && missed < maxMisfires; {
+			missed++
+			n, err := trigger.NextFireTime(next)
+			if err != nil || n <= next {
+				break
+			}
+			next = n
+		}
+		return &misfireTrigger{Trigger: trigger, pending: missed}
+	default:
+		return &misfireTrigger{Trigger: trigger, pending: 1}
+	}
+}
+
+// isOneShot 是否为 RunOnce 或 RunAt 的触发器
+func isOneShot(spec string) bool {
+	return strings.HasPrefix(spec, "@delay ") || strings.HasPrefix(spec, "@at ")
+}
+
+func (p *persistentScheduler) misfired(record *StoredJob) bool {
+	return record.NextFireTime > 0 && record.NextFireTime+p.misfireThreshold.Nanoseconds() < p.nowFunc()
+}
+
+func (p *persistentScheduler) ScheduleJob(ctx context.Context, job Job, trigger Trigger) error {
+	j, ok := unwrapJob(job, func(j Job) bool {
+		_, ok := j.(PersistentJob)
+		return ok
+	})
+	spec, isSpec := trigger.(Specifier)
+	if !ok || !isSpec {
+		// WithCalendar 等装饰的触发器无法用描述表示,任务仅在内存中调度
+		if ok {
+			logger.From(ctx).Warn("trigger is not a Specifier, job will not be persisted",
+				zap.String("key", job.Key()), zap.String("trigger", trigger.Description()))
+		}
+		return p.SchedulerRuntime.ScheduleJob(ctx, job, trigger)
+	}
+	if p.SchedulerRuntime.Has(job.Key()) {
+		return errors.Errorf("found key %s", job.Key())
+	}
+	factory, data := j.(PersistentJob).Factory()
+	record := &StoredJob{
+		Key:         job.Key(),
+		Description: job.Description(),
+		Factory:     factory,
+		Data:        data,
+		Trigger:     spec.Spec(),
+		Misfire:     p.misfirePolicy,
+	}
+	if m, ok := unwrapJob(job, func(j Job) bool {
+		_, ok := j.(Misfirer)
+		return ok
+	}); ok {
+		record.Misfire = m.(Misfirer).MisfirePolicy()
+	}
+	// 重启后重新注册的周期任务沿用存储的触发时间
+	stored, err := p.store.Get(ctx, record.Key)
+	switch {
+	case err == nil && stored.Trigger == record.Trigger && !isOneShot(record.Trigger):
+		record.LastFireTime, record.NextFireTime = stored.LastFireTime, stored.NextFireTime
+		trigger = p.misfire(record, trigger)
+	case err != nil && !errors.Is(err, ErrJobNotFound):
+		return err
+	}
+	p.mux.Lock()
+	delete(p.pending, record.Key)
+	p.mux.Unlock()
+	if err = p.store.Save(ctx, record); err != nil {
+		return err
+	}
+	return p.SchedulerRuntime.ScheduleJob(ctx, p.wrapJob(job), p.wrapTrigger(record.Key, trigger))
+}
+
+func (p *persistentScheduler) GetScheduledJob(key string) (*ScheduledJob, error) {
+	scheduledJob, err := p.SchedulerRuntime.GetScheduledJob(key)
+	if err != nil {
+		return nil, err
+	}
+	if j, ok := scheduledJob.Job.(*persistJob); ok {
+		scheduledJob.Job = j.Job
+	}
+	if t, ok := scheduledJob.Trigger.(*persistTrigger); ok {
+		scheduledJob.Trigger = t.Trigger
+	}
+	if t, ok := scheduledJob.Trigger.(*misfireTrigger); ok {
+		scheduledJob.Trigger = t.Trigger
+	}
+	return scheduledJob, nil
+}
+
+func (p *persistentScheduler) DeleteJob(ctx context.Context, key string) error {
+	if err := p.SchedulerRuntime.DeleteJob(ctx, key); err != nil {
+		return err
+	}
+	p.mux.Lock()
+	delete(p.pending, key)
+	p.mux.Unlock()
+	return p.store.Delete(ctx, key)
+}
+
+// Reschedule 替换任务的触发器,持久化的任务同时更新存储中的触发器描述
+func (p *persistentScheduler) Reschedule(ctx context.Context, key string, trigger Trigger) error {
+	stored, err := p.store.Get(ctx, key)
+	if errors.Is(err, ErrJobNotFound) {
+		return p.SchedulerRuntime.Reschedule(ctx, key, trigger)
+	}
+	if err != nil {
+		return err
+	}
+	spec, ok := trigger.(Specifier)
+	if !ok {
+		return errors.Errorf("trigger of persistent job %s must implement Specifier", key)
+	}
+	record := *stored
+	record.Trigger, record.NextFireTime = spec.Spec(), 0
+	p.mux.Lock()
+	delete(p.pending, key)
+	p.mux.Unlock()
+	if err = p.store.Save(ctx, &record); err != nil {
+		return err
+	}
+	if err = p.SchedulerRuntime.Reschedule(ctx, key, p.wrapTrigger(key, trigger)); err != nil {
+		if saveErr := p.store.Save(ctx, stored); saveErr != nil {
+			logger.From(ctx).Error("restore job record", zap.String("key", key), zap.Error(saveErr))
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *persistentScheduler) wrapJob(job Job) Job {
+	return &persistJob{Job: job, scheduler: p}
+}
+
+func (p *persistentScheduler) wrapTrigger(key string, trigger Trigger) Trigger {
+	return &persistTrigger{Trigger: trigger, key: key, scheduler: p}
+}
+
+// activeRuntime 集群中仅部分实例执行的调度器,例如 clusterScheduler
+type activeRuntime interface {
+	// active 本实例是否执行该任务
+	active(key string) bool
+}
+
+// record 记录触发时间的变更,由 sync 异步写入存储。
+// 集群中未执行该任务的实例不记录,避免其丢弃的触发覆盖leader的触发时间或删除一次性任务
+func (p *persistentScheduler) record(key string, update func(*fireTime)) {
+	if r, ok := p.SchedulerRuntime.(activeRuntime); ok && !r.active(key) {
+		return
+	}
+	p.mux.Lock()
+	ft, ok := p.pending[key]
+	if !ok {
+		ft = &fireTime{}
+		p.pending[key] = ft
+	}
+	update(ft)
+	p.mux.Unlock()
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// sync 将触发时间的变更写入存储,退出前写入剩余的变更
+func (p *persistentScheduler) sync(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			p.flush(flushCtx)
+			cancel()
+			return
+		case <-p.notify:
+			p.flush(ctx)
+		}
+	}
+}
+
+func (p *persistentScheduler) flush(ctx context.Context) {
+	p.mux.Lock()
+	pending := p.pending
+	p.pending = make(map[string]*fireTime, len(pending))
+	p.mux.Unlock()
+	for key, ft := range pending {
+		var err error
+		if ft.done {
+			err = p.store.Delete(ctx, key)
+		} else {
+			err = p.store.UpdateFireTime(ctx, key, ft.last, ft.next)
+		}
+		if err != nil {
+			logger.From(ctx).Error("persist job fire time", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+type persistJob struct {
+	Job
+	scheduler *persistentScheduler
+}
+
+func (j *persistJob) Unwrap() Job {
+	return j.Job
+}
+
+func (j *persistJob) Execute(ctx context.Context) {
+	now := j.scheduler.nowFunc()
+	j.scheduler.record(j.Key(), func(ft *fireTime) {
+		ft.last = now
+	})
+	j.Job.Execute(ctx)
+}
+
+// persistTrigger 记录下次触发时间
+type persistTrigger struct {
+	Trigger
+	key       string
+	scheduler *persistentScheduler
+}
+
+func (t *persistTrigger) NextFireTime(prev int64) (int64, error) {
+	next, err := t.Trigger.NextFireTime(prev)
+	switch {
+	case err == nil:
+		t.scheduler.record(t.key, func(ft *fireTime) {
+			ft.next = next
+		})
+	case errors.Is(err, ErrSkipScheduleJob):
+		t.scheduler.record(t.key, func(ft *fireTime) {
+			ft.done = true
+		})
+	}
+	return next, err
+}
+
+// misfireTrigger 先立即触发 pending 次,之后按原触发器调度
+type misfireTrigger struct {
+	Trigger
+	pending int
+}
+
+func (t *misfireTrigger) NextFireTime(prev int64) (int64, error) {
+	if t.pending > 0 {
+		t.pending--
+		return prev, nil
+	}
+	return t.Trigger.NextFireTime(prev)
+}
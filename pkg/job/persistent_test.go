@@ -0,0 +1,217 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore 进程内的任务存储
+type memStore struct {
+	mux  sync.Mutex
+	jobs map[string]StoredJob
+}
+
+func newMemStore() *memStore {
+	return &memStore{jobs: make(map[string]StoredJob)}
+}
+
+func (m *memStore) Save(_ context.Context, job *StoredJob) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.jobs[job.Key] = *job
+	return nil
+}
+
+func (m *memStore) Get(_ context.Context, key string) (*StoredJob, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	job, ok := m.jobs[key]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return &job, nil
+}
+
+func (m *memStore) List(context.Context) ([]*StoredJob, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	list := make([]*StoredJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		job := job
+		list = append(list, &job)
+	}
+	return list, nil
+}
+
+func (m *memStore) Delete(_ context.Context, key string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.jobs, key)
+	return nil
+}
+
+func (m *memStore) UpdateFireTime(_ context.Context, key string, last, next int64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	job, ok := m.jobs[key]
+	if !ok {
+		return nil
+	}
+	if last > 0 {
+		job.LastFireTime = last
+	}
+	if next > 0 {
+		job.NextFireTime = next
+	}
+	m.jobs[key] = job
+	return nil
+}
+
+var persistCount int64
+
+type persistCountJob struct {
+	key string
+}
+
+func (p *persistCountJob) Description() string { return p.key }
+
+func (p *persistCountJob) Key() string { return p.key }
+
+func (p *persistCountJob) Execute(context.Context) {
+	atomic.AddInt64(&persistCount, 1)
+}
+
+func (p *persistCountJob) Factory() (string, []byte) {
+	return "persist_count", nil
+}
+
+func init() {
+	RegisterJobFactory("persist_count", func(key string, _ []byte) (Job, error) {
+		return &persistCountJob{key: key}, nil
+	})
+}
+
+func TestTriggerSpec(t *testing.T) {
+	triggers := []Trigger{
+		Every(90 * time.Second),
+		RunAt(time.Now().UnixNano()),
+		RunOnce(time.Minute),
+	}
+	for _, spec := range []string{"*/5 * * * * *", "0 0 1 * *", "CRON_TZ=Asia/Shanghai 0 8 * * *"} {
+		trigger, err := ParseTrigger(spec)
+		require.NoError(t, err)
+		assert.Equal(t, spec, trigger.(Specifier).Spec())
+		triggers = append(triggers, trigger)
+	}
+	for _, trigger := range triggers {
+		spec := trigger.(Specifier).Spec()
+		restored, err := ParseTrigger(spec)
+		require.NoError(t, err, spec)
+		assert.Equal(t, spec, restored.(Specifier).Spec())
+	}
+}
+
+func TestPersistentScheduler_decorated(t *testing.T) {
+	store := newMemStore()
+	p := NewPersistentScheduler(NewTimeWheel(), store)
+	ctx := context.Background()
+	require.NoError(t, p.ScheduleJob(ctx, &persistCountJob{key: "calendar"},
+		WithCalendar(Every(time.Minute), Window{})))
+	require.NoError(t, p.ScheduleJob(ctx, &persistCountJob{key: "every"}, Every(time.Minute)))
+	// 装饰的触发器不实现 Specifier,任务仅在内存中调度
+	assert.True(t, p.Has("calendar"))
+	_, err := store.Get(ctx, "calendar")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = store.Get(ctx, "every")
+	assert.NoError(t, err)
+}
+
+func TestPersistentScheduler_misfire(t *testing.T) {
+	now := time.Now().UnixNano()
+	record := &StoredJob{Trigger: "@every 1m", NextFireTime: now - (10*time.Minute - time.Second).Nanoseconds()}
+	tests := []struct {
+		policy  MisfirePolicy
+		pending int
+	}{
+		{MisfireFireNow, 1},
+		{MisfireSkip, 0},
+		{MisfireFireAll, 10},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			p := NewPersistentScheduler(NewTimeWheel(), newMemStore()).(*persistentScheduler)
+			p.nowFunc = func() int64 { return now }
+			record.Misfire = tt.policy
+			trigger, err := p.restoreTrigger(record)
+			require.NoError(t, err)
+			pending := 0
+			if m, ok := trigger.(*misfireTrigger); ok {
+				pending = m.pending
+			}
+			assert.Equal(t, tt.pending, pending)
+		})
+	}
+
+	// 错过触发的一次性任务
+	p := NewPersistentScheduler(NewTimeWheel(), newMemStore(), WithMisfirePolicy(MisfireSkip)).(*persistentScheduler)
+	trigger, err := p.restoreTrigger(&StoredJob{Trigger: "@delay 1m", NextFireTime: now - time.Hour.Nanoseconds(), Misfire: MisfireSkip})
+	require.NoError(t, err)
+	assert.Nil(t, trigger)
+	trigger, err = p.restoreTrigger(&StoredJob{Trigger: "@delay 1m", NextFireTime: now - time.Hour.Nanoseconds(), Misfire: MisfireFireNow})
+	require.NoError(t, err)
+	assert.NotNil(t, trigger)
+}
+
+func TestPersistentScheduler_reload(t *testing.T) {
+	store := newMemStore()
+	ctx := context.Background()
+	atomic.StoreInt64(&persistCount, 0)
+
+	// 未持久化的任务及持久化的任务
+	first := NewPersistentScheduler(NewTimeWheel(WithInterval(5*time.Millisecond)), store)
+	require.NoError(t, first.ScheduleJob(ctx, NewFuncJob(func(context.Context) {}), Every(time.Hour)))
+	require.NoError(t, first.ScheduleJob(ctx, &persistCountJob{key: "every"}, Every(20*time.Millisecond)))
+	require.NoError(t, first.ScheduleJob(ctx, &persistCountJob{key: "once"}, RunOnce(10*time.Millisecond)))
+	require.Error(t, first.ScheduleJob(ctx, &persistCountJob{key: "every"}, Every(time.Second)))
+	list, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	scheduled, err := first.GetScheduledJob("every")
+	require.NoError(t, err)
+	assert.Equal(t, &persistCountJob{key: "every"}, scheduled.Job)
+	assert.Equal(t, "@every 20ms", scheduled.Trigger.(Specifier).Spec())
+
+	// 模拟重启,由新的调度器加载存储中的任务
+	second := NewPersistentScheduler(NewTimeWheel(WithInterval(5*time.Millisecond)), store)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		_ = second.Start(runCtx)
+		close(done)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	assert.ElementsMatch(t, []string{"every"}, second.GetJobKeys())
+	assert.Positive(t, atomic.LoadInt64(&persistCount))
+	record, err := store.Get(ctx, "every")
+	require.NoError(t, err)
+	assert.Positive(t, record.LastFireTime)
+	assert.Greater(t, record.NextFireTime, record.LastFireTime)
+	// 一次性任务执行后从存储中删除
+	_, err = store.Get(ctx, "once")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	require.NoError(t, second.DeleteJob(ctx, "every"))
+	cancel()
+	<-done
+	_, err = store.Get(ctx, "every")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -8,6 +8,10 @@ import (
 type ScheduledJob struct {
 	Job     Job
 	Trigger Trigger
+	// NextFireTime is the next time at which the job is scheduled to fire.
+	NextFireTime int64
+	// Paused reports whether the job is paused.
+	Paused bool
 }
 
 // SchedulerRuntime represents a Job orchestrator.
@@ -29,6 +33,21 @@ type SchedulerRuntime interface {
 	// DeleteJob removes the job with the specified key from the SchedulerRuntime execution queue.
 	DeleteJob(ctx context.Context, key string) error
 
+	// GetJobRuns returns the running and recent runs of the job with the specified key, newest first.
+	GetJobRuns(key string) []JobRun
+
+	// Pause pauses the job with the specified key, the job will not be executed until it is resumed.
+	Pause(ctx context.Context, key string) error
+
+	// Resume resumes the paused job with the specified key, missed fires are executed once.
+	Resume(ctx context.Context, key string) error
+
+	// TriggerNow executes the job with the specified key immediately without affecting its schedule.
+	TriggerNow(ctx context.Context, key string) error
+
+	// Reschedule replaces the trigger of the job with the specified key.
+	Reschedule(ctx context.Context, key string, trigger Trigger) error
+
 	// Has Looks up an item under specified key.
 	Has(key string) bool
 }
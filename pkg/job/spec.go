@@ -2,6 +2,7 @@ package job
 
 import (
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -10,10 +11,91 @@ import (
 type specTrigger struct {
 	Second, Minute, Hour, Dom, Month, Dow uint64
 
+	// Years are the sorted years to fire in, nil means every year.
+	Years []int
+
 	// Override location for this schedule.
 	Location *time.Location
 	spec     string
 	next     time.Time
+
+	// Rules of L, W, # in the day of month and day of week fields.
+	domRules []domRule
+	dowRules []dowRule
+}
+
+type domRuleKind int
+
+const (
+	// lastDay is the value-th day before the last day of the month.
+	lastDay domRuleKind = iota
+	// nearestWeekday is the nearest weekday to the value-th day of the month.
+	nearestWeekday
+	// lastWeekday is the last weekday of the month.
+	lastWeekday
+)
+
+// domRule is a day of month expression which depends on the month.
+type domRule struct {
+	kind  domRuleKind
+	value int
+}
+
+// day returns the day of the month the rule matches, or 0 if none.
+func (r domRule) day(year int, month time.Month, loc *time.Location) int {
+	last := daysIn(year, month, loc)
+	switch r.kind {
+	case lastDay:
+		return last - r.value
+	case lastWeekday:
+		switch time.Date(year, month, last, 12, 0, 0, 0, loc).Weekday() {
+		case time.Saturday:
+			return last - 1
+		case time.Sunday:
+			return last - 2
+		}
+		return last
+	case nearestWeekday:
+		if r.value > last {
+			return 0
+		}
+		switch time.Date(year, month, r.value, 12, 0, 0, 0, loc).Weekday() {
+		case time.Saturday:
+			if r.value == 1 {
+				return r.value + 2
+			}
+			return r.value - 1
+		case time.Sunday:
+			if r.value == last {
+				return r.value - 2
+			}
+			return r.value + 1
+		}
+		return r.value
+	}
+	return 0
+}
+
+// dowRule is a day of week expression which depends on the month,
+// nth is the nth weekday in the month, or -1 for the last one.
+type dowRule struct {
+	weekday time.Weekday
+	nth     int
+}
+
+func (r dowRule) matches(t time.Time) bool {
+	if t.Weekday() != r.weekday {
+		return false
+	}
+	if r.nth < 0 {
+		return t.Day()+7 > daysIn(t.Year(), t.Month(), t.Location())
+	}
+	return (t.Day()-1)/7+1 == r.nth
+}
+
+// daysIn returns the number of days in the month.
+func daysIn(year int, month time.Month, loc *time.Location) int {
+	return time.Date(year, month+1, 0, 12, 0, 0, 0, loc).Day()
 }
 
 func (s *specTrigger) NextFireTime(prev int64) (int64, error) {
@@ -28,6 +110,14 @@ func (s *specTrigger) Description() string {
 	return fmt.Sprintf("cron:%s", s.spec)
 }
 
+// Spec returns the crontab spec, with the time zone if it is not local.
+func (s *specTrigger) Spec() string {
+	if s.Location == nil || s.Location == time.Local {
+		return s.spec
+	}
+	return "CRON_TZ=" + s.Location.String() + " " + s.spec
+}
+
 // bounds provides a range of acceptable values (plus a map of name to value).
 type bounds struct {
 	min, max uint
@@ -54,7 +144,8 @@ var (
 		"nov": 11,
 		"dec": 12,
 	}}
-	dow = bounds{0, 6, map[string]uint{
+	years = bounds{1970, 2099, nil}
+	dow   = bounds{0, 6, map[string]uint{
 		"sun": 0,
 		"mon": 1,
 		"tue": 2,
@@ -72,7 +163,55 @@ const (
 
 // Next returns the next time this schedule is activated, greater than the given
 // time.  If no time can be found to satisfy the schedule, return the zero time.
+//
+// Around daylight saving time transitions, schedules with fixed hours fire once
+// when the clock falls back, and fire at the same minute and second after the
+// gap when the clock springs forward over the scheduled time.
 func (s *specTrigger) Next(t time.Time) time.Time {
+	for {
+		next := s.nextTime(t)
+		if next.IsZero() || s.Hour&starBit > 0 || !s.repeated(next) {
+			return next
+		}
+		t = next
+	}
+}
+
+// repeated reports whether t is the second occurrence of its wall clock time
+// when the clock falls back. The fold is found from the zone offsets around t,
+// since the instant time.Date picks for an ambiguous time is not guaranteed.
+func (s *specTrigger) repeated(t time.Time) bool {
+	if s.Location != time.Local {
+		t = t.In(s.Location)
+	}
+	_, offset := t.Zone()
+	_, before := t.Add(-time.Hour).Zone()
+	if before <= offset {
+		return false
+	}
+	// The clock fell back by before-offset, the same wall clock time occurred
+	// that long ago if the offset was still the old one.
+	_, earlier := t.Add(-time.Duration(before-offset) * time.Second).Zone()
+	return earlier == before
+}
+
+// skippedHour reports whether the hours skipped between prev and next due to
+// daylight saving time match the schedule.
+func (s *specTrigger) skippedHour(prev, next time.Time) bool {
+	_, before := prev.Zone()
+	_, after := next.Zone()
+	if after <= before {
+		return false
+	}
+	for h := prev.Hour() + 1; h < next.Hour(); h++ {
+		if 1<<uint(h)&s.Hour > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *specTrigger) nextTime(t time.Time) time.Time {
 	// General approach
 	//
 	// For Month, Day, Hour, Minute, Second:
@@ -109,6 +248,19 @@ WRAP:
 		return time.Time{}
 	}
 
+	// Find the first applicable year.
+	if !s.yearMatches(t.Year()) {
+		year := s.nextYear(t.Year())
+		if year == 0 {
+			return time.Time{}
+		}
+		added = true
+		t = time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+		if year+5 > yearLimit {
+			yearLimit = year + 5
+		}
+	}
+
 	// Find the first applicable month.
 	// If it's this month, then do nothing.
 	for 1<<uint(t.Month())&s.Month == 0 {
@@ -155,13 +307,20 @@ WRAP:
 	for 1<<uint(t.Hour())&s.Hour == 0 {
 		if !added {
 			added = true
-			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+			// Truncate within the hour instead of using time.Date, which may
+			// pick the other instant of an ambiguous hour.
+			t = t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
 		}
+		prev := t
 		t = t.Add(1 * time.Hour)
 
 		if t.Hour() == 0 {
 			goto WRAP
 		}
+		// The scheduled hour is skipped when the clock springs forward.
+		if s.skippedHour(prev, t) {
+			break
+		}
 	}
 
 	for 1<<uint(t.Minute())&s.Minute == 0 {
@@ -198,8 +357,37 @@ func dayMatches(s *specTrigger, t time.Time) bool {
 		domMatch = 1<<uint(t.Day())&s.Dom > 0
 		dowMatch = 1<<uint(t.Weekday())&s.Dow > 0
 	)
+	for _, rule := range s.domRules {
+		if domMatch {
+			break
+		}
+		domMatch = rule.day(t.Year(), t.Month(), t.Location()) == t.Day()
+	}
+	for _, rule := range s.dowRules {
+		if dowMatch {
+			break
+		}
+		dowMatch = rule.matches(t)
+	}
 	if s.Dom&starBit > 0 || s.Dow&starBit > 0 {
 		return domMatch && dowMatch
 	}
 	return domMatch || dowMatch
 }
+
+func (s *specTrigger) yearMatches(year int) bool {
+	if s.Years == nil {
+		return true
+	}
+	i := sort.SearchInts(s.Years, year)
+	return i < len(s.Years) && s.Years[i] == year
+}
+
+// nextYear returns the first year after the given year, or 0 if none.
+func (s *specTrigger) nextYear(year int) int {
+	i := sort.SearchInts(s.Years, year+1)
+	if i == len(s.Years) {
+		return 0
+	}
+	return s.Years[i]
+}
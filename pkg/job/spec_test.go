@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCron(t *testing.T) {
@@ -65,3 +68,110 @@ func TestAt(t *testing.T) {
 	}
 	t.Log(time.Unix(p/1e9, p%1e9))
 }
+
+func TestCronSpecial(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want []time.Time
+	}{
+		{"0 0 10 L * ?", []time.Time{
+			time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 31, 10, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 10 L-2 * ?", []time.Time{
+			time.Date(2024, 1, 29, 10, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 27, 10, 0, 0, 0, time.UTC),
+		}},
+		// 2024-06-15 为星期六, 2024-09-15 为星期日
+		{"0 0 10 15W 6,9 ?", []time.Time{
+			time.Date(2024, 6, 14, 10, 0, 0, 0, time.UTC),
+			time.Date(2024, 9, 16, 10, 0, 0, 0, time.UTC),
+		}},
+		// 2024-06-01 为星期六,不跨月
+		{"0 0 10 1W 6 ?", []time.Time{
+			time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC),
+		}},
+		// 2024-03-31 为星期日, 2024-08-31 为星期六
+		{"0 0 10 LW 3,8 ?", []time.Time{
+			time.Date(2024, 3, 29, 10, 0, 0, 0, time.UTC),
+			time.Date(2024, 8, 30, 10, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 10 ? * FRI#2", []time.Time{
+			time.Date(2024, 1, 12, 10, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 9, 10, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 10 ? * 5L", []time.Time{
+			time.Date(2024, 1, 26, 10, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 23, 10, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 10 1 1 ? 2026-2030/2", []time.Time{
+			time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+			time.Date(2028, 1, 1, 10, 0, 0, 0, time.UTC),
+			time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC),
+			{},
+		}},
+		{"0 0 10 29 2 ? 2024,2040", []time.Time{
+			time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC),
+			time.Date(2040, 2, 29, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			trigger, err := ParseStandard("TZ=UTC " + tt.spec)
+			require.NoError(t, err)
+			next := start
+			for _, want := range tt.want {
+				next = trigger.(*specTrigger).Next(next)
+				assert.True(t, want.Equal(next), "want %s, got %s", want, next)
+			}
+		})
+	}
+
+	for _, spec := range []string{"0 0 10 32W * ?", "0 0 10 * * 7#1", "0 0 10 ? * 5#6", "0 0 10 L-31 * ?",
+		"0 0 10 *,L * ?", "0 0 10 1 1 ? 1969", "0 0 10 1 1 ? 2030-2025"} {
+		_, err := ParseStandard(spec)
+		assert.Error(t, err, spec)
+	}
+	// 省略秒时不支持年
+	_, err := ParseStandard("0 10 1 1 ? 2030")
+	assert.Error(t, err)
+}
+
+func TestCronDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// 夏令时开始, 2:30 不存在,在跳过后的 3:30 执行
+	trigger, err := ParseStandard("CRON_TZ=America/New_York 0 30 2 * * *")
+	require.NoError(t, err)
+	next := trigger.(*specTrigger).Next(time.Date(2023, 3, 11, 12, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2023, 3, 12, 3, 30, 0, 0, loc).Unix(), next.Unix())
+	next = trigger.(*specTrigger).Next(next)
+	assert.Equal(t, time.Date(2023, 3, 13, 2, 30, 0, 0, loc).Unix(), next.Unix())
+
+	// 夏令时结束, 1:30 出现两次,只执行一次
+	trigger, err = ParseStandard("CRON_TZ=America/New_York 0 30 1 * * *")
+	require.NoError(t, err)
+	// 使用UTC时间表示两次1:30,不依赖time.Date对歧义时间的选择
+	first := time.Date(2023, 11, 5, 5, 30, 0, 0, time.UTC)
+	next = trigger.(*specTrigger).Next(time.Date(2023, 11, 5, 0, 0, 0, 0, loc))
+	assert.Equal(t, first.Unix(), next.Unix())
+	next = trigger.(*specTrigger).Next(next)
+	assert.Equal(t, time.Date(2023, 11, 6, 1, 30, 0, 0, loc).Unix(), next.Unix())
+	// 从第一次的1:45开始,跳过第二次的1:30
+	next = trigger.(*specTrigger).Next(first.Add(15 * time.Minute))
+	assert.Equal(t, time.Date(2023, 11, 6, 1, 30, 0, 0, loc).Unix(), next.Unix())
+	// 从第二次的1:00开始,不回到第一次的1:30
+	next = trigger.(*specTrigger).Next(first.Add(30 * time.Minute))
+	assert.Equal(t, time.Date(2023, 11, 6, 1, 30, 0, 0, loc).Unix(), next.Unix())
+	assert.True(t, trigger.(*specTrigger).repeated(first.Add(time.Hour)))
+	assert.False(t, trigger.(*specTrigger).repeated(first))
+
+	// 不限制小时的任务按实际时间执行
+	trigger, err = ParseStandard("CRON_TZ=America/New_York 0 30 * * * *")
+	require.NoError(t, err)
+	next = trigger.(*specTrigger).Next(first)
+	assert.Equal(t, first.Add(time.Hour).Unix(), next.Unix())
+}
@@ -0,0 +1,103 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MisfirePolicy 调度器停止期间错过触发时间的处理策略
+type MisfirePolicy string
+
+const (
+	// MisfireFireNow 立即补偿执行一次,之后按触发器正常调度
+	MisfireFireNow MisfirePolicy = "fire_now"
+	// MisfireSkip 忽略错过的触发,从当前时间开始按触发器调度,一次性的任务不再执行
+	MisfireSkip MisfirePolicy = "skip"
+	// MisfireFireAll 补偿执行所有错过的触发,最多 maxMisfires 次
+	MisfireFireAll MisfirePolicy = "fire_all"
+)
+
+// maxMisfires MisfireFireAll 最多补偿执行的次数
+const maxMisfires = 100
+
+var ErrJobNotFound = errors.New("job not found")
+
+// StoredJob 持久化的任务
+type StoredJob struct {
+	Key          string        `json:"key" gorm:"column:job_key;primaryKey;size:191;comment:任务唯一标识"`
+	Description  string        `json:"description" gorm:"column:description;comment:任务描述"`
+	Factory      string        `json:"factory" gorm:"column:factory;comment:任务工厂名称"`
+	Data         []byte        `json:"data" gorm:"column:data;comment:任务工厂的参数"`
+	Trigger      string        `json:"trigger" gorm:"column:trigger_spec;comment:触发器描述"`
+	Misfire      MisfirePolicy `json:"misfire" gorm:"column:misfire;comment:错过触发的处理策略"`
+	LastFireTime int64         `json:"last_fire_time" gorm:"column:last_fire_time;comment:上次触发时间(纳秒)"`
+	NextFireTime int64         `json:"next_fire_time" gorm:"column:next_fire_time;comment:下次触发时间(纳秒)"`
+	CreatedAt    time.Time     `json:"created_at" gorm:"column:created_at;comment:创建时间"`
+	UpdatedAt    time.Time     `json:"updated_at" gorm:"column:updated_at;comment:更新时间"`
+}
+
+func (StoredJob) TableName() string {
+	return "scheduled_job"
+}
+
+// JobStore 任务的持久化存储
+type JobStore interface {
+	// Save 保存任务,已存在时覆盖
+	Save(ctx context.Context, job *StoredJob) error
+	// Get 获取任务,不存在时返回 ErrJobNotFound
+	Get(ctx context.Context, key string) (*StoredJob, error)
+	// List 获取所有任务
+	List(ctx context.Context) ([]*StoredJob, error)
+	// Delete 删除任务
+	Delete(ctx context.Context, key string) error
+	// UpdateFireTime 更新任务的触发时间,值为0时不更新
+	UpdateFireTime(ctx context.Context, key string, last, next int64) error
+}
+
+// PersistentJob 可持久化的任务,通过注册的任务工厂及参数在重启后重建
+type PersistentJob interface {
+	Job
+	// Factory 返回任务工厂的名称及参数
+	Factory() (name string, data []byte)
+}
+
+// Misfirer 指定任务错过触发时的处理策略,未实现时使用调度器的默认策略
+type Misfirer interface {
+	MisfirePolicy() MisfirePolicy
+}
+
+// JobFactory 根据任务的key及参数重建任务
+type JobFactory func(key string, data []byte) (Job, error)
+
+var factories sync.Map
+
+// RegisterJobFactory 注册任务工厂,需在调度器启动前注册
+func RegisterJobFactory(name string, factory JobFactory) {
+	factories.Store(name, factory)
+}
+
+func lookupJobFactory(name string) (JobFactory, bool) {
+	factory, ok := factories.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return factory.(JobFactory), true
+}
+
+// unwrapJob 沿 Unwrap 链查找满足 match 的任务
+func unwrapJob(job Job, match func(Job) bool) (Job, bool) {
+	for job != nil {
+		if match(job) {
+			return job, true
+		}
+		u, ok := job.(interface{ Unwrap() Job })
+		if !ok {
+			break
+		}
+		job = u.Unwrap()
+	}
+	return nil, false
+}
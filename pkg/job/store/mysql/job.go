@@ -0,0 +1,78 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"template/pkg/job"
+)
+
+// NewJobStore 基于数据库的任务存储
+func NewJobStore(db *gorm.DB) job.JobStore {
+	return &jobStore{
+		DB: db,
+	}
+}
+
+type jobStore struct {
+	*gorm.DB
+}
+
+// Save 保存任务,已存在时覆盖
+func (s *jobStore) Save(ctx context.Context, data *job.StoredJob) error {
+	if err := s.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(data).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Get 根据key获取任务
+func (s *jobStore) Get(ctx context.Context, key string) (*job.StoredJob, error) {
+	var obj job.StoredJob
+	if err := s.WithContext(ctx).Where("job_key = ?", key).First(&obj).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(job.ErrJobNotFound)
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &obj, nil
+}
+
+// List 获取所有任务
+func (s *jobStore) List(ctx context.Context) ([]*job.StoredJob, error) {
+	var list []*job.StoredJob
+	if err := s.WithContext(ctx).Order("job_key").Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+// Delete 根据key删除任务
+func (s *jobStore) Delete(ctx context.Context, key string) error {
+	if err := s.WithContext(ctx).Where("job_key = ?", key).Delete(&job.StoredJob{}).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// UpdateFireTime 更新任务的触发时间,值为0时不更新
+func (s *jobStore) UpdateFireTime(ctx context.Context, key string, last, next int64) error {
+	values := make(map[string]interface{}, 2)
+	if last > 0 {
+		values["last_fire_time"] = last
+	}
+	if next > 0 {
+		values["next_fire_time"] = next
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if err := s.WithContext(ctx).Model(&job.StoredJob{}).Where("job_key = ?", key).
+		Updates(values).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
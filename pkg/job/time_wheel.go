@@ -15,6 +15,8 @@ import (
 
 type delayTask struct {
 	delay int64
+	// 下次触发的时间
+	next int64
 	Job
 	Trigger
 }
@@ -22,6 +24,7 @@ type delayTask struct {
 type entry struct {
 	delayTask
 	circle int
+	pos    int
 }
 
 func WithInterval(interval time.Duration) Option {
@@ -36,10 +39,18 @@ func WithSlot(slotNum int) Option {
 	}
 }
 
+// WithHistorySize 设置每个任务保留的执行记录数,默认为20
+func WithHistorySize(size int) Option {
+	return func(o *option) {
+		o.historySize = size
+	}
+}
+
 type option struct {
-	interval time.Duration
-	slotNum  int
-	nowFunc  func() int64
+	interval    time.Duration
+	slotNum     int
+	historySize int
+	nowFunc     func() int64
 }
 
 type Option func(*option)
@@ -63,7 +74,10 @@ func NewTimeWheel(opts ...Option) SchedulerRuntime {
 		slotSum:           o.slotNum,
 		addTaskChannel:    make(chan *entry),
 		removeTaskChannel: make(chan string),
+		opChannel:         make(chan *op),
+		paused:            cmap.New(),
 		nowFunc:           o.nowFunc,
+		executor:          newExecutor(o.historySize),
 	}
 	for i := 0; i < t.slotSum; i++ {
 		t.slots[i] = list.New()
@@ -74,15 +88,19 @@ func NewTimeWheel(opts ...Option) SchedulerRuntime {
 type timeWheel struct {
 	interval time.Duration // 指针每隔多久往前移动一格
 	slots    []*list.List  // 时间轮槽
-	// key: 定时器唯一标识 value: 定时器所在的槽, 主要用于删除定时器
+	// key: 定时器唯一标识 value: 定时器, 记录所在的槽, 主要用于删除定时器
 	timerMap          cmap.ConcurrentMap
 	cur               int         // 当前指针指向哪一个槽
 	slotSum           int         // 槽数量
 	addTaskChannel    chan *entry // 新增任务channel
 	removeTaskChannel chan string // 删除任务channel
+	opChannel         chan *op    // 在调度循环中执行的操作
+	// key: 暂停的定时器唯一标识
+	paused cmap.ConcurrentMap
 
-	pool    *pool.ContextPool
-	nowFunc func() int64
+	pool     *pool.ContextPool
+	nowFunc  func() int64
+	executor *executor
 
 	running uint32
 }
@@ -105,6 +123,8 @@ func (t *timeWheel) Start(ctx context.Context) error {
 			t.addTask(task)
 		case key := <-t.removeTaskChannel:
 			t.removeJob(key)
+		case o := <-t.opChannel:
+			o.done <- o.f()
 		}
 	}
 }
@@ -121,7 +141,8 @@ func (t *timeWheel) getPositionAndCircle(d int64) (int, int) {
 func (t *timeWheel) addTask(task *entry) {
 	pos, circle := t.getPositionAndCircle(task.delay)
 	task.circle = circle
-	t.timerMap.Set(task.Key(), pos)
+	task.pos = pos
+	t.timerMap.Set(task.Key(), task)
 	t.slots[pos].PushBack(task)
 }
 
@@ -157,28 +178,17 @@ func (t *timeWheel) GetScheduledJob(key string) (*ScheduledJob, error) {
 	if !found {
 		return nil, fmt.Errorf("not found key %s", key)
 	}
-	pos, ok := value.(int)
+	// 仅读取不变的任务及触发器, 槽中的链表只在调度循环中访问
+	task, ok := value.(*entry)
 	if !ok {
 		return nil, fmt.Errorf("invalid value %v", value)
 	}
-	l := t.slots[pos]
-	for e := l.Front(); e != nil; {
-		task, ok := e.Value.(*entry)
-		if !ok {
-			next := e.Next()
-			l.Remove(e)
-			e = next
-			continue
-		}
-		if task.Key() == key {
-			return &ScheduledJob{
-				Job:     task.Job,
-				Trigger: task.Trigger,
-			}, nil
-		}
-		e = e.Next()
-	}
-	return nil, fmt.Errorf("not found key %s in slots", key)
+	return &ScheduledJob{
+		Job:          task.Job,
+		Trigger:      task.Trigger,
+		NextFireTime: task.next,
+		Paused:       t.paused.Has(key),
+	}, nil
 }
 
 func (t *timeWheel) DeleteJob(ctx context.Context, key string) error {
@@ -194,6 +204,10 @@ func (t *timeWheel) DeleteJob(ctx context.Context, key string) error {
 	}
 }
 
+func (t *timeWheel) GetJobRuns(key string) []JobRun {
+	return t.executor.runs(key)
+}
+
 func (t *timeWheel) Has(key string) bool {
 	return t.timerMap.Has(key)
 }
@@ -204,11 +218,11 @@ func (t *timeWheel) removeJob(key string) {
 	if !found {
 		return
 	}
-	pos, ok := value.(int)
+	task, ok := value.(*entry)
 	if !ok {
 		return
 	}
-	l := t.slots[pos]
+	l := t.slots[task.pos]
 	for e := l.Front(); e != nil; {
 		task, ok := e.Value.(*entry)
 		if !ok {
@@ -223,6 +237,8 @@ func (t *timeWheel) removeJob(key string) {
 			l.Remove(e)
 			// 删除位置信息
 			t.timerMap.Remove(key)
+			t.paused.Remove(key)
+			t.executor.forget(key)
 			return
 		}
 		e = e.Next()
@@ -250,13 +266,20 @@ func (t *timeWheel) scanAndRunTask(l *list.List) {
 			e = e.Next()
 			continue
 		}
+		next := e.Next()
+		l.Remove(e)
+		// 暂停的任务在下一格继续等待, 恢复后执行
+		if t.paused.Has(task.Key()) {
+			task.delay = t.interval.Nanoseconds()
+			t.addTask(task)
+			e = next
+			continue
+		}
 		// 任务执行
 		t.pool.Go(func(ctx context.Context) error {
-			task.Execute(ctx)
+			t.executor.execute(ctx, task.Job)
 			return nil
 		})
-		next := e.Next()
-		l.Remove(e)
 		// 重新调度
 		t.moveTask(task)
 		e = next
@@ -277,6 +300,7 @@ func (t *timeWheel) createTask(job Job, trigger Trigger) (*entry, error) {
 	return &entry{
 		delayTask: delayTask{
 			delay:   delay,
+			next:    now + delay,
 			Job:     job,
 			Trigger: trigger,
 		},
@@ -294,3 +318,84 @@ func (t *timeWheel) moveTask(task *entry) {
 	}
 	t.addTask(taskValue)
 }
+
+// op 在调度循环中执行的操作
+type op struct {
+	f    func() error
+	done chan error
+}
+
+// do 在调度循环中执行f, 未运行时直接执行
+func (t *timeWheel) do(ctx context.Context, f func() error) error {
+	if atomic.LoadUint32(&t.running) == 0 {
+		return f()
+	}
+	o := &op{f: f, done: make(chan error, 1)}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case t.opChannel <- o:
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-o.done:
+		return err
+	}
+}
+
+func (t *timeWheel) Pause(_ context.Context, key string) error {
+	if !t.timerMap.Has(key) {
+		return errors.Wrapf(ErrJobNotFound, "key %s", key)
+	}
+	t.paused.Set(key, struct{}{})
+	return nil
+}
+
+func (t *timeWheel) Resume(_ context.Context, key string) error {
+	if !t.timerMap.Has(key) {
+		return errors.Wrapf(ErrJobNotFound, "key %s", key)
+	}
+	t.paused.Remove(key)
+	return nil
+}
+
+func (t *timeWheel) TriggerNow(ctx context.Context, key string) error {
+	if atomic.LoadUint32(&t.running) == 0 {
+		return errors.New("scheduler is not running")
+	}
+	return t.do(ctx, func() error {
+		value, found := t.timerMap.Get(key)
+		if !found {
+			return errors.Wrapf(ErrJobNotFound, "key %s", key)
+		}
+		task := value.(*entry)
+		t.pool.Go(func(ctx context.Context) error {
+			t.executor.execute(ctx, task.Job)
+			return nil
+		})
+		return nil
+	})
+}
+
+func (t *timeWheel) Reschedule(ctx context.Context, key string, trigger Trigger) error {
+	return t.do(ctx, func() error {
+		value, found := t.timerMap.Get(key)
+		if !found {
+			return errors.Wrapf(ErrJobNotFound, "key %s", key)
+		}
+		task, err := t.createTask(value.(*entry).Job, trigger)
+		if err != nil {
+			return err
+		}
+		l := t.slots[value.(*entry).pos]
+		for e := l.Front(); e != nil; e = e.Next() {
+			if e.Value == value {
+				l.Remove(e)
+				break
+			}
+		}
+		t.addTask(task)
+		return nil
+	})
+}
@@ -3,6 +3,7 @@ package job
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -15,6 +16,63 @@ type Trigger interface {
 	Description() string
 }
 
+// Specifier represents a Trigger which can be persisted and restored by ParseTrigger.
+type Specifier interface {
+	// Spec returns the spec of the Trigger.
+	Spec() string
+}
+
+var fullParser = NewParser(SecondOptional | Minute | Hour | Dom | Month | Dow | Descriptor | YearOptional)
+
+// ParseTrigger returns the Trigger represented by the spec returned from Specifier.
+func ParseTrigger(spec string) (Trigger, error) {
+	return fullParser.Parse(spec)
+}
+
+// NextFireTimes returns at most n fire times of the trigger starting from next.
+// The trigger is copied so that the scheduled trigger is not affected,
+// only next is returned if the trigger can't be copied or fires only once.
+func NextFireTimes(trigger Trigger, next int64, n int) []int64 {
+	if n <= 0 {
+		return nil
+	}
+	times := make([]int64, 0, n)
+	times = append(times, next)
+	t, ok := copyTrigger(trigger)
+	if !ok {
+		return times
+	}
+	for len(times) < n {
+		fire, err := t.NextFireTime(next)
+		if err != nil || fire <= next {
+			break
+		}
+		times = append(times, fire)
+		next = fire
+	}
+	return times
+}
+
+// copier represents a Trigger decorator which can copy itself without sharing state.
+type copier interface {
+	copy() (Trigger, bool)
+}
+
+// copyTrigger returns a copy of the trigger, Specifier is restored from its spec.
+func copyTrigger(trigger Trigger) (Trigger, bool) {
+	switch t := trigger.(type) {
+	case copier:
+		return t.copy()
+	case Specifier:
+		if isOneShot(t.Spec()) {
+			return nil, false
+		}
+		restored, err := ParseTrigger(t.Spec())
+		return restored, err == nil
+	}
+	return nil, false
+}
+
 // constantDelayTrigger implements the quartz.Trigger interface; uses a fixed interval.
 type constantDelayTrigger struct {
 	Interval time.Duration
@@ -41,6 +99,11 @@ func (t *constantDelayTrigger) Description() string {
 	return fmt.Sprintf("constantDelayTrigger with interval: %d", t.Interval)
 }
 
+// Spec returns the descriptor of the trigger.
+func (t *constantDelayTrigger) Spec() string {
+	return "@every " + t.Interval.String()
+}
+
 // runOnceTrigger implements the quartz.Trigger interface.
 // This type of Trigger can only be fired once and will delay immediately.
 type runOnceTrigger struct {
@@ -82,6 +145,11 @@ func (ot *runOnceTrigger) Description() string {
 	return fmt.Sprintf("runOnceTrigger (%s).", status)
 }
 
+// Spec returns the descriptor of the trigger.
+func (ot *runOnceTrigger) Spec() string {
+	return "@delay " + ot.Delay.String()
+}
+
 // Verify runAtTrigger satisfies the Trigger interface.
 var _ Trigger = (*runAtTrigger)(nil)
 
@@ -122,3 +190,8 @@ func (ot *runAtTrigger) Description() string {
 
 	return fmt.Sprintf("runAtTrigger (%s).", status)
 }
+
+// Spec returns the descriptor of the trigger.
+func (ot *runAtTrigger) Spec() string {
+	return "@at " + strconv.FormatInt(ot.at, 10)
+}
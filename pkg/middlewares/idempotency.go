@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"template/pkg/code"
+	"template/pkg/idempotent"
+	"template/pkg/logger/gormx"
+	"template/pkg/resp"
+	"template/pkg/utils/v"
+)
+
+const idempotencyPrefix = "idempotency:"
+
+var (
+	// errServerResponse 响应状态码为5xx,删除key允许重试
+	errServerResponse = errors.New("server error response")
+	// errPanic 处理请求时panic,删除key后重新panic
+	errPanic = errors.New("panic while handling request")
+)
+
+// Idempotency 幂等中间件
+// 1.只针对携带 Idempotency-Key 请求头的POST请求
+// 2.key按账号、请求路径及请求头的值区分,相同key的请求仅处理一次,重复的请求返回第一次的响应及 Idempotent-Replayed 头
+// 3.相同key的请求正在处理时返回冲突;响应状态码为5xx或处理时panic时删除key,允许客户端重试
+// 4.相同key的请求内容不同时返回422
+func Idempotency(guard *idempotent.Guard, from func(context.Context) gormx.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(v.HeaderIdempotencyKey)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		body, err := c.GetRawData()
+		if err != nil {
+			resp.ErrorParam(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := md5String(string(body))
+		var panicked interface{}
+		record, err := guard.Do(ctx, retrieveIdempotencyKey(c, key), func(ctx context.Context) (record *idempotent.Record, err error) {
+			writer := c.Writer
+			rw := &wrappedWriter{ResponseWriter: c.Writer}
+			c.Writer = rw
+			defer func() {
+				c.Writer = writer
+				if panicked = recover(); panicked != nil {
+					record, err = nil, errPanic
+				}
+			}()
+			c.Next()
+			if rw.Status() >= http.StatusInternalServerError {
+				return nil, errServerResponse
+			}
+			return &idempotent.Record{
+				Status:      rw.Status(),
+				ContentType: writer.Header().Get(v.HeaderContentType),
+				Body:        rw.buffer.Bytes(),
+				Fingerprint: fingerprint,
+			}, nil
+		})
+		if panicked != nil {
+			// 仅记录删除key失败
+			if err != errPanic {
+				from(ctx).Errorf("err:%+v", err)
+			}
+			panic(panicked)
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, idempotent.ErrDuplicate) && record.Fingerprint != fingerprint:
+			resp.Error(c, code.ErrIdempotencyMismatch)
+		case errors.Is(err, idempotent.ErrDuplicate):
+			c.Header(v.HeaderIdempotentReplayed, "true")
+			c.Data(record.Status, record.ContentType, record.Body)
+			c.Abort()
+		case errors.Is(err, idempotent.ErrInProgress):
+			resp.Error(c, code.ErrRequestInProgress)
+		case c.Writer.Written():
+			// 请求已处理,仅记录删除或保存key失败
+			if err != errServerResponse {
+				from(ctx).Errorf("err:%+v", err)
+			}
+		default:
+			from(ctx).Errorf("err:%+v", err)
+			resp.Error(c, code.ErrInternalServerError.WithResult(err.Error()))
+		}
+	}
+}
+
+func retrieveIdempotencyKey(c *gin.Context, key string) string {
+	var buf bytes.Buffer
+	buf.WriteString(c.GetHeader(v.HeaderAccountID))
+	buf.WriteByte(':')
+	buf.WriteString(c.Request.URL.Path)
+	buf.WriteByte(':')
+	buf.WriteString(key)
+	return idempotencyPrefix + md5String(buf.String())
+}
@@ -0,0 +1,112 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"template/pkg/idempotent"
+	"template/pkg/logger/gormx"
+	"template/pkg/utils/v"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	runs, status := 0, http.StatusCreated
+	router := gin.New()
+	router.Use(Idempotency(idempotent.NewGuard(idempotent.NewMemoryStore()), gormx.Nop))
+	router.POST("/areas", func(c *gin.Context) {
+		runs++
+		c.JSON(status, gin.H{"runs": runs})
+	})
+	request := func(key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/areas", strings.NewReader(`{"name":"a"}`))
+		if key != "" {
+			r.Header.Set(v.HeaderIdempotencyKey, key)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("a")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	// 重复的请求返回第一次的响应
+	w = request("a")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"runs":1}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(v.HeaderIdempotentReplayed))
+	assert.Equal(t, 1, runs)
+
+	// 未携带key的请求不做处理
+	request("")
+	assert.Equal(t, 2, runs)
+
+	// 5xx的响应允许重试
+	status = http.StatusInternalServerError
+	request("b")
+	status = http.StatusOK
+	w = request("b")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 4, runs)
+}
+
+func TestIdempotency_mismatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	runs := 0
+	router := gin.New()
+	router.Use(Idempotency(idempotent.NewGuard(idempotent.NewMemoryStore()), gormx.Nop))
+	router.POST("/areas", func(c *gin.Context) {
+		runs++
+		body, _ := c.GetRawData()
+		c.Data(http.StatusCreated, "application/json", body)
+	})
+	request := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/areas", strings.NewReader(body))
+		r.Header.Set(v.HeaderIdempotencyKey, "a")
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	// 处理请求时仍可读取请求内容
+	w := request(`{"name":"a"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"name":"a"}`, w.Body.String())
+	// 相同key的请求内容不同时拒绝
+	w = request(`{"name":"b"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, runs)
+}
+
+func TestIdempotency_panic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	runs := 0
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, _ interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.Use(Idempotency(idempotent.NewGuard(idempotent.NewMemoryStore()), gormx.Nop))
+	router.POST("/areas", func(c *gin.Context) {
+		if runs++; runs == 1 {
+			panic("boom")
+		}
+		c.Status(http.StatusCreated)
+	})
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/areas", nil)
+		r.Header.Set(v.HeaderIdempotencyKey, "a")
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, request().Code)
+	// panic后删除key,允许重试
+	assert.Equal(t, http.StatusCreated, request().Code)
+	assert.Equal(t, 2, runs)
+}
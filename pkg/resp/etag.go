@@ -0,0 +1,51 @@
+package resp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"template/pkg/code"
+)
+
+// SetETag 以资源的版本号设置ETag响应头
+func SetETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// IfMatch 解析If-Match请求头中的版本号,未携带或为*时ok为false
+func IfMatch(c *gin.Context) (version int64, ok bool, err error) {
+	return parseETag(c.GetHeader("If-Match"))
+}
+
+// NotModified If-None-Match与当前版本一致时响应304,返回true表示已响应
+func NotModified(c *gin.Context, version int64) bool {
+	expected, ok, err := parseETag(c.GetHeader("If-None-Match"))
+	if err != nil || !ok || expected != version {
+		return false
+	}
+	SetETag(c, version)
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+func parseETag(value string) (int64, bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "*" {
+		return 0, false, nil
+	}
+	// 版本号为强校验,弱ETag同样按版本号比较
+	value = strings.TrimPrefix(value, "W/")
+	unquoted, err := strconv.Unquote(value)
+	if err != nil {
+		unquoted = value
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil {
+		return 0, false, errors.WithStack(code.ErrInvalidParam.WithResult("invalid etag " + value))
+	}
+	return version, true, nil
+}
@@ -107,3 +107,10 @@ func List(c *gin.Context, list interface{}, pageNum, pageSize int, total int64,
 	}
 	c.JSON(http.StatusOK, WrapResult(result))
 }
+
+// CursorList 包裹DCS游标分页的List结果,next_cursor为空时表示没有更多数据,total仅在统计总数时有效
+func CursorList(c *gin.Context, list interface{}, pageSize int, nextCursor string, total int64,
+	values ...map[string]interface{}) {
+	values = append([]map[string]interface{}{{"next_cursor": nextCursor}}, values...)
+	List(c, list, 0, pageSize, total, values...)
+}
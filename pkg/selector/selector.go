@@ -1,7 +1,11 @@
 package selector
 
 import (
+	"math/rand"
 	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Node struct {
@@ -13,3 +17,88 @@ type Node struct {
 type Selector interface {
 	Next() *Node
 }
+
+// NewRandom 随机选择节点
+func NewRandom(nodes []*Node) Selector {
+	return &random{
+		nodes: nodes,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+type random struct {
+	nodes []*Node
+	mux   sync.Mutex
+	rand  *rand.Rand
+}
+
+func (r *random) Next() *Node {
+	if len(r.nodes) == 0 {
+		return nil
+	}
+	r.mux.Lock()
+	index := r.rand.Intn(len(r.nodes))
+	r.mux.Unlock()
+	return r.nodes[index]
+}
+
+// NewRoundRobin 轮询选择节点
+func NewRoundRobin(nodes []*Node) Selector {
+	return &roundRobin{
+		nodes: nodes,
+	}
+}
+
+type roundRobin struct {
+	nodes []*Node
+	next  uint64
+}
+
+func (r *roundRobin) Next() *Node {
+	if len(r.nodes) == 0 {
+		return nil
+	}
+	index := (atomic.AddUint64(&r.next, 1) - 1) % uint64(len(r.nodes))
+	return r.nodes[index]
+}
+
+// NewWeightedRoundRobin 平滑加权轮询选择节点,权重小于等于0的节点不会被选中
+func NewWeightedRoundRobin(nodes []*Node) Selector {
+	w := &weightedRoundRobin{
+		nodes:   make([]*Node, 0, len(nodes)),
+		current: make([]float64, 0, len(nodes)),
+	}
+	for _, node := range nodes {
+		if node.Weight <= 0 {
+			continue
+		}
+		w.nodes = append(w.nodes, node)
+		w.current = append(w.current, 0)
+		w.total += node.Weight
+	}
+	return w
+}
+
+type weightedRoundRobin struct {
+	nodes   []*Node
+	current []float64
+	total   float64
+	mux     sync.Mutex
+}
+
+func (w *weightedRoundRobin) Next() *Node {
+	if len(w.nodes) == 0 {
+		return nil
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	best := 0
+	for i, node := range w.nodes {
+		w.current[i] += node.Weight
+		if w.current[i] > w.current[best] {
+			best = i
+		}
+	}
+	w.current[best] -= w.total
+	return w.nodes[best]
+}
@@ -0,0 +1,41 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundRobin(t *testing.T) {
+	nodes := []*Node{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	s := NewRoundRobin(nodes)
+	var got []string
+	for i := 0; i < 6; i++ {
+		got = append(got, s.Next().Name)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a", "b", "c"}, got)
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	nodes := []*Node{{Name: "a", Weight: 5}, {Name: "b", Weight: 1}, {Name: "c", Weight: 1}, {Name: "d", Weight: 0}}
+	s := NewWeightedRoundRobin(nodes)
+	var got []string
+	for i := 0; i < 7; i++ {
+		got = append(got, s.Next().Name)
+	}
+	assert.Equal(t, []string{"a", "a", "b", "a", "c", "a", "a"}, got)
+}
+
+func TestRandom(t *testing.T) {
+	nodes := []*Node{{Name: "a"}, {Name: "b"}}
+	s := NewRandom(nodes)
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, nodes, s.Next())
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	assert.Nil(t, NewRandom(nil).Next())
+	assert.Nil(t, NewRoundRobin(nil).Next())
+	assert.Nil(t, NewWeightedRoundRobin(nil).Next())
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"template/pkg/json"
+	"template/pkg/utils/v"
+)
+
+// ErrInvalidCursor 游标无法解析或与当前的排序条件不一致
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorPagination 游标分页,将上一页最后一条记录的排序键编码为 next_cursor,
+// 下一页从该位置继续查询,避免 COUNT 及大偏移量带来的全表扫描
+type CursorPagination struct {
+	// 上一页返回的 next_cursor,为空时查询第一页
+	// in: query
+	Cursor string `form:"cursor" json:"-"`
+	// 查询每页显示条目
+	// Example: 100
+	// in: query
+	PageSize int `form:"page_size,default=20" json:"page_size" binding:"omitempty,min=1"`
+	// 是否统计总数,默认不统计
+	// in: query
+	WithTotal bool `form:"with_total" json:"-"`
+	// 排序信息【格式:字段 排序方式】,默认 created_at desc,id 会作为最后的排序列保证顺序唯一
+	// in: query
+	SortField string `form:"sort" json:"-" binding:"omitempty,order"`
+	// 总计条目,仅 with_total 为 true 时返回
+	// swagger:ignore
+	Total int64 `form:"-" json:"total,omitempty"`
+	// 下一页的游标,为空时表示没有更多数据
+	// swagger:ignore
+	NextCursor string `form:"-" json:"next_cursor"`
+
+	sort   string
+	orders []clause.OrderByColumn
+}
+
+// cursorValue 游标中记录的排序条件及最后一条记录的排序键
+type cursorValue struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+// Build 查询需先通过 Model 指定模型,多查询一条记录用于判断是否存在下一页,
+// 查询结果需调用 Next 去除多余的记录并生成 next_cursor
+func (p *CursorPagination) Build(_ context.Context, query *gorm.DB, _ ...SQLOption) *gorm.DB {
+	if p.PageSize <= 0 {
+		p.PageSize = v.DefaultPageSize
+	}
+	if err := query.Statement.Parse(query.Statement.Model); err != nil {
+		_ = query.AddError(errors.WithStack(err))
+		return query
+	}
+	columns, err := modelColumns(query.Statement.Model)
+	if err != nil {
+		_ = query.AddError(err)
+		return query
+	}
+	if err = p.parseSort(columns); err != nil {
+		_ = query.AddError(errors.Wrap(ErrInvalidCursor, err.Error()))
+		return query
+	}
+	if p.WithTotal {
+		query.Count(&p.Total)
+	}
+	if p.Cursor != "" {
+		values, err := p.decode(columns)
+		if err != nil {
+			_ = query.AddError(errors.Wrap(ErrInvalidCursor, err.Error()))
+			return query
+		}
+		query = query.Where(p.after(values))
+	}
+	for _, order := range p.orders {
+		query = query.Order(order)
+	}
+	return query.Limit(p.PageSize + 1)
+}
+
+// Next 去除多查询的一条记录并生成 next_cursor,list 为查询结果切片的指针
+func (p *CursorPagination) Next(list interface{}) error {
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return errors.Errorf("list must be a pointer to slice, got %T", list)
+	}
+	rv = rv.Elem()
+	p.NextCursor = ""
+	if rv.Len() <= p.PageSize {
+		return nil
+	}
+	rv.Set(rv.Slice(0, p.PageSize))
+
+	s, err := schema.Parse(list, schemaCache, namingStrategy)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	last := reflect.Indirect(rv.Index(p.PageSize - 1))
+	values := make([]string, 0, len(p.orders))
+	for _, order := range p.orders {
+		field := s.LookUpField(order.Column.Name)
+		if field == nil {
+			return errors.Errorf("column %s not found in %s", order.Column.Name, s.Name)
+		}
+		value, _ := field.ValueOf(context.Background(), last)
+		values = append(values, formatCursorValue(value))
+	}
+	data, err := json.Marshal(&cursorValue{Sort: p.sort, Values: values})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	p.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	return nil
+}
+
+func (p *CursorPagination) parseSort(columns map[string]*schema.Field) error {
+	sort := strings.TrimSpace(p.SortField)
+	if sort == "" {
+		sort = "created_at desc"
+	}
+	orders, err := parseSort(sort, columns)
+	if err != nil {
+		return err
+	}
+	// 追加主键保证排序唯一,方向与最后一个排序列一致
+	if last := orders[len(orders)-1]; last.Column.Name != "id" {
+		if _, ok := columns["id"]; !ok {
+			return errors.New("cursor pagination requires id column")
+		}
+		orders = append(orders, clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: "id"},
+			Desc:   last.Desc,
+		})
+	}
+	parts := make([]string, 0, len(orders))
+	for _, order := range orders {
+		if order.Desc {
+			parts = append(parts, order.Column.Name+" desc")
+		} else {
+			parts = append(parts, order.Column.Name+" asc")
+		}
+	}
+	p.sort = strings.Join(parts, ",")
+	p.orders = orders
+	return nil
+}
+
+func (p *CursorPagination) decode(columns map[string]*schema.Field) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	var cursor cursorValue
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Sort != p.sort || len(cursor.Values) != len(p.orders) {
+		return nil, errors.New("sort does not match the cursor")
+	}
+	values := make([]interface{}, 0, len(cursor.Values))
+	for i, order := range p.orders {
+		value, err := convertValue(columns[order.Column.Name], cursor.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// after 生成位于游标之后的条件,例如排序为 a desc,id desc 时:
+// a < ? OR (a = ? AND id < ?)
+func (p *CursorPagination) after(values []interface{}) clause.Expression {
+	exprs := make([]clause.Expression, 0, len(p.orders))
+	for i, order := range p.orders {
+		conditions := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, clause.Eq{Column: p.orders[j].Column, Value: values[j]})
+		}
+		if order.Desc {
+			conditions = append(conditions, clause.Lt{Column: order.Column, Value: values[i]})
+		} else {
+			conditions = append(conditions, clause.Gt{Column: order.Column, Value: values[i]})
+		}
+		exprs = append(exprs, clause.And(conditions...))
+	}
+	return clause.Or(exprs...)
+}
+
+func formatCursorValue(value interface{}) string {
+	switch val := value.(type) {
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if val == nil {
+			return ""
+		}
+		return val.UTC().Format(time.RFC3339Nano)
+	case uint64:
+		return strconv.FormatUint(val, v.Decimal)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorPagination(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&testSite{}))
+	createdAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 7; i++ {
+		site := &testSite{SiteName: "site", Weight: i % 3}
+		site.ID = uint64(i)
+		// 相同的创建时间依赖id保证顺序
+		site.CreatedAt = createdAt.Add(time.Duration(i/2) * time.Second)
+		require.NoError(t, db.Create(site).Error)
+	}
+
+	walk := func(t *testing.T, sort string, withTotal bool) ([]uint64, int64) {
+		var (
+			ids   []uint64
+			total int64
+		)
+		cursor := ""
+		for page := 0; page < 10; page++ {
+			p := &CursorPagination{Cursor: cursor, PageSize: 3, SortField: sort, WithTotal: withTotal}
+			var sites []*testSite
+			require.NoError(t, p.Build(context.Background(), db.Model(&testSite{})).Find(&sites).Error)
+			require.NoError(t, p.Next(&sites))
+			assert.LessOrEqual(t, len(sites), 3)
+			for _, site := range sites {
+				ids = append(ids, site.ID)
+			}
+			if page == 0 {
+				total = p.Total
+			}
+			if p.NextCursor == "" {
+				return ids, total
+			}
+			cursor = p.NextCursor
+		}
+		t.Fatal("too many pages")
+		return nil, 0
+	}
+
+	ids, total := walk(t, "", false)
+	assert.Equal(t, []uint64{7, 6, 5, 4, 3, 2, 1}, ids)
+	assert.Zero(t, total)
+
+	ids, total = walk(t, "created_at asc", true)
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5, 6, 7}, ids)
+	assert.Equal(t, int64(7), total)
+
+	ids, _ = walk(t, "weight asc,created_at desc", false)
+	assert.Equal(t, []uint64{6, 3, 7, 4, 1, 5, 2}, ids)
+}
+
+func TestCursorPaginationInvalid(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&testSite{}))
+
+	var sites []*testSite
+	p := &CursorPagination{Cursor: "not a cursor"}
+	assert.ErrorIs(t, p.Build(context.Background(), db.Model(&testSite{})).Find(&sites).Error, ErrInvalidCursor)
+
+	// 游标与排序条件不一致
+	first := &CursorPagination{PageSize: 1, SortField: "weight"}
+	for i := 1; i <= 2; i++ {
+		site := &testSite{}
+		site.ID = uint64(i)
+		require.NoError(t, db.Create(site).Error)
+	}
+	require.NoError(t, first.Build(context.Background(), db.Model(&testSite{})).Find(&sites).Error)
+	require.NoError(t, first.Next(&sites))
+	require.NotEmpty(t, first.NextCursor)
+	p = &CursorPagination{Cursor: first.NextCursor, PageSize: 1}
+	assert.ErrorIs(t, p.Build(context.Background(), db.Model(&testSite{})).Find(&sites).Error, ErrInvalidCursor)
+
+	p = &CursorPagination{SortField: "secret"}
+	assert.ErrorIs(t, p.Build(context.Background(), db.Model(&testSite{})).Find(&sites).Error, ErrInvalidCursor)
+}
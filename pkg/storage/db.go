@@ -8,9 +8,12 @@ import (
 	"time"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	glogger "gorm.io/gorm/logger"
-	"gorm.io/gorm/schema"
+
+	"template/pkg/selector"
 )
 
 var (
@@ -19,7 +22,17 @@ var (
 	ErrDuplicate       = "1062: Duplicate"
 )
 
+// Dialect 数据库类型,取值与gorm.Dialector.Name()一致
+type Dialect string
+
+const (
+	DialectMySQL    Dialect = "mysql"
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
 type option struct {
+	dialect     Dialect
 	maxOpenConn int
 	maxIdleConn int
 
@@ -36,10 +49,22 @@ type option struct {
 	connMaxLifetime time.Duration
 	logger          glogger.Interface
 	plugins         []gorm.Plugin
+
+	replicas            []Replica
+	newSelector         func([]*selector.Node) selector.Selector
+	healthCheckInterval time.Duration
 }
 
 type Option func(*option)
 
+// WithDialect 设置数据库类型,默认为MySQL;
+// SQLite的数据库文件路径通过WithDatabase设置,为空时使用内存数据库
+func WithDialect(dialect Dialect) Option {
+	return func(o *option) {
+		o.dialect = dialect
+	}
+}
+
 func WithMaxOpenConn(maxOpenConn int) Option {
 	return func(o *option) {
 		o.maxOpenConn = maxOpenConn
@@ -127,49 +152,30 @@ func WithPlugins(plugins ...gorm.Plugin) Option {
 // New init DB
 func New(ctx context.Context, opts ...Option) (*DB, error) {
 	o := &option{
+		dialect:     DialectMySQL,
 		maxOpenConn: 100,
 		maxIdleConn: 80,
 		ip:          "127.0.0.1",
-		port:        "3306",
 		charset:     "utf8mb4",
 	}
 	for _, f := range opts {
 		f(o)
 	}
-	client, err := gorm.Open(
-		&mysql.Dialector{Config: &mysql.Config{
-			DSN: Dsn(
-				o.user,
-				o.password,
-				o.ip,
-				o.port,
-				o.database,
-				o.charset,
-				o.timeout,
-				o.readTimeout,
-				o.writeTimeout,
-			),
-			DisableWithReturning: true,
-		}},
-		&gorm.Config{
-			SkipDefaultTransaction: false,
-			NamingStrategy: schema.NamingStrategy{
-				SingularTable: true, // 不考虑表名单复数变化
-			},
-			FullSaveAssociations: false,
-			NowFunc: func() time.Time {
-				return time.Now().UTC()
-			},
-			PrepareStmt: true,
-			Logger:      o.logger,
-		},
-	)
+	dialector, err := newDialector(o)
+	if err != nil {
+		return nil, err
+	}
+	client, err := gorm.Open(dialector, newConfig(o))
 	if err != nil {
 		return nil, err
 	}
 
 	// Replace preload callback
 	client.Callback().Query().Replace("gorm:preload", Preload)
+	// 乐观锁版本冲突检查
+	if err = client.Callback().Update().After("gorm:update").Register("storage:version", checkVersion); err != nil {
+		return nil, err
+	}
 
 	// 插件注入
 	for _, plugin := range o.plugins {
@@ -177,6 +183,17 @@ func New(ctx context.Context, opts ...Option) (*DB, error) {
 			return nil, err
 		}
 	}
+	// 读写分离
+	var r *resolver
+	if len(o.replicas) > 0 {
+		if r, err = newResolver(ctx, o); err != nil {
+			return nil, err
+		}
+		if err = client.Use(r); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+	}
 	// 注入context
 	client = client.WithContext(ctx)
 
@@ -189,9 +206,55 @@ func New(ctx context.Context, opts ...Option) (*DB, error) {
 	sqlDB.SetMaxIdleConns(o.maxIdleConn)        // 默认值2
 	sqlDB.SetConnMaxLifetime(o.connMaxLifetime) // 默认值0，永不过期
 
-	return &DB{DB: client}, nil
+	return &DB{DB: client, resolver: r}, nil
 }
 
+func newConfig(o *option) *gorm.Config {
+	return &gorm.Config{
+		SkipDefaultTransaction: false,
+		NamingStrategy:         namingStrategy,
+		FullSaveAssociations:   false,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+		PrepareStmt: true,
+		Logger:      o.logger,
+	}
+}
+
+func newDialector(o *option) (gorm.Dialector, error) {
+	switch o.dialect {
+	case DialectMySQL:
+		if o.port == "" {
+			o.port = "3306"
+		}
+		return &mysql.Dialector{Config: &mysql.Config{
+			DSN: Dsn(
+				o.user,
+				o.password,
+				o.ip,
+				o.port,
+				o.database,
+				o.charset,
+				o.timeout,
+				o.readTimeout,
+				o.writeTimeout,
+			),
+			DisableWithReturning: true,
+		}}, nil
+	case DialectPostgres:
+		if o.port == "" {
+			o.port = "5432"
+		}
+		return postgres.Open(PostgresDsn(o.user, o.password, o.ip, o.port, o.database, o.timeout)), nil
+	case DialectSQLite:
+		return sqlite.Open(SqliteDsn(o.database)), nil
+	default:
+		return nil, fmt.Errorf("unsupported dialect %s", o.dialect)
+	}
+}
+
+// Dsn 生成MySQL的连接串
 func Dsn(
 	user, password, ip, port, database, charset string,
 	timeout, readTimeout, writeTimeout time.Duration,
@@ -210,11 +273,40 @@ func Dsn(
 	return uri
 }
 
+// PostgresDsn 生成PostgreSQL的连接串
+func PostgresDsn(user, password, ip, port, database string, timeout time.Duration) string {
+	uri := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=UTC",
+		ip, port, user, password, database)
+	if timeout != 0 {
+		uri += fmt.Sprintf(" connect_timeout=%d", int(timeout.Seconds()))
+	}
+	return uri
+}
+
+// SqliteDsn 生成SQLite的连接串,database为空时使用共享的内存数据库
+func SqliteDsn(database string) string {
+	if database == "" {
+		database = "file::memory:?cache=shared"
+	}
+	return database
+}
+
 type DB struct {
 	*gorm.DB
+	resolver *resolver
+}
+
+// Dialect 返回当前连接的数据库类型
+func (d *DB) Dialect() Dialect {
+	return Dialect(d.Dialector.Name())
 }
 
 func (d *DB) Close() error {
+	if d.resolver != nil {
+		if err := d.resolver.Close(); err != nil {
+			return err
+		}
+	}
 	s, err := d.DB.DB()
 	if err != nil {
 		return err
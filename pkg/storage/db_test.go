@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testArea struct {
+	Base
+	AreaCode string  `gorm:"column:area_code;uniqueIndex:idx_area_code,priority:1"`
+	Deleted  Deleted `gorm:"column:deleted;uniqueIndex:idx_area_code,priority:2"`
+}
+
+func newTestDB(t *testing.T) *DB {
+	db, err := New(context.Background(),
+		WithDialect(DialectSQLite),
+		WithDatabase("file:"+t.Name()+"?mode=memory&cache=shared"),
+		WithMaxOpenConn(1),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	require.NoError(t, db.AutoMigrate(&testArea{}))
+	return db
+}
+
+func TestNewWithSQLite(t *testing.T) {
+	db := newTestDB(t)
+	assert.Equal(t, DialectSQLite, db.Dialect())
+
+	area := &testArea{AreaCode: "cn-east"}
+	area.ID = 1
+	require.NoError(t, db.Create(area).Error)
+
+	duplicate := &testArea{AreaCode: "cn-east"}
+	duplicate.ID = 2
+	assert.True(t, IsDuplicate(db.Create(duplicate).Error))
+
+	require.NoError(t, db.Where("id = ?", area.ID).Delete(&testArea{}).Error)
+	assert.ErrorIs(t, db.Where("id = ?", area.ID).First(&testArea{}).Error, NotFound)
+
+	var deleted testArea
+	require.NoError(t, db.Unscoped().Where("id = ?", area.ID).First(&deleted).Error)
+	assert.Equal(t, Deleted(area.ID), deleted.Deleted)
+	assert.True(t, deleted.DeletedAt.Valid)
+
+	// 软删除后允许重新创建相同编码的记录
+	recreated := &testArea{AreaCode: "cn-east"}
+	recreated.ID = 3
+	assert.NoError(t, db.Create(recreated).Error)
+}
+
+func TestDsn(t *testing.T) {
+	assert.Equal(t,
+		"root:123456@tcp(127.0.0.1:3306)/template?charset=utf8mb4&parseTime=true&loc=UTC",
+		Dsn("root", "123456", "127.0.0.1", "3306", "template", "utf8mb4", 0, 0, 0))
+	assert.Equal(t,
+		"host=127.0.0.1 port=5432 user=root password=123456 dbname=template sslmode=disable TimeZone=UTC",
+		PostgresDsn("root", "123456", "127.0.0.1", "5432", "template", 0))
+	assert.Equal(t, "file::memory:?cache=shared", SqliteDsn(""))
+}
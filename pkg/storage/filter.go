@@ -0,0 +1,468 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// maxFilterLength 过滤表达式的最大长度
+const maxFilterLength = 2048
+
+var (
+	namingStrategy = schema.NamingStrategy{
+		SingularTable: true, // 不考虑表名单复数变化
+	}
+	schemaCache = &sync.Map{}
+
+	deletedType   = reflect.TypeOf(Deleted(0))
+	deletedAtType = reflect.TypeOf(DeletedAt{})
+	versionType   = reflect.TypeOf(Version(0))
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+// timeLayouts 过滤条件中时间类型的值支持的格式
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+type filterOption struct {
+	columns     []string
+	defaultSort string
+}
+
+type FilterOption func(*filterOption)
+
+// WithFilterColumns 在模型列的基础上进一步限制允许过滤及排序的列
+func WithFilterColumns(columns ...string) FilterOption {
+	return func(o *filterOption) {
+		o.columns = columns
+	}
+}
+
+// WithDefaultSort 未指定排序时使用的排序,默认为 created_at desc
+func WithDefaultSort(sort string) FilterOption {
+	return func(o *filterOption) {
+		o.defaultSort = sort
+	}
+}
+
+// Filter 通用的过滤及排序条件,列名需在模型的白名单内,所有值均以参数的方式传入sql
+//
+// 过滤语法: area_name eq 'a' and (status in (on,off) or created_at between (2022-01-01,2022-02-01))
+// 操作符: eq ne gt ge lt le like in nin between, 条件间使用 and or 连接,and 优先于 or,可使用括号分组
+// 排序语法: created_at desc,id asc,未指定排序方式时默认降序
+type Filter struct {
+	where  clause.Expression
+	orders []clause.OrderByColumn
+}
+
+// NewFilter 根据模型的gorm schema解析过滤及排序条件,不合法时返回错误
+func NewFilter(model interface{}, filter, sort string, opts ...FilterOption) (*Filter, error) {
+	o := &filterOption{
+		defaultSort: "created_at desc",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	all, err := modelColumns(model)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := restrictColumns(all, o.columns)
+	if err != nil {
+		return nil, err
+	}
+	f := &Filter{}
+	if filter = strings.TrimSpace(filter); filter != "" {
+		if len(filter) > maxFilterLength {
+			return nil, errors.Errorf("filter is longer than %d", maxFilterLength)
+		}
+		if f.where, err = parseFilter(filter, columns); err != nil {
+			return nil, err
+		}
+	}
+	// 默认排序由调用方指定,不受白名单限制
+	if sort = strings.TrimSpace(sort); sort != "" {
+		f.orders, err = parseSort(sort, columns)
+	} else {
+		f.orders, err = parseSort(o.defaultSort, all)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Filter) Build(_ context.Context, query *gorm.DB, _ ...SQLOption) *gorm.DB {
+	if f.where != nil {
+		query = query.Where(f.where)
+	}
+	for _, order := range f.orders {
+		query = query.Order(order)
+	}
+	return query
+}
+
+// modelColumns 模型中允许过滤的列,忽略软删除及json中隐藏的字段
+func modelColumns(model interface{}) (map[string]*schema.Field, error) {
+	s, err := schema.Parse(model, schemaCache, namingStrategy)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	columns := make(map[string]*schema.Field, len(s.DBNames))
+	for _, field := range s.Fields {
+		if field.DBName == "" || !field.Readable || field.StructField.Tag.Get("json") == "-" {
+			continue
+		}
+		if fieldType := indirectType(field.FieldType); fieldType == deletedType || fieldType == deletedAtType {
+			continue
+		}
+		columns[field.DBName] = field
+	}
+	return columns, nil
+}
+
+func restrictColumns(columns map[string]*schema.Field, allowed []string) (map[string]*schema.Field, error) {
+	if len(allowed) == 0 {
+		return columns, nil
+	}
+	result := make(map[string]*schema.Field, len(allowed))
+	for _, column := range allowed {
+		field, ok := columns[column]
+		if !ok {
+			return nil, errors.Errorf("column %s is not filterable", column)
+		}
+		result[column] = field
+	}
+	return result, nil
+}
+
+func parseSort(sort string, columns map[string]*schema.Field) ([]clause.OrderByColumn, error) {
+	if sort == "" {
+		return nil, nil
+	}
+	items := strings.Split(sort, ",")
+	orders := make([]clause.OrderByColumn, 0, len(items))
+	for _, item := range items {
+		fields := strings.Fields(item)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.Errorf("invalid sort %q", item)
+		}
+		if _, ok := columns[fields[0]]; !ok {
+			return nil, errors.Errorf("sort by %s is not allowed", fields[0])
+		}
+		// 未明确排序方式时默认降序
+		desc := true
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+				desc = false
+			case "desc":
+			default:
+				return nil, errors.Errorf("invalid sort direction %q", fields[1])
+			}
+		}
+		orders = append(orders, clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: fields[0]},
+			Desc:   desc,
+		})
+	}
+	return orders, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, value: ",", pos: i})
+			i++
+		case c == '\'' || c == '"':
+			// 引号内的值原样保留,连续两个引号表示引号本身
+			var b strings.Builder
+			start := i
+			for i++; ; i++ {
+				if i >= len(s) {
+					return nil, errors.Errorf("unterminated string at %d", start)
+				}
+				if s[i] == c {
+					if i+1 < len(s) && s[i+1] == c {
+						b.WriteByte(c)
+						i++
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+			}
+			tokens = append(tokens, token{kind: tokenString, value: b.String(), pos: start})
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r(),'\"", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: s[start:i], pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(s)}), nil
+}
+
+// filterParser 递归下降解析过滤表达式
+//
+//	expr   = term { "or" term }
+//	term   = factor { "and" factor }
+//	factor = "(" expr ")" | column op value
+//	value  = word | string | "(" value { "," value } ")"
+type filterParser struct {
+	tokens  []token
+	pos     int
+	columns map[string]*schema.Field
+}
+
+func parseFilter(filter string, columns map[string]*schema.Field) (clause.Expression, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens, columns: columns}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errors.Errorf("unexpected %q at %d", t.value, t.pos)
+	}
+	return expr, nil
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokenWord && strings.EqualFold(t.value, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseExpr() (clause.Expression, error) {
+	expr, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []clause.Expression{expr}
+	for p.keyword("or") {
+		if expr, err = p.parseTerm(); err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return clause.Or(exprs...), nil
+}
+
+func (p *filterParser) parseTerm() (clause.Expression, error) {
+	expr, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []clause.Expression{expr}
+	for p.keyword("and") {
+		if expr, err = p.parseFactor(); err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return clause.And(exprs...), nil
+}
+
+func (p *filterParser) parseFactor() (clause.Expression, error) {
+	t := p.next()
+	if t.kind == tokenLParen {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if t = p.next(); t.kind != tokenRParen {
+			return nil, errors.Errorf("expected ) at %d", t.pos)
+		}
+		return expr, nil
+	}
+	if t.kind != tokenWord {
+		return nil, errors.Errorf("expected column at %d", t.pos)
+	}
+	field, ok := p.columns[t.value]
+	if !ok {
+		return nil, errors.Errorf("filter by %s is not allowed", t.value)
+	}
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+
+	op := p.next()
+	if op.kind != tokenWord {
+		return nil, errors.Errorf("expected operator at %d", op.pos)
+	}
+	switch strings.ToLower(op.value) {
+	case "in", "nin":
+		values, err := p.parseList(field)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(op.value, "nin") {
+			return clause.Not(clause.IN{Column: column, Values: values}), nil
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case "between":
+		values, err := p.parseList(field)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != 2 {
+			return nil, errors.Errorf("between requires 2 values at %d", op.pos)
+		}
+		return clause.And(clause.Gte{Column: column, Value: values[0]}, clause.Lte{Column: column, Value: values[1]}), nil
+	case "like":
+		t = p.next()
+		if t.kind != tokenWord && t.kind != tokenString {
+			return nil, errors.Errorf("expected value at %d", t.pos)
+		}
+		// 使用!作为转义符,兼容各数据库
+		pattern := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(t.value)
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []interface{}{column, "%" + pattern + "%"}}, nil
+	}
+
+	value, err := p.parseValue(field)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(op.value) {
+	case "eq":
+		return clause.Eq{Column: column, Value: value}, nil
+	case "ne":
+		return clause.Neq{Column: column, Value: value}, nil
+	case "gt":
+		return clause.Gt{Column: column, Value: value}, nil
+	case "ge":
+		return clause.Gte{Column: column, Value: value}, nil
+	case "lt":
+		return clause.Lt{Column: column, Value: value}, nil
+	case "le":
+		return clause.Lte{Column: column, Value: value}, nil
+	default:
+		return nil, errors.Errorf("unsupported operator %q at %d", op.value, op.pos)
+	}
+}
+
+func (p *filterParser) parseList(field *schema.Field) ([]interface{}, error) {
+	if t := p.next(); t.kind != tokenLParen {
+		return nil, errors.Errorf("expected ( at %d", t.pos)
+	}
+	var values []interface{}
+	for {
+		value, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		t := p.next()
+		if t.kind == tokenRParen {
+			return values, nil
+		}
+		if t.kind != tokenComma {
+			return nil, errors.Errorf("expected , or ) at %d", t.pos)
+		}
+	}
+}
+
+func (p *filterParser) parseValue(field *schema.Field) (interface{}, error) {
+	t := p.next()
+	if t.kind != tokenWord && t.kind != tokenString {
+		return nil, errors.Errorf("expected value at %d", t.pos)
+	}
+	value, err := convertValue(field, t.value)
+	if err != nil {
+		return nil, errors.Errorf("invalid value %q of %s at %d", t.value, field.DBName, t.pos)
+	}
+	return value, nil
+}
+
+// convertValue 按列的类型转换值,避免依赖数据库的隐式转换
+func convertValue(field *schema.Field, value string) (interface{}, error) {
+	fieldType := indirectType(field.FieldType)
+	if fieldType == timeType {
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid time %s", value)
+	}
+	switch fieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"template/pkg/code"
+)
+
+type testSite struct {
+	Base
+	SiteName string  `gorm:"column:site_name"`
+	Status   string  `gorm:"column:status"`
+	Weight   int     `gorm:"column:weight"`
+	Secret   string  `gorm:"column:secret" json:"-"`
+	Deleted  Deleted `gorm:"column:deleted"`
+}
+
+func TestFilter(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&testSite{}))
+	for i, site := range []testSite{
+		{SiteName: "hz_1", Status: "on", Weight: 1},
+		{SiteName: "hz_2", Status: "off", Weight: 2},
+		{SiteName: "sh%1", Status: "on", Weight: 3},
+		{SiteName: "bj'1", Status: "maintain", Weight: 4},
+	} {
+		site := site
+		site.ID = uint64(i + 1)
+		require.NoError(t, db.Create(&site).Error)
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		sort   string
+		want   []uint64
+	}{
+		{name: "default sort", want: []uint64{4, 3, 2, 1}},
+		{name: "eq", filter: "status eq on", sort: "id asc", want: []uint64{1, 3}},
+		{name: "ne", filter: "status ne on", sort: "id", want: []uint64{4, 2}},
+		{name: "quoted", filter: `site_name eq 'bj''1'`, want: []uint64{4}},
+		{name: "in", filter: "weight in (1,4)", sort: "weight asc", want: []uint64{1, 4}},
+		{name: "nin", filter: "status nin (on, off)", want: []uint64{4}},
+		{name: "range", filter: "weight gt 1 and weight le 3", sort: "id asc", want: []uint64{2, 3}},
+		{name: "between", filter: "weight between (2,3)", sort: "id asc", want: []uint64{2, 3}},
+		{name: "like escaped", filter: "site_name like '%'", want: []uint64{3}},
+		{name: "like", filter: "site_name LIKE hz_", sort: "id asc", want: []uint64{1, 2}},
+		{
+			name:   "and or",
+			filter: "status eq off or (status eq on and weight ge 3)",
+			sort:   "id asc",
+			want:   []uint64{2, 3},
+		},
+		{name: "time", filter: "created_at gt 2000-01-01", sort: "id asc", want: []uint64{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(&testSite{}, tt.filter, tt.sort)
+			require.NoError(t, err)
+			var sites []*testSite
+			require.NoError(t, f.Build(context.Background(), db.Model(&testSite{})).Find(&sites).Error)
+			ids := make([]uint64, 0, len(sites))
+			for _, site := range sites {
+				ids = append(ids, site.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestFilterInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		sort   string
+	}{
+		{name: "unknown column", filter: "unknown eq 1"},
+		{name: "hidden column", filter: "secret eq 1"},
+		{name: "soft deleted column", filter: "deleted eq 0"},
+		{name: "unknown operator", filter: "status is on"},
+		{name: "invalid value", filter: "weight eq abc"},
+		{name: "between", filter: "weight between (1)"},
+		{name: "unterminated string", filter: "status eq 'on"},
+		{name: "unbalanced", filter: "(status eq on"},
+		{name: "trailing", filter: "status eq on weight"},
+		{name: "injection", sort: "id; drop table test_site"},
+		{name: "sort direction", sort: "id up"},
+		{name: "sort column", sort: "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFilter(&testSite{}, tt.filter, tt.sort)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewFilter(&testSite{}, "weight eq 1", "", WithFilterColumns("status"))
+	assert.Error(t, err)
+	_, err = NewFilter(&testSite{}, "status eq on", "", WithFilterColumns("status"))
+	assert.NoError(t, err)
+	_, err = NewFilter(&testSite{}, "", "weight", WithFilterColumns("status"))
+	assert.Error(t, err)
+}
+
+func TestSortBuild(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&testSite{}))
+	ctx := context.Background()
+	for i := 1; i <= 2; i++ {
+		site := testSite{Weight: i}
+		site.ID = uint64(i)
+		require.NoError(t, db.Create(&site).Error)
+	}
+
+	var sites []*testSite
+	query := &ListQuery{Sort: Sort{SortField: "weight asc"}}
+	require.NoError(t, query.Build(ctx, db.Model(&testSite{})).Find(&sites).Error)
+	require.Len(t, sites, 2)
+	assert.Equal(t, uint64(1), sites[0].ID)
+
+	// 排序列需在模型的白名单内
+	for _, sort := range []string{"unknown", "secret desc", "id; drop table test_site"} {
+		query = &ListQuery{Sort: Sort{SortField: sort}}
+		err := query.Build(ctx, db.Model(&testSite{})).Find(&sites).Error
+		assert.ErrorIs(t, err, code.ErrInvalidParam, sort)
+	}
+}
@@ -2,11 +2,11 @@ package storage
 
 import (
 	"context"
-	"fmt"
-	"strings"
 
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 
+	"template/pkg/code"
 	"template/pkg/utils/v"
 )
 
@@ -88,29 +88,15 @@ type Sort struct {
 	SortField string `form:"sort" json:"-" binding:"omitempty,order"`
 }
 
-func (s *Sort) Build(_ context.Context, query *gorm.DB, _ ...SQLOption) *gorm.DB {
-	// SortField 给多个字段排序
-	// created_at, id asc => order by created_at desc, id asc
-	defaultCreatedAtSort := true
-	if s.SortField != "" {
-		sorts := strings.Split(s.SortField, ",")
-		for _, field := range sorts {
-			if strings.Contains(field, "created_at") {
-				defaultCreatedAtSort = false
-			}
-			// 如果排序没有明确要按asc或desc来排序，则按照默认排序(倒序)
-			if !strings.HasSuffix(field, "asc") && !strings.HasSuffix(field, "desc") {
-				query = query.Order(fmt.Sprintf("%s desc", field))
-				continue
-			}
-			query = query.Order(field)
-		}
-	}
-	if defaultCreatedAtSort {
-		// 默认按created_at倒序排列
-		query = query.Order("created_at desc")
+// Build 以查询模型(需先通过 Model 指定)的列作为白名单校验排序条件,未指定时按 created_at 倒序,
+// 不合法时在查询中记录 code.ErrInvalidParam
+func (s *Sort) Build(ctx context.Context, query *gorm.DB, opts ...SQLOption) *gorm.DB {
+	filter, err := NewFilter(query.Statement.Model, "", s.SortField)
+	if err != nil {
+		_ = query.AddError(errors.WithStack(code.ErrInvalidParam.WithResult(err.Error())))
+		return query
 	}
-	return query
+	return filter.Build(ctx, query, opts...)
 }
 
 type ListQuery struct {
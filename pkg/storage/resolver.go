@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"template/pkg/selector"
+)
+
+// Replica 只读副本节点,账号、密码及数据库名称与主库一致
+type Replica struct {
+	IP     string
+	Port   string
+	Weight float64
+}
+
+// WithReplicas 设置只读副本,配置后查询语句将路由到副本执行
+func WithReplicas(replicas ...Replica) Option {
+	return func(o *option) {
+		o.replicas = replicas
+	}
+}
+
+// WithReplicaSelector 设置副本的选择策略,默认随机选择,
+// 可选 selector.NewRandom, selector.NewRoundRobin, selector.NewWeightedRoundRobin
+func WithReplicaSelector(newSelector func([]*selector.Node) selector.Selector) Option {
+	return func(o *option) {
+		o.newSelector = newSelector
+	}
+}
+
+// WithHealthCheckInterval 设置副本健康检查的间隔,检查失败的副本将暂时移出路由
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(o *option) {
+		o.healthCheckInterval = interval
+	}
+}
+
+type forcePrimaryKey struct{}
+
+// ForcePrimary 标记查询走主库,用于写后立即读的场景
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+// IsForcePrimary 判断查询是否需要走主库
+func IsForcePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	force, _ := ctx.Value(forcePrimaryKey{}).(bool)
+	return force
+}
+
+type replicaPool struct {
+	node    *selector.Node
+	db      *gorm.DB
+	healthy bool
+}
+
+func newResolver(ctx context.Context, o *option) (*resolver, error) {
+	r := &resolver{
+		newSelector: o.newSelector,
+		interval:    o.healthCheckInterval,
+		pools:       make(map[string]*replicaPool, len(o.replicas)),
+	}
+	if r.newSelector == nil {
+		r.newSelector = selector.NewRandom
+	}
+	for _, replica := range o.replicas {
+		replicaOption := *o
+		replicaOption.ip = replica.IP
+		replicaOption.port = replica.Port
+		dialector, err := newDialector(&replicaOption)
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		db, err := gorm.Open(dialector, newConfig(o))
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		var sqlDB *sql.DB
+		if sqlDB, err = db.DB(); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(o.maxOpenConn)
+		sqlDB.SetMaxIdleConns(o.maxIdleConn)
+		sqlDB.SetConnMaxLifetime(o.connMaxLifetime)
+
+		name := net.JoinHostPort(replicaOption.ip, replicaOption.port)
+		r.pools[name] = &replicaPool{
+			node: &selector.Node{
+				Name:   name,
+				URL:    url.URL{Scheme: string(o.dialect), Host: name},
+				Weight: replica.Weight,
+			},
+			db:      db,
+			healthy: true,
+		}
+	}
+	r.rebuild()
+	if r.interval > 0 {
+		ctx, r.cancel = context.WithCancel(ctx)
+		go r.healthCheck(ctx)
+	}
+	return r, nil
+}
+
+// resolver 读写分离插件,将查询语句路由到健康的只读副本
+type resolver struct {
+	newSelector func([]*selector.Node) selector.Selector
+	interval    time.Duration
+	cancel      context.CancelFunc
+
+	mux      sync.RWMutex
+	pools    map[string]*replicaPool
+	selector selector.Selector
+}
+
+func (r *resolver) Name() string {
+	return "Resolver"
+}
+
+func (r *resolver) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("gorm:query").
+		Register("resolver:query", r.switchReplica); err != nil {
+		return err
+	}
+	return db.Callback().Row().Before("gorm:row").
+		Register("resolver:row", r.switchReplica)
+}
+
+func (r *resolver) switchReplica(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	// 事务中的查询及显式要求主库的查询不做路由
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		return
+	}
+	if IsForcePrimary(db.Statement.Context) {
+		return
+	}
+	if _, ok := db.Statement.Clauses["FOR"]; ok {
+		return
+	}
+	if db.Statement.SQL.Len() > 0 {
+		sql := strings.ToUpper(strings.TrimSpace(db.Statement.SQL.String()))
+		if !strings.HasPrefix(sql, "SELECT") || strings.Contains(sql, "FOR UPDATE") {
+			return
+		}
+	}
+	if pool := r.next(); pool != nil {
+		db.Statement.ConnPool = pool
+	}
+}
+
+// next 选择一个健康的副本,没有健康的副本时返回nil即使用主库
+func (r *resolver) next() gorm.ConnPool {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	node := r.selector.Next()
+	if node == nil {
+		return nil
+	}
+	pool, ok := r.pools[node.Name]
+	if !ok {
+		return nil
+	}
+	return pool.db.Statement.ConnPool
+}
+
+// rebuild 根据副本的健康状态重建选择器,调用方需持有写锁或处于初始化阶段
+func (r *resolver) rebuild() {
+	nodes := make([]*selector.Node, 0, len(r.pools))
+	for _, pool := range r.pools {
+		if pool.healthy {
+			nodes = append(nodes, pool.node)
+		}
+	}
+	r.selector = r.newSelector(nodes)
+}
+
+func (r *resolver) healthCheck(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check(ctx)
+		}
+	}
+}
+
+func (r *resolver) check(ctx context.Context) {
+	r.mux.RLock()
+	pools := make([]*replicaPool, 0, len(r.pools))
+	for _, pool := range r.pools {
+		pools = append(pools, pool)
+	}
+	r.mux.RUnlock()
+
+	status := make(map[string]bool, len(pools))
+	for _, pool := range pools {
+		status[pool.node.Name] = r.ping(ctx, pool.db)
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	var changed bool
+	for name, healthy := range status {
+		if pool := r.pools[name]; pool.healthy != healthy {
+			pool.healthy = healthy
+			changed = true
+		}
+	}
+	if changed {
+		r.rebuild()
+	}
+}
+
+func (r *resolver) ping(ctx context.Context, db *gorm.DB) bool {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.interval)
+	defer cancel()
+	return sqlDB.PingContext(ctx) == nil
+}
+
+func (r *resolver) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	var err error
+	for _, pool := range r.pools {
+		sqlDB, dbErr := pool.db.DB()
+		if dbErr != nil {
+			err = dbErr
+			continue
+		}
+		if closeErr := sqlDB.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
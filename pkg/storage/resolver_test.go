@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"template/pkg/selector"
+)
+
+func TestResolver(t *testing.T) {
+	primary := newTestDB(t)
+	replica, err := New(context.Background(),
+		WithDialect(DialectSQLite),
+		WithDatabase("file:"+t.Name()+"_replica?mode=memory&cache=shared"),
+		WithMaxOpenConn(1),
+	)
+	require.NoError(t, err)
+	require.NoError(t, replica.AutoMigrate(&testArea{}))
+
+	r := &resolver{
+		newSelector: selector.NewRoundRobin,
+		pools: map[string]*replicaPool{
+			"replica": {node: &selector.Node{Name: "replica"}, db: replica.DB, healthy: true},
+		},
+	}
+	r.rebuild()
+	require.NoError(t, primary.Use(r))
+	primary.resolver = r
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+
+	onPrimary := &testArea{AreaCode: "primary"}
+	onPrimary.ID = 1
+	require.NoError(t, primary.Create(onPrimary).Error)
+	onReplica := &testArea{AreaCode: "replica"}
+	onReplica.ID = 1
+	require.NoError(t, replica.Create(onReplica).Error)
+
+	var got testArea
+	require.NoError(t, primary.First(&got).Error)
+	assert.Equal(t, "replica", got.AreaCode)
+
+	require.NoError(t, primary.WithContext(ForcePrimary(context.Background())).First(&got).Error)
+	assert.Equal(t, "primary", got.AreaCode)
+
+	require.NoError(t, primary.Transaction(func(tx *gorm.DB) error {
+		return tx.First(&got).Error
+	}))
+	assert.Equal(t, "primary", got.AreaCode)
+
+	// 副本不可用时回退到主库
+	r.mux.Lock()
+	r.pools["replica"].healthy = false
+	r.rebuild()
+	r.mux.Unlock()
+	require.NoError(t, primary.First(&got).Error)
+	assert.Equal(t, "primary", got.AreaCode)
+}
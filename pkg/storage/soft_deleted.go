@@ -116,7 +116,7 @@ func (s SoftDeleteDeletedClause) ModifyStatement(stmt *gorm.Statement) {
 	}
 	assignment := clause.Assignment{
 		Column: clause.Column{Name: s.Field.DBName},
-		Value:  gorm.Expr("`id`"),
+		Value:  clause.Column{Name: "id"},
 	}
 	curTime := stmt.NowFunc()
 	_, idOk := stmt.Schema.FieldsByDBName["id"]
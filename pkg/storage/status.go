@@ -80,7 +80,7 @@ func (s StatusDeleteDeleteClause) ModifyStatement(stmt *gorm.Statement) {
 	if _, ok := stmt.Schema.FieldsByName["Deleted"]; ok {
 		assignment := clause.Assignment{
 			Column: clause.Column{Name: "deleted"},
-			Value:  gorm.Expr("`id`"),
+			Value:  clause.Column{Name: "id"},
 		}
 		_, idOk := stmt.Schema.FieldsByDBName["id"]
 		if !idOk {
@@ -16,3 +16,14 @@ func EscapeQueryString(s string) string {
 	}
 	return s
 }
+
+// IsDuplicate reports whether err is caused by a unique key conflict
+func IsDuplicate(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, ErrDuplicate) || // MySQL
+		strings.Contains(msg, "SQLSTATE 23505") || // PostgreSQL
+		strings.Contains(msg, "UNIQUE constraint failed") // SQLite
+}
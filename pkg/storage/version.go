@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"template/pkg/code"
+)
+
+// versionEnabled 标记本次更新带有版本条件
+const versionEnabled = "version_enabled"
+
+// Version 乐观锁版本号,创建时为1,每次更新自增1。
+// 更新的模型中版本号非零,或Updates的map中包含版本列时,以该值作为更新条件,
+// 条件不满足导致未更新任何记录时返回 code.ErrVersionConflict
+type Version int64
+
+func (v Version) CreateClauses(field *schema.Field) []clause.Interface {
+	return []clause.Interface{VersionCreateClause{Field: field}}
+}
+
+type VersionCreateClause struct {
+	Field *schema.Field
+}
+
+func (v VersionCreateClause) Name() string {
+	return ""
+}
+
+func (v VersionCreateClause) Build(clause.Builder) {
+}
+
+func (v VersionCreateClause) MergeClause(*clause.Clause) {
+}
+
+func (v VersionCreateClause) ModifyStatement(stmt *gorm.Statement) {
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			v.initVersion(stmt, reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		v.initVersion(stmt, stmt.ReflectValue)
+	}
+}
+
+func (v VersionCreateClause) initVersion(stmt *gorm.Statement, value reflect.Value) {
+	if _, zero := v.Field.ValueOf(stmt.Context, value); zero {
+		_ = stmt.AddError(v.Field.Set(stmt.Context, value, Version(1)))
+	}
+}
+
+func (v Version) UpdateClauses(field *schema.Field) []clause.Interface {
+	return []clause.Interface{VersionUpdateClause{Field: field}}
+}
+
+type VersionUpdateClause struct {
+	Field *schema.Field
+}
+
+func (v VersionUpdateClause) Name() string {
+	return ""
+}
+
+func (v VersionUpdateClause) Build(clause.Builder) {
+}
+
+func (v VersionUpdateClause) MergeClause(*clause.Clause) {
+}
+
+func (v VersionUpdateClause) ModifyStatement(stmt *gorm.Statement) {
+	if _, ok := stmt.Clauses[versionEnabled]; ok || stmt.SQL.Len() != 0 {
+		return
+	}
+	expected, ok := v.expected(stmt)
+	if ok {
+		if c, ok := stmt.Clauses["WHERE"]; ok {
+			if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 1 {
+				for _, expr := range where.Exprs {
+					if orCond, ok := expr.(clause.OrConditions); ok && len(orCond.Exprs) == 1 {
+						where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
+						c.Expression = where
+						stmt.Clauses["WHERE"] = c
+						break
+					}
+				}
+			}
+		}
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: v.Field.DBName}, Value: expected},
+		}})
+		stmt.Clauses[versionEnabled] = clause.Clause{}
+	}
+	stmt.SetColumn(v.Field.DBName, clause.Expr{SQL: stmt.Quote(v.Field.DBName) + "+1"}, true)
+}
+
+// expected 获取更新条件中的版本号,并将更新的内容转换为map,以便对版本列赋值为表达式
+func (v VersionUpdateClause) expected(stmt *gorm.Statement) (interface{}, bool) {
+	var (
+		expected interface{}
+		found    bool
+	)
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		if value, zero := v.Field.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			expected, found = value, true
+		}
+	}
+
+	switch dest := stmt.Dest.(type) {
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(dest))
+		for key, value := range dest {
+			if key == v.Field.DBName || key == v.Field.Name {
+				expected, found = value, true
+				continue
+			}
+			values[key] = value
+		}
+		stmt.Dest = values
+	default:
+		destValue := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+		if destValue.Kind() != reflect.Struct {
+			break
+		}
+		s, err := schema.Parse(stmt.Dest, schemaCache, stmt.DB.NamingStrategy)
+		if err != nil {
+			_ = stmt.AddError(err)
+			break
+		}
+		if value, zero := v.Field.ValueOf(stmt.Context, destValue); !zero && s == stmt.Schema {
+			expected, found = value, true
+		}
+		// 与gorm对struct的处理一致,未Select时只更新非零值
+		selectColumns, restricted := stmt.SelectAndOmitColumns(false, true)
+		values := make(map[string]interface{}, len(s.Fields))
+		for _, field := range s.Fields {
+			if field.DBName == "" || field.DBName == v.Field.DBName || !field.Updatable || field.PrimaryKey ||
+				field.AutoUpdateTime > 0 {
+				continue
+			}
+			selected, ok := selectColumns[field.DBName]
+			if (ok && !selected) || (!ok && restricted) {
+				continue
+			}
+			value, zero := field.ValueOf(stmt.Context, destValue)
+			if ok || !zero {
+				values[field.DBName] = value
+			}
+		}
+		stmt.Dest = values
+	}
+	return expected, found
+}
+
+// checkVersion 带版本条件的更新未影响任何记录时返回版本冲突
+func checkVersion(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	if _, ok := db.Statement.Clauses[versionEnabled]; !ok {
+		return
+	}
+	if db.RowsAffected == 0 {
+		_ = db.AddError(errors.WithStack(code.ErrVersionConflict))
+		return
+	}
+	// 同步模型中的版本号
+	if db.Statement.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	for _, field := range db.Statement.Schema.Fields {
+		if field.FieldType != versionType {
+			continue
+		}
+		if value, zero := field.ValueOf(db.Statement.Context, db.Statement.ReflectValue); !zero {
+			if version, ok := value.(Version); ok {
+				_ = db.AddError(field.Set(db.Statement.Context, db.Statement.ReflectValue, version+1))
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"template/pkg/code"
+)
+
+type testVersioned struct {
+	Base
+	Name    string  `gorm:"column:name"`
+	Version Version `gorm:"column:version"`
+}
+
+func TestVersion(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&testVersioned{}))
+
+	obj := &testVersioned{Name: "a"}
+	obj.ID = 1
+	require.NoError(t, db.Create(obj).Error)
+	assert.Equal(t, Version(1), obj.Version)
+
+	// map中携带版本号时作为更新条件
+	require.NoError(t, db.Model(&testVersioned{}).Where("id = ?", obj.ID).
+		Updates(map[string]interface{}{"name": "b", "version": 1}).Error)
+	err := db.Model(&testVersioned{}).Where("id = ?", obj.ID).
+		Updates(map[string]interface{}{"name": "c", "version": 1}).Error
+	assert.ErrorIs(t, err, code.ErrVersionConflict)
+
+	var got testVersioned
+	require.NoError(t, db.First(&got, obj.ID).Error)
+	assert.Equal(t, "b", got.Name)
+	assert.Equal(t, Version(2), got.Version)
+
+	// 未携带版本号时直接更新,版本号自增
+	require.NoError(t, db.Model(&testVersioned{}).Where("id = ?", obj.ID).Update("name", "d").Error)
+	require.NoError(t, db.First(&got, obj.ID).Error)
+	assert.Equal(t, Version(3), got.Version)
+
+	// 模型中的版本号已过期
+	assert.ErrorIs(t, db.Model(obj).Updates(&testVersioned{Name: "e"}).Error, code.ErrVersionConflict)
+
+	require.NoError(t, db.Model(&got).Updates(&testVersioned{Name: "f"}).Error)
+	assert.Equal(t, Version(4), got.Version)
+	obj.Version, obj.Name = got.Version, "g"
+	require.NoError(t, db.Save(obj).Error)
+	assert.Equal(t, Version(5), obj.Version)
+
+	require.NoError(t, db.First(&got, obj.ID).Error)
+	assert.Equal(t, "g", got.Name)
+	assert.Equal(t, Version(5), got.Version)
+}
@@ -0,0 +1,113 @@
+package syncx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Lease 基于redis的租约,用于集群中的leader选举,
+// 每次获取成功时生成单调递增的fencing token,持有者可以用其拒绝过期leader的写入
+type Lease struct {
+	client redis.UniversalClient
+
+	acquireScript string
+	renewalScript string
+	releaseScript string
+
+	key      string
+	tokenKey string
+
+	option
+}
+
+func NewLease(key string, client redis.UniversalClient, opts ...Option) *Lease {
+	o := option{
+		expiration:     10 * time.Second,
+		clientIDPrefix: uuid.NewV4().String(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Lease{
+		client: client,
+		acquireScript: `
+	-- KEYS[1] 租约名
+	-- KEYS[2] fencing token
+	-- ARGV[1] 客户端标识
+	-- ARGV[2] 过期时间
+	if redis.call('set',KEYS[1],ARGV[1],'NX','PX',ARGV[2]) then
+		return redis.call('incr',KEYS[2])
+	end
+	if redis.call('get',KEYS[1]) == ARGV[1] then
+		redis.call('pexpire',KEYS[1],ARGV[2])
+		return tonumber(redis.call('get',KEYS[2]))
+	end
+	return 0
+`,
+		renewalScript: `
+	-- KEYS[1] 租约名
+	-- ARGV[1] 客户端标识
+	-- ARGV[2] 过期时间
+	if redis.call('get',KEYS[1]) == ARGV[1] then
+		return redis.call('pexpire',KEYS[1],ARGV[2])
+	end
+	return 0
+`,
+		releaseScript: `
+	-- KEYS[1] 租约名
+	-- ARGV[1] 客户端标识
+	if redis.call('get',KEYS[1]) == ARGV[1] then
+		return redis.call('del',KEYS[1])
+	end
+	return 0
+`,
+		// 使用hash tag保证集群模式下两个key位于同一个slot
+		key:      "{" + key + "}",
+		tokenKey: "{" + key + "}:fencing",
+		option:   o,
+	}
+}
+
+// Acquire 获取租约,已被其他客户端持有时返回 ErrNotObtained,成功时返回fencing token
+func (l *Lease) Acquire(ctx context.Context) (int64, error) {
+	token, err := l.client.Eval(ctx, l.acquireScript, []string{l.key, l.tokenKey},
+		l.clientIDPrefix, int64(l.expiration/time.Millisecond)).Int64()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if token == 0 {
+		return 0, fmt.Errorf("lease %s is held by another client,%w", l.key, ErrNotObtained)
+	}
+	return token, nil
+}
+
+// Renew 续约,租约已过期或被其他客户端持有时返回 ErrNotObtained
+func (l *Lease) Renew(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, l.renewalScript, []string{l.key},
+		l.clientIDPrefix, int64(l.expiration/time.Millisecond)).Int64()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if res == 0 {
+		return fmt.Errorf("lease %s is lost,%w", l.key, ErrNotObtained)
+	}
+	return nil
+}
+
+// Release 释放租约
+func (l *Lease) Release(ctx context.Context) error {
+	if err := l.client.Eval(ctx, l.releaseScript, []string{l.key}, l.clientIDPrefix).Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// TTL 租约的有效期
+func (l *Lease) TTL() time.Duration {
+	return l.expiration
+}
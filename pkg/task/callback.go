@@ -13,3 +13,42 @@ type CallbackFunc func(ctx context.Context, info StoreInfo, input interface{}, e
 func (d CallbackFunc) Trigger(ctx context.Context, info StoreInfo, input interface{}, err error) {
 	d(ctx, info, input, err)
 }
+
+// StartCallback 由 Execute 在提交前调用
+type StartCallback interface {
+	Start(ctx context.Context, task Task, input interface{})
+}
+
+// RollbackCallback 由 Execute 在提交失败后、回滚前调用,此后触发的回调来自回滚
+type RollbackCallback interface {
+	Rollback(ctx context.Context, task Task, input interface{}, err error)
+}
+
+// FinishCallback 由 Execute 在执行结束后调用,提交失败时在回滚后调用
+type FinishCallback interface {
+	Finish(ctx context.Context, task Task, input interface{}, err error)
+}
+
+func start(ctx context.Context, task Task, input interface{}, callbacks []Callback) {
+	for _, callback := range callbacks {
+		if c, ok := callback.(StartCallback); ok {
+			c.Start(ctx, task, input)
+		}
+	}
+}
+
+func rollback(ctx context.Context, task Task, input interface{}, err error, callbacks []Callback) {
+	for _, callback := range callbacks {
+		if c, ok := callback.(RollbackCallback); ok {
+			c.Rollback(ctx, task, input, err)
+		}
+	}
+}
+
+func finish(ctx context.Context, task Task, input interface{}, err error, callbacks []Callback) {
+	for _, callback := range callbacks {
+		if c, ok := callback.(FinishCallback); ok {
+			c.Finish(ctx, task, input, err)
+		}
+	}
+}
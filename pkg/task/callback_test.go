@@ -0,0 +1,70 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hookCallback struct {
+	mux      sync.Mutex
+	calls    []string
+	leaves   int
+	finished error
+}
+
+func (h *hookCallback) Start(_ context.Context, task Task, _ interface{}) {
+	h.leaves = CountLeaves(task)
+	h.add("start")
+}
+
+func (h *hookCallback) Trigger(_ context.Context, _ StoreInfo, _ interface{}, err error) {
+	if err == nil {
+		h.add("trigger")
+	}
+}
+
+func (h *hookCallback) Rollback(_ context.Context, _ Task, _ interface{}, _ error) {
+	h.add("rollback")
+}
+
+func (h *hookCallback) Finish(_ context.Context, _ Task, _ interface{}, err error) {
+	h.finished = err
+	h.add("finish")
+}
+
+func (h *hookCallback) add(call string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func TestExecute_hooks(t *testing.T) {
+	ok := func(context.Context, interface{}) error { return nil }
+	pipeline := PipelineTask(WithTasks(
+		NewFunc(ok),
+		ParallelTask(WithTasks(NewFunc(ok), NewFunc(ok))),
+		DAGTask(WithNodes(Node{Name: "a", Task: NewFunc(ok)}, Node{Name: "b", Task: NewFunc(ok), DependsOn: []string{"a"}})),
+	))
+	h := &hookCallback{}
+	assert.NoError(t, Execute(context.Background(), pipeline, nil, h))
+	assert.Equal(t, 5, h.leaves)
+	assert.Equal(t, []string{"start", "trigger", "trigger", "trigger", "trigger", "trigger", "finish"}, h.calls)
+	assert.NoError(t, h.finished)
+
+	h = &hookCallback{}
+	failed := NewFunc(func(context.Context, interface{}) error { return errors.New("failed") })
+	assert.NoError(t, InertExecute(context.Background(), failed, nil, h))
+	assert.Equal(t, 1, h.leaves)
+	assert.Equal(t, []string{"start", "rollback", "finish"}, h.calls)
+	assert.EqualError(t, h.finished, "failed")
+
+	// 回滚触发的回调在 Rollback 之后
+	h = &hookCallback{}
+	rollback := NewFuncTask(func(context.Context, interface{}) error { return errors.New("failed") }, ok)
+	assert.Error(t, Execute(context.Background(), PipelineTask(WithTasks(NewFuncTask(ok, ok), rollback)), nil, h))
+	assert.Equal(t, []string{"start", "trigger", "rollback", "trigger", "trigger", "finish"}, h.calls)
+}
@@ -0,0 +1,334 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+
+	"template/pkg/conc/pool"
+)
+
+// metadataGraph StoreInfo 元数据中各节点状态的key
+const metadataGraph = "graph"
+
+// Node DAG任务的节点
+type Node struct {
+	Name string
+	Task Task
+	// 依赖的节点名称,依赖的节点全部成功后才执行
+	DependsOn []string
+}
+
+// GraphNode 节点的执行状态
+type GraphNode struct {
+	Name      string   `json:"name"`
+	State     State    `json:"state"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// DAG 按依赖关系执行的任务
+type DAG interface {
+	Task
+	StoreInfo
+	// Graph 按拓扑顺序返回各节点的状态
+	Graph() []GraphNode
+	// DOT 导出为graphviz格式,节点颜色表示状态
+	DOT() string
+	// JSON 导出为json格式
+	JSON() ([]byte, error)
+}
+
+type dagNode struct {
+	Node
+	// 捕获panic的任务
+	task       Task
+	dependents []string
+	state      State
+	err        error
+}
+
+type dagTask struct {
+	StoreInfo
+	commitCallbacks   []Callback
+	rollbackCallbacks []Callback
+	maxConcurrency    int
+	// 拓扑顺序的节点
+	order []*dagNode
+	nodes map[string]*dagNode
+	mux   sync.RWMutex
+	// 节点定义错误,执行时返回
+	err error
+}
+
+// DAGTask 按依赖关系执行节点,无依赖关系的节点并发执行。
+// 失败时按拓扑逆序回滚已成功的节点,节点定义存在环或缺少依赖时执行返回错误
+func DAGTask(opts ...Option) DAG {
+	uid := uuid.NewV1()
+	o := &taskOption{
+		storeInfo:         DefaultTaskInfo(uid.String()),
+		commitCallbacks:   make([]Callback, 0),
+		rollbackCallbacks: make([]Callback, 0),
+		nodes:             make([]Node, 0),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	d := &dagTask{
+		StoreInfo:         o.storeInfo,
+		commitCallbacks:   o.commitCallbacks,
+		rollbackCallbacks: o.rollbackCallbacks,
+		maxConcurrency:    o.maxConcurrency,
+		nodes:             make(map[string]*dagNode, len(o.nodes)),
+	}
+	if o.storeInfo.Name() == "" {
+		d.SetName("dag-task-" + o.storeInfo.ID())
+	}
+	if d.err = d.build(o.nodes); d.err != nil {
+		d.AddError(d.err, true)
+		return d
+	}
+	d.refresh()
+	return d
+}
+
+// build 校验节点并按拓扑排序
+func (d *dagTask) build(nodes []Node) error {
+	list := make([]*dagNode, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Name == "" || node.Task == nil {
+			return errors.New("node name and task are required")
+		}
+		if _, ok := d.nodes[node.Name]; ok {
+			return errors.Errorf("duplicate node %s", node.Name)
+		}
+		n := &dagNode{Node: node, task: SafeTask(node.Task), state: Ready}
+		d.nodes[node.Name] = n
+		list = append(list, n)
+	}
+	waiting := make(map[string]int, len(list))
+	for _, n := range list {
+		for _, dep := range n.DependsOn {
+			parent, ok := d.nodes[dep]
+			if !ok {
+				return errors.Errorf("node %s depends on unknown node %s", n.Name, dep)
+			}
+			parent.dependents = append(parent.dependents, n.Name)
+		}
+		waiting[n.Name] = len(n.DependsOn)
+	}
+	queue := make([]*dagNode, 0, len(list))
+	for _, n := range list {
+		if waiting[n.Name] == 0 {
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		d.order = append(d.order, n)
+		for _, name := range n.dependents {
+			if waiting[name]--; waiting[name] == 0 {
+				queue = append(queue, d.nodes[name])
+			}
+		}
+	}
+	if len(d.order) != len(list) {
+		cycle := make([]string, 0)
+		for _, n := range list {
+			if waiting[n.Name] > 0 {
+				cycle = append(cycle, n.Name)
+			}
+		}
+		return errors.Errorf("dependency cycle in nodes %s", strings.Join(cycle, ","))
+	}
+	return nil
+}
+
+func (d *dagTask) Commit(ctx context.Context, input interface{}, callbacks ...Callback) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.SetState(Running)
+	err := d.walk(ctx, false, func(ctx context.Context, n *dagNode) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		d.setState(n, Running, nil)
+		if err := n.task.Commit(ctx, input, callbacks...); err != nil {
+			d.setState(n, Error, err)
+			return errors.WithMessagef(err, "node %s", n.Name)
+		}
+		d.setState(n, Success, nil)
+		return nil
+	})
+	d.AddError(err, true)
+	for _, callback := range d.commitCallbacks {
+		callback.Trigger(ctx, d, input, err)
+	}
+	return err
+}
+
+func (d *dagTask) Children() []Task {
+	tasks := make([]Task, 0, len(d.order))
+	for _, n := range d.order {
+		tasks = append(tasks, n.Task)
+	}
+	return tasks
+}
+
+func (d *dagTask) Rollback(ctx context.Context, input interface{}, callbacks ...Callback) error {
+	if d.err != nil {
+		return nil
+	}
+	err := d.walk(ctx, true, func(ctx context.Context, n *dagNode) error {
+		d.mux.RLock()
+		state := n.state
+		d.mux.RUnlock()
+		if state != Success {
+			return nil
+		}
+		if err := n.task.Rollback(ctx, input, callbacks...); err != nil {
+			d.setState(n, Error, err)
+			return errors.WithMessagef(err, "node %s", n.Name)
+		}
+		d.setState(n, Compensated, nil)
+		return nil
+	})
+	d.AddError(err, true)
+	for _, callback := range d.rollbackCallbacks {
+		callback.Trigger(ctx, d, input, err)
+	}
+	return err
+}
+
+// walk 按拓扑顺序并发访问节点,reverse时按逆序访问。
+// 提交时任一节点失败即取消其他节点,回滚时节点失败仅不再访问其依赖的节点
+func (d *dagTask) walk(ctx context.Context, reverse bool, visit func(context.Context, *dagNode) error) error {
+	type result struct {
+		node *dagNode
+		err  error
+	}
+	waiting := make(map[string]int, len(d.order))
+	for _, n := range d.order {
+		if reverse {
+			waiting[n.Name] = len(n.dependents)
+		} else {
+			waiting[n.Name] = len(n.DependsOn)
+		}
+	}
+	p := pool.New().WithContext(ctx)
+	if !reverse {
+		p = p.WithFailFast()
+	}
+	if d.maxConcurrency > 0 {
+		p = p.WithMaxGoroutines(d.maxConcurrency)
+	}
+	// 每个节点最多执行一次,容量足够时执行完成的节点不会阻塞
+	done := make(chan result, len(d.order))
+	running := 0
+	submit := func(n *dagNode) {
+		running++
+		p.Go(func(ctx context.Context) error {
+			err := visit(ctx, n)
+			done <- result{node: n, err: err}
+			return err
+		})
+	}
+	for _, n := range d.order {
+		if waiting[n.Name] == 0 {
+			submit(n)
+		}
+	}
+	failed := false
+	for running > 0 {
+		r := <-done
+		running--
+		if r.err != nil {
+			failed = true
+			continue
+		}
+		if failed && !reverse {
+			continue
+		}
+		next := r.node.dependents
+		if reverse {
+			next = r.node.DependsOn
+		}
+		for _, name := range next {
+			if waiting[name]--; waiting[name] == 0 {
+				submit(d.nodes[name])
+			}
+		}
+	}
+	return p.Wait()
+}
+
+func (d *dagTask) setState(n *dagNode, state State, err error) {
+	d.mux.Lock()
+	n.state, n.err = state, err
+	d.mux.Unlock()
+	d.refresh()
+}
+
+// refresh 将各节点状态写入元数据
+func (d *dagTask) refresh() {
+	d.SetMetadata(map[string]interface{}{
+		metadataGraph: d.Graph(),
+	})
+}
+
+func (d *dagTask) Graph() []GraphNode {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+	graph := make([]GraphNode, 0, len(d.order))
+	for _, n := range d.order {
+		node := GraphNode{
+			Name:      n.Name,
+			State:     n.state,
+			DependsOn: n.DependsOn,
+		}
+		if n.err != nil {
+			node.Error = n.err.Error()
+		}
+		graph = append(graph, node)
+	}
+	return graph
+}
+
+var stateColors = map[State]string{
+	Ready:       "gray",
+	Running:     "blue",
+	Success:     "green",
+	Error:       "red",
+	Compensated: "orange",
+}
+
+func (d *dagTask) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", d.Name())
+	graph := d.Graph()
+	for _, n := range graph {
+		fmt.Fprintf(&b, "  %q [label=%q, color=%s];\n", n.Name, n.Name+"\n"+string(n.State), stateColors[n.State])
+	}
+	for _, n := range graph {
+		for _, dep := range n.DependsOn {
+			fmt.Fprintf(&b, "  %q -> %q;\n", dep, n.Name)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (d *dagTask) JSON() ([]byte, error) {
+	data, err := json.Marshal(d.Graph())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
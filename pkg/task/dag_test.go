@@ -0,0 +1,150 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dagRecorder 记录节点的执行顺序及最大并发数
+type dagRecorder struct {
+	mux     sync.Mutex
+	calls   []string
+	running int32
+	max     int32
+}
+
+func (r *dagRecorder) node(name string, fail bool, deps ...string) Node {
+	return Node{
+		Name:      name,
+		DependsOn: deps,
+		Task: NewFuncTask(func(context.Context, interface{}) error {
+			cur := atomic.AddInt32(&r.running, 1)
+			defer atomic.AddInt32(&r.running, -1)
+			for {
+				max := atomic.LoadInt32(&r.max)
+				if cur <= max || atomic.CompareAndSwapInt32(&r.max, max, cur) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			r.add("commit " + name)
+			if fail {
+				return errors.New(name + " failed")
+			}
+			return nil
+		}, func(context.Context, interface{}) error {
+			r.add("rollback " + name)
+			return nil
+		}),
+	}
+}
+
+func (r *dagRecorder) add(call string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *dagRecorder) index(call string) int {
+	for i, c := range r.calls {
+		if c == call {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestDAGTask_Commit(t *testing.T) {
+	r := &dagRecorder{}
+	// a -> b, a -> c, b -> d, c -> d
+	d := DAGTask(WithNodes(
+		r.node("d", false, "b", "c"),
+		r.node("b", false, "a"),
+		r.node("c", false, "a"),
+		r.node("a", false),
+	))
+	require.NoError(t, Execute(context.Background(), d, nil))
+	require.Len(t, r.calls, 4)
+	assert.Equal(t, "commit a", r.calls[0])
+	assert.Equal(t, "commit d", r.calls[3])
+	assert.Equal(t, int32(2), r.max)
+	assert.Equal(t, Success, d.State())
+
+	graph, ok := d.Metadata()[metadataGraph].([]GraphNode)
+	require.True(t, ok)
+	require.Len(t, graph, 4)
+	assert.Equal(t, "a", graph[0].Name)
+	for _, n := range graph {
+		assert.Equal(t, Success, n.State)
+	}
+	assert.Contains(t, d.DOT(), `"b" -> "d";`)
+	data, err := d.JSON()
+	require.NoError(t, err)
+	var nodes []GraphNode
+	require.NoError(t, json.Unmarshal(data, &nodes))
+	assert.Equal(t, graph, nodes)
+}
+
+func TestDAGTask_maxConcurrency(t *testing.T) {
+	r := &dagRecorder{}
+	d := DAGTask(WithMaxConcurrency(2), WithNodes(
+		r.node("a", false), r.node("b", false), r.node("c", false), r.node("d", false), r.node("e", false),
+	))
+	require.NoError(t, d.Commit(context.Background(), nil))
+	assert.Len(t, r.calls, 5)
+	assert.Equal(t, int32(2), r.max)
+}
+
+func TestDAGTask_Rollback(t *testing.T) {
+	r := &dagRecorder{}
+	// c 失败,d 不执行,回滚时先回滚 b 再回滚 a
+	d := DAGTask(WithNodes(
+		r.node("a", false),
+		r.node("b", false, "a"),
+		r.node("c", true, "a"),
+		r.node("d", false, "b", "c"),
+	))
+	err := Execute(context.Background(), d, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "c failed")
+	assert.Equal(t, -1, r.index("commit d"))
+	assert.Equal(t, -1, r.index("rollback c"))
+	assert.Less(t, r.index("rollback b"), r.index("rollback a"))
+
+	states := make(map[string]State)
+	for _, n := range d.Graph() {
+		states[n.Name] = n.State
+	}
+	assert.Equal(t, map[string]State{"a": Compensated, "b": Compensated, "c": Error, "d": Ready}, states)
+}
+
+func TestDAGTask_invalid(t *testing.T) {
+	r := &dagRecorder{}
+	tests := []struct {
+		name  string
+		nodes []Node
+		err   string
+	}{
+		{"cycle", []Node{r.node("a", false, "b"), r.node("b", false, "a"), r.node("c", false)}, "cycle in nodes a,b"},
+		{"unknown", []Node{r.node("a", false, "x")}, "unknown node x"},
+		{"duplicate", []Node{r.node("a", false), r.node("a", false)}, "duplicate node a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DAGTask(WithNodes(tt.nodes...))
+			err := d.Commit(context.Background(), nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+			assert.Equal(t, Error, d.State())
+		})
+	}
+	assert.Empty(t, r.calls)
+}
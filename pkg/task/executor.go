@@ -11,19 +11,28 @@ import (
 
 // Execute 在执行阶段不允许任何错误
 func Execute(ctx context.Context, task Task, input interface{}, callbacks ...Callback) error {
+	start(ctx, task, input, callbacks)
 	err := task.Commit(ctx, input, callbacks...)
-	if err == nil {
-		return nil
+	if err != nil {
+		rollback(ctx, task, input, err, callbacks)
+		err = multierr.Append(err, task.Rollback(ctx, input, callbacks...))
 	}
-	return multierr.Append(err, task.Rollback(ctx, input, callbacks...))
+	finish(ctx, task, input, err, callbacks)
+	return err
 }
 
 // InertExecute 惰性执行器,如果发生错误，它将打印提示，但是会忽略commit的错误
 func InertExecute(ctx context.Context, task Task, input interface{}, callbacks ...Callback) error {
+	start(ctx, task, input, callbacks)
 	err := task.Commit(ctx, input, callbacks...)
 	if err == nil {
+		finish(ctx, task, input, nil, callbacks)
 		return nil
 	}
 	logger.From(ctx).Warn("commit failed", zap.Error(err))
-	return task.Rollback(ctx, input, callbacks...)
+	rollback(ctx, task, input, err, callbacks)
+	rerr := task.Rollback(ctx, input, callbacks...)
+	// 执行结束的回调仍然记录commit的错误
+	finish(ctx, task, input, multierr.Append(err, rerr), callbacks)
+	return rerr
 }
@@ -11,6 +11,24 @@ import (
 	"template/pkg/conc/pool"
 )
 
+// Composite 由子任务组成的任务
+type Composite interface {
+	Children() []Task
+}
+
+// CountLeaves 返回任务包含的非组合任务数,用于计算进度
+func CountLeaves(task Task) int {
+	c, ok := task.(Composite)
+	if !ok {
+		return 1
+	}
+	count := 0
+	for _, child := range c.Children() {
+		count += CountLeaves(child)
+	}
+	return count
+}
+
 type pipelineTask struct {
 	StoreInfo
 	commitCallbacks   []Callback
@@ -60,6 +78,10 @@ func (s *pipelineTask) Commit(ctx context.Context, input interface{}, callbacks
 	return err
 }
 
+func (s *pipelineTask) Children() []Task {
+	return s.tasks
+}
+
 func (s *pipelineTask) Rollback(ctx context.Context, input interface{}, callbacks ...Callback) error {
 	var err error
 	for i := s.cur; i >= 0; i-- {
@@ -129,6 +151,10 @@ func (p *parallelTask) Commit(ctx context.Context, input interface{}, callbacks
 	return err
 }
 
+func (p *parallelTask) Children() []Task {
+	return p.tasks
+}
+
 func (p *parallelTask) Rollback(ctx context.Context, input interface{}, callbacks ...Callback) error {
 	g := pool.New().WithContext(ctx).WithCancelOnError()
 	for index, task := range p.tasks {
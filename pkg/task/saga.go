@@ -0,0 +1,345 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"template/pkg/logger"
+)
+
+const (
+	// Compensating 步骤执行失败,正在回滚已完成的步骤
+	Compensating State = "compensating"
+	// Compensated 已完成回滚
+	Compensated State = "compensated"
+)
+
+// DefaultSagaLease 执行中saga的默认租约时长
+const DefaultSagaLease = 30 * time.Second
+
+var (
+	ErrSagaNotFound      = errors.New("saga not found")
+	ErrSagaNotRegistered = errors.New("saga not registered")
+)
+
+// SagaRecord 持久化的saga执行状态
+type SagaRecord struct {
+	ID    string `json:"id" gorm:"column:id;primaryKey;size:64;comment:执行ID"`
+	Name  string `json:"name" gorm:"column:name;comment:saga名称"`
+	State State  `json:"state" gorm:"column:state;comment:执行状态"`
+	// 最近一次完成步骤后的输入
+	Input []byte `json:"input" gorm:"column:input;comment:输入参数"`
+	// 下一个执行的步骤
+	Step   int    `json:"step" gorm:"column:step;comment:下一个执行的步骤"`
+	Reason string `json:"reason" gorm:"column:reason;comment:失败原因"`
+	// 执行该saga的实例,执行期间定期续期租约,租约过期后由其他实例恢复执行
+	Owner      string    `json:"owner" gorm:"column:owner;size:64;comment:执行实例"`
+	LeaseUntil time.Time `json:"lease_until" gorm:"column:lease_until;comment:租约过期时间"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;comment:创建时间"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"column:updated_at;comment:更新时间"`
+}
+
+func (SagaRecord) TableName() string {
+	return "saga"
+}
+
+// SagaStep 持久化的步骤执行状态
+type SagaStep struct {
+	SagaID string `json:"saga_id" gorm:"column:saga_id;primaryKey;size:64;comment:执行ID"`
+	Step   int    `json:"step" gorm:"column:step;primaryKey;autoIncrement:false;comment:步骤序号"`
+	Name   string `json:"name" gorm:"column:name;comment:步骤名称"`
+	State  State  `json:"state" gorm:"column:state;comment:执行状态"`
+	// 执行该步骤时的输入,回滚时使用
+	Input     []byte    `json:"input" gorm:"column:input;comment:输入参数"`
+	Reason    string    `json:"reason" gorm:"column:reason;comment:失败原因"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;comment:创建时间"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;comment:更新时间"`
+}
+
+func (SagaStep) TableName() string {
+	return "saga_step"
+}
+
+// SagaStore saga执行状态的持久化存储
+type SagaStore interface {
+	// SaveSaga 保存执行状态,已存在时覆盖
+	SaveSaga(ctx context.Context, record *SagaRecord) error
+	// GetSaga 获取执行状态,不存在时返回 ErrSagaNotFound
+	GetSaga(ctx context.Context, id string) (*SagaRecord, error)
+	// ListUnfinished 获取租约在now之前过期的执行中及回滚中的saga
+	ListUnfinished(ctx context.Context, now time.Time) ([]*SagaRecord, error)
+	// ClaimSaga 未完成的saga租约属于owner或在now之前过期时,由owner持有租约至until,返回是否成功
+	ClaimSaga(ctx context.Context, id, owner string, now, until time.Time) (bool, error)
+	// SaveStep 保存步骤状态,已存在时覆盖
+	SaveStep(ctx context.Context, step *SagaStep) error
+	// ListSteps 按步骤序号获取saga的所有步骤
+	ListSteps(ctx context.Context, sagaID string) ([]*SagaStep, error)
+}
+
+// Step saga的步骤
+type Step struct {
+	Name string
+	Task Task
+}
+
+// Saga 按顺序执行的步骤,失败时逆序回滚已执行的步骤。
+// 重启后未完成的步骤会重新执行,步骤的 Commit 及 Rollback 需要保证幂等
+type Saga struct {
+	Name  string
+	Steps []Step
+	// NewInput 创建输入的指针,用于从存储中反序列化输入,为空时反序列化为 interface{}
+	NewInput func() interface{}
+}
+
+// SagaOption saga执行器的配置
+type SagaOption func(*SagaExecutor)
+
+// WithSagaOwner 指定执行器所在实例的标识,默认随机生成
+func WithSagaOwner(owner string) SagaOption {
+	return func(e *SagaExecutor) {
+		e.owner = owner
+	}
+}
+
+// WithSagaLease 指定执行中saga的租约时长,不大于0时使用 DefaultSagaLease
+func WithSagaLease(ttl time.Duration) SagaOption {
+	return func(e *SagaExecutor) {
+		if ttl > 0 {
+			e.ttl = ttl
+		}
+	}
+}
+
+// SagaExecutor 持久化执行状态的saga执行器
+type SagaExecutor struct {
+	store SagaStore
+	sagas sync.Map
+	owner string
+	ttl   time.Duration
+}
+
+func NewSagaExecutor(store SagaStore, opts ...SagaOption) *SagaExecutor {
+	e := &SagaExecutor{store: store, owner: uuid.NewV4().String(), ttl: DefaultSagaLease}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Register 注册saga,恢复执行时根据名称查找,同名时覆盖
+func (e *SagaExecutor) Register(saga *Saga) error {
+	if saga.Name == "" || len(saga.Steps) == 0 {
+		return errors.New("saga name and steps are required")
+	}
+	e.sagas.Store(saga.Name, saga)
+	return nil
+}
+
+// Execute 执行saga,返回执行ID。步骤失败时回滚已执行的步骤并返回步骤的错误
+func (e *SagaExecutor) Execute(ctx context.Context, name string, input interface{}, callbacks ...Callback) (string, error) {
+	saga, err := e.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	record := &SagaRecord{
+		ID:    uuid.NewV1().String(),
+		Name:  name,
+		State: Running,
+		Input: data,
+		Owner: e.owner,
+	}
+	if err = e.save(ctx, record); err != nil {
+		return "", err
+	}
+	return record.ID, e.run(ctx, saga, record, input, callbacks)
+}
+
+// Resume 恢复租约过期的未完成saga,执行中的继续执行,回滚中的继续回滚,
+// 需在注册全部saga后调用;恢复前先获取租约,多个实例同时调用时每个saga只由一个实例恢复
+func (e *SagaExecutor) Resume(ctx context.Context, callbacks ...Callback) error {
+	now := time.Now()
+	records, err := e.store.ListUnfinished(ctx, now)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, record := range records {
+		ok, err := e.store.ClaimSaga(ctx, record.ID, e.owner, now, time.Now().Add(e.ttl))
+		if err != nil {
+			errs = multierr.Append(errs, errors.WithMessagef(err, "claim saga %s", record.ID))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		record.Owner = e.owner
+		saga, err := e.lookup(record.Name)
+		if err != nil {
+			errs = multierr.Append(errs, errors.WithMessagef(err, "resume saga %s", record.ID))
+			continue
+		}
+		input, err := saga.decode(record.Input)
+		if err != nil {
+			errs = multierr.Append(errs, errors.WithMessagef(err, "resume saga %s", record.ID))
+			continue
+		}
+		if err = e.run(ctx, saga, record, input, callbacks); err != nil {
+			errs = multierr.Append(errs, errors.WithMessagef(err, "resume saga %s", record.ID))
+		}
+	}
+	return errs
+}
+
+func (e *SagaExecutor) lookup(name string) (*Saga, error) {
+	v, ok := e.sagas.Load(name)
+	if !ok {
+		return nil, errors.Wrap(ErrSagaNotRegistered, name)
+	}
+	return v.(*Saga), nil
+}
+
+func (e *SagaExecutor) run(ctx context.Context, saga *Saga, record *SagaRecord, input interface{}, callbacks []Callback) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go e.keepalive(ctx, cancel, record.ID)
+	if record.State == Running {
+		failed, err := e.commit(ctx, saga, record, input, callbacks)
+		// 存储失败或被取消时保持执行中,重启后继续执行
+		if !failed || ctx.Err() != nil {
+			return err
+		}
+		record.State = Compensating
+		record.Reason = err.Error()
+		if serr := e.save(ctx, record); serr != nil {
+			return multierr.Append(err, serr)
+		}
+		return multierr.Append(err, e.compensate(ctx, saga, record, callbacks))
+	}
+	return e.compensate(ctx, saga, record, callbacks)
+}
+
+// commit 从记录的步骤开始执行,failed表示步骤执行失败需要回滚
+func (e *SagaExecutor) commit(ctx context.Context, saga *Saga, record *SagaRecord, input interface{}, callbacks []Callback) (bool, error) {
+	for record.Step < len(saga.Steps) {
+		step := saga.Steps[record.Step]
+		data, err := json.Marshal(input)
+		if err != nil {
+			return true, errors.WithStack(err)
+		}
+		s := &SagaStep{
+			SagaID: record.ID,
+			Step:   record.Step,
+			Name:   step.Name,
+			State:  Running,
+			Input:  data,
+		}
+		if err = e.store.SaveStep(ctx, s); err != nil {
+			return false, err
+		}
+		if err = step.Task.Commit(ctx, input, callbacks...); err != nil {
+			s.State, s.Reason = Error, err.Error()
+			return true, multierr.Append(err, e.store.SaveStep(ctx, s))
+		}
+		s.State = Success
+		if err = e.store.SaveStep(ctx, s); err != nil {
+			return false, err
+		}
+		// 步骤可能修改输入,保存修改后的输入供后续步骤恢复
+		if record.Input, err = json.Marshal(input); err != nil {
+			return true, errors.WithStack(err)
+		}
+		record.Step++
+		if err = e.save(ctx, record); err != nil {
+			return false, err
+		}
+	}
+	record.State = Success
+	return false, e.save(ctx, record)
+}
+
+// compensate 逆序回滚已执行的步骤,回滚失败时停止并保持回滚中,重启后继续回滚
+func (e *SagaExecutor) compensate(ctx context.Context, saga *Saga, record *SagaRecord, callbacks []Callback) error {
+	steps, err := e.store.ListSteps(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		s := steps[i]
+		if s.State == Compensated {
+			continue
+		}
+		if s.Step >= len(saga.Steps) {
+			return errors.Errorf("saga %s has no step %d", saga.Name, s.Step)
+		}
+		input, err := saga.decode(s.Input)
+		if err != nil {
+			return err
+		}
+		if err = saga.Steps[s.Step].Task.Rollback(ctx, input, callbacks...); err != nil {
+			s.Reason = err.Error()
+			return multierr.Append(err, e.store.SaveStep(ctx, s))
+		}
+		s.State = Compensated
+		if err = e.store.SaveStep(ctx, s); err != nil {
+			return err
+		}
+	}
+	record.State = Compensated
+	return e.save(ctx, record)
+}
+
+// save 保存执行状态并续期租约
+func (e *SagaExecutor) save(ctx context.Context, record *SagaRecord) error {
+	record.LeaseUntil = time.Now().Add(e.ttl)
+	return e.store.SaveSaga(ctx, record)
+}
+
+// keepalive 执行期间定期续期租约,租约被其他实例接管时取消执行
+func (e *SagaExecutor) keepalive(ctx context.Context, cancel context.CancelFunc, id string) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		ok, err := e.store.ClaimSaga(ctx, id, e.owner, now, now.Add(e.ttl))
+		if err != nil {
+			logger.From(ctx).Warn("renew saga lease failed", zap.String("id", id), zap.Error(err))
+			continue
+		}
+		if !ok {
+			logger.From(ctx).Warn("saga lease lost", zap.String("id", id))
+			cancel()
+			return
+		}
+	}
+}
+
+func (s *Saga) decode(data []byte) (interface{}, error) {
+	if s.NewInput == nil {
+		var input interface{}
+		if err := json.Unmarshal(data, &input); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return input, nil
+	}
+	input := s.NewInput()
+	if err := json.Unmarshal(data, input); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return input, nil
+}
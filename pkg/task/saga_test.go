@@ -0,0 +1,278 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memSagaStore struct {
+	mux   sync.Mutex
+	sagas map[string]SagaRecord
+	steps map[string]map[int]SagaStep
+}
+
+func newMemSagaStore() *memSagaStore {
+	return &memSagaStore{
+		sagas: make(map[string]SagaRecord),
+		steps: make(map[string]map[int]SagaStep),
+	}
+}
+
+func (m *memSagaStore) SaveSaga(_ context.Context, record *SagaRecord) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.sagas[record.ID] = *record
+	return nil
+}
+
+func (m *memSagaStore) GetSaga(_ context.Context, id string) (*SagaRecord, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	record, ok := m.sagas[id]
+	if !ok {
+		return nil, ErrSagaNotFound
+	}
+	return &record, nil
+}
+
+func (m *memSagaStore) ListUnfinished(_ context.Context, now time.Time) ([]*SagaRecord, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	var list []*SagaRecord
+	for _, record := range m.sagas {
+		record := record
+		if (record.State == Running || record.State == Compensating) && record.LeaseUntil.Before(now) {
+			list = append(list, &record)
+		}
+	}
+	return list, nil
+}
+
+func (m *memSagaStore) ClaimSaga(_ context.Context, id, owner string, now, until time.Time) (bool, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	record, ok := m.sagas[id]
+	if !ok || (record.State != Running && record.State != Compensating) ||
+		(record.Owner != owner && !record.LeaseUntil.Before(now)) {
+		return false, nil
+	}
+	record.Owner, record.LeaseUntil = owner, until
+	m.sagas[id] = record
+	return true, nil
+}
+
+func (m *memSagaStore) SaveStep(_ context.Context, step *SagaStep) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.steps[step.SagaID] == nil {
+		m.steps[step.SagaID] = make(map[int]SagaStep)
+	}
+	m.steps[step.SagaID][step.Step] = *step
+	return nil
+}
+
+func (m *memSagaStore) ListSteps(_ context.Context, sagaID string) ([]*SagaStep, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	list := make([]*SagaStep, 0, len(m.steps[sagaID]))
+	for _, step := range m.steps[sagaID] {
+		step := step
+		list = append(list, &step)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Step < list[j].Step
+	})
+	return list, nil
+}
+
+type orderInput struct {
+	Count int `json:"count"`
+}
+
+// recorder 记录步骤的执行顺序
+type recorder struct {
+	mux   sync.Mutex
+	calls []string
+}
+
+func (r *recorder) step(name string, fail, failRollback bool) Step {
+	return Step{
+		Name: name,
+		Task: NewFuncTask(func(_ context.Context, input interface{}) error {
+			r.add("commit " + name)
+			if fail {
+				return errors.New(name + " failed")
+			}
+			input.(*orderInput).Count++
+			return nil
+		}, func(_ context.Context, input interface{}) error {
+			r.add("rollback " + name)
+			if failRollback {
+				return errors.New(name + " rollback failed")
+			}
+			return nil
+		}),
+	}
+}
+
+func (r *recorder) add(call string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func newOrderSaga(steps ...Step) *Saga {
+	return &Saga{
+		Name:  "order",
+		Steps: steps,
+		NewInput: func() interface{} {
+			return &orderInput{}
+		},
+	}
+}
+
+func TestSagaExecutor_Execute(t *testing.T) {
+	ctx := context.Background()
+	store := newMemSagaStore()
+	e := NewSagaExecutor(store)
+	r := &recorder{}
+	require.NoError(t, e.Register(newOrderSaga(r.step("a", false, false), r.step("b", false, false))))
+
+	input := &orderInput{}
+	id, err := e.Execute(ctx, "order", input)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"commit a", "commit b"}, r.calls)
+	record, err := store.GetSaga(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, Success, record.State)
+	assert.Equal(t, 2, record.Step)
+	assert.JSONEq(t, `{"count":2}`, string(record.Input))
+
+	_, err = e.Execute(ctx, "unknown", input)
+	assert.ErrorIs(t, err, ErrSagaNotRegistered)
+}
+
+func TestSagaExecutor_compensate(t *testing.T) {
+	ctx := context.Background()
+	store := newMemSagaStore()
+	e := NewSagaExecutor(store)
+	r := &recorder{}
+	require.NoError(t, e.Register(newOrderSaga(r.step("a", false, false), r.step("b", false, false), r.step("c", true, false))))
+
+	id, err := e.Execute(ctx, "order", &orderInput{})
+	require.Error(t, err)
+	assert.Equal(t, []string{"commit a", "commit b", "commit c", "rollback c", "rollback b", "rollback a"}, r.calls)
+	record, err := store.GetSaga(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, Compensated, record.State)
+	assert.Contains(t, record.Reason, "c failed")
+
+	steps, err := store.ListSteps(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, steps, 3)
+	// 回滚时使用执行该步骤时的输入
+	assert.JSONEq(t, `{"count":1}`, string(steps[1].Input))
+	for _, step := range steps {
+		assert.Equal(t, Compensated, step.State)
+	}
+}
+
+func TestSagaExecutor_Resume(t *testing.T) {
+	ctx := context.Background()
+	store := newMemSagaStore()
+	// 模拟在执行第二步时崩溃
+	require.NoError(t, store.SaveSaga(ctx, &SagaRecord{ID: "running", Name: "order", State: Running, Input: []byte(`{"count":1}`), Step: 1}))
+	require.NoError(t, store.SaveStep(ctx, &SagaStep{SagaID: "running", Step: 0, Name: "a", State: Success, Input: []byte(`{"count":0}`)}))
+	require.NoError(t, store.SaveStep(ctx, &SagaStep{SagaID: "running", Step: 1, Name: "b", State: Running, Input: []byte(`{"count":1}`)}))
+
+	e := NewSagaExecutor(store)
+	r := &recorder{}
+	require.NoError(t, e.Register(newOrderSaga(r.step("a", false, false), r.step("b", false, false))))
+	require.NoError(t, e.Resume(ctx))
+	assert.Equal(t, []string{"commit b"}, r.calls)
+	record, err := store.GetSaga(ctx, "running")
+	require.NoError(t, err)
+	assert.Equal(t, Success, record.State)
+	assert.JSONEq(t, `{"count":2}`, string(record.Input))
+
+	unfinished, err := store.ListUnfinished(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, unfinished)
+}
+
+func TestSagaExecutor_resumeCompensating(t *testing.T) {
+	ctx := context.Background()
+	store := newMemSagaStore()
+	e := NewSagaExecutor(store)
+	r := &recorder{}
+	failRollback := newOrderSaga(r.step("a", false, false), r.step("b", false, true), r.step("c", true, false))
+	require.NoError(t, e.Register(failRollback))
+
+	// 回滚失败时保持回滚中
+	id, err := e.Execute(ctx, "order", &orderInput{})
+	require.Error(t, err)
+	assert.Equal(t, []string{"commit a", "commit b", "commit c", "rollback c", "rollback b"}, r.calls)
+	record, err := store.GetSaga(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, Compensating, record.State)
+
+	// 租约未过期时其他实例不恢复
+	r = &recorder{}
+	other := NewSagaExecutor(store, WithSagaOwner("other"))
+	require.NoError(t, other.Register(newOrderSaga(r.step("a", false, false), r.step("b", false, false), r.step("c", true, false))))
+	require.NoError(t, other.Resume(ctx))
+	assert.Empty(t, r.calls)
+
+	// 租约过期后由其他实例继续回滚未回滚的步骤
+	record.LeaseUntil = time.Now().Add(-time.Second)
+	require.NoError(t, store.SaveSaga(ctx, record))
+	require.NoError(t, other.Resume(ctx))
+	assert.Equal(t, []string{"rollback b", "rollback a"}, r.calls)
+	record, err = store.GetSaga(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, Compensated, record.State)
+	assert.Equal(t, "other", record.Owner)
+}
+
+func TestSagaExecutor_leaseLost(t *testing.T) {
+	ctx := context.Background()
+	store := newMemSagaStore()
+	e := NewSagaExecutor(store, WithSagaLease(30*time.Millisecond))
+	taken := make(chan struct{})
+	require.NoError(t, e.Register(&Saga{Name: "slow", Steps: []Step{{Name: "wait", Task: NewFuncTask(func(ctx context.Context, _ interface{}) error {
+		<-taken
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil)}}}))
+
+	go func() {
+		// 模拟租约过期后被其他实例接管
+		for {
+			store.mux.Lock()
+			for id, record := range store.sagas {
+				record.Owner = "other"
+				store.sagas[id] = record
+			}
+			n := len(store.sagas)
+			store.mux.Unlock()
+			if n > 0 {
+				close(taken)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	// 续期失败时取消执行,保持执行中由接管的实例继续执行
+	id, err := e.Execute(ctx, "slow", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	record, err := store.GetSaga(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, Running, record.State)
+}
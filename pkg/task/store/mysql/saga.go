@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"template/pkg/task"
+)
+
+// NewSagaStore 基于数据库的saga执行状态存储
+func NewSagaStore(db *gorm.DB) task.SagaStore {
+	return &sagaStore{
+		DB: db,
+	}
+}
+
+type sagaStore struct {
+	*gorm.DB
+}
+
+// unfinished 需要恢复执行的状态
+var unfinished = []task.State{task.Running, task.Compensating}
+
+// SaveSaga 保存执行状态,已存在时覆盖
+func (s *sagaStore) SaveSaga(ctx context.Context, record *task.SagaRecord) error {
+	if err := s.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(record).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// GetSaga 根据ID获取执行状态
+func (s *sagaStore) GetSaga(ctx context.Context, id string) (*task.SagaRecord, error) {
+	var obj task.SagaRecord
+	if err := s.WithContext(ctx).Where("id = ?", id).First(&obj).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithStack(task.ErrSagaNotFound)
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &obj, nil
+}
+
+// ListUnfinished 获取租约在now之前过期的执行中及回滚中的saga
+func (s *sagaStore) ListUnfinished(ctx context.Context, now time.Time) ([]*task.SagaRecord, error) {
+	var list []*task.SagaRecord
+	if err := s.WithContext(ctx).Where("state IN ? AND lease_until < ?", unfinished, now).
+		Order("created_at").Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+// ClaimSaga 未完成的saga租约属于owner或在now之前过期时,由owner持有租约至until
+func (s *sagaStore) ClaimSaga(ctx context.Context, id, owner string, now, until time.Time) (bool, error) {
+	result := s.WithContext(ctx).Model(&task.SagaRecord{}).
+		Where("id = ? AND state IN ? AND (owner = ? OR lease_until < ?)", id, unfinished, owner, now).
+		Updates(map[string]interface{}{"owner": owner, "lease_until": until})
+	if result.Error != nil {
+		return false, errors.WithStack(result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SaveStep 保存步骤状态,已存在时覆盖
+func (s *sagaStore) SaveStep(ctx context.Context, step *task.SagaStep) error {
+	if err := s.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(step).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ListSteps 按步骤序号获取saga的所有步骤
+func (s *sagaStore) ListSteps(ctx context.Context, sagaID string) ([]*task.SagaStep, error) {
+	var list []*task.SagaStep
+	if err := s.WithContext(ctx).Where("saga_id = ?", sagaID).Order("step").Find(&list).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
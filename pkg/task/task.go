@@ -17,6 +17,8 @@ type taskOption struct {
 	commitCallbacks   []Callback
 	rollbackCallbacks []Callback
 	tasks             []Task
+	nodes             []Node
+	maxConcurrency    int
 }
 
 type Option func(*taskOption)
@@ -45,6 +47,20 @@ func WithTasks(tasks ...Task) Option {
 	}
 }
 
+// WithNodes 设置DAG任务的节点
+func WithNodes(nodes ...Node) Option {
+	return func(option *taskOption) {
+		option.nodes = nodes
+	}
+}
+
+// WithMaxConcurrency 限制同时执行的任务数,默认不限制
+func WithMaxConcurrency(n int) Option {
+	return func(option *taskOption) {
+		option.maxConcurrency = n
+	}
+}
+
 // Task is library's minimum unit
 type Task interface {
 	Commit(ctx context.Context, input interface{}, callbacks ...Callback) error
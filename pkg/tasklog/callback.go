@@ -0,0 +1,76 @@
+package tasklog
+
+import (
+	"context"
+	"sync"
+
+	"template/pkg/task"
+)
+
+// Execute 执行任务并记录任务日志
+func Execute(ctx context.Context, taskType string, t task.Task, input interface{}, callbacks ...task.Callback) error {
+	return task.Execute(ctx, t, input, append(callbacks, NewTaskCallback(taskType))...)
+}
+
+// TaskCallback 通过 task.Execute 执行时记录任务日志:开始时创建日志,
+// 每个子任务提交完成时刷新进度,结束时记录结果。每次执行需创建新的实例
+type TaskCallback struct {
+	taskType    string
+	client      *TaskLogClient
+	created     bool
+	rollingBack bool
+	completed   sync.Map
+}
+
+func NewTaskCallback(taskType string) *TaskCallback {
+	return &TaskCallback{
+		taskType: taskType,
+		client:   NewTaskLog(),
+	}
+}
+
+func (c *TaskCallback) ResourceId(resId uint64) *TaskCallback {
+	c.client.ResourceId(resId)
+	return c
+}
+
+// Start 创建任务日志,以子任务数作为资源数
+func (c *TaskCallback) Start(ctx context.Context, t task.Task, input interface{}) {
+	if taskLogStore == nil {
+		return
+	}
+	name := c.taskType
+	if info, ok := t.(task.StoreInfo); ok && info.Name() != "" {
+		name = info.Name()
+	}
+	c.client.ResourceNum(task.CountLeaves(t)).InputParam(input)
+	c.created = c.client.Create(ctx, name, c.taskType) == nil
+}
+
+// Trigger 子任务提交成功时刷新进度,重试的子任务不重复计算,回滚的子任务不计算
+func (c *TaskCallback) Trigger(ctx context.Context, info task.StoreInfo, _ interface{}, err error) {
+	if !c.created || c.rollingBack || err != nil {
+		return
+	}
+	if _, loaded := c.completed.LoadOrStore(info.ID(), struct{}{}); loaded {
+		return
+	}
+	_ = c.client.RefreshProgress(ctx, 1)
+}
+
+// Rollback 提交失败后开始回滚,不再刷新进度
+func (c *TaskCallback) Rollback(context.Context, task.Task, interface{}, error) {
+	c.rollingBack = true
+}
+
+// Finish 记录执行结果,任务的元数据作为结果
+func (c *TaskCallback) Finish(ctx context.Context, t task.Task, _ interface{}, err error) {
+	if !c.created {
+		return
+	}
+	var result interface{}
+	if info, ok := t.(task.StoreInfo); ok && len(info.Metadata()) > 0 {
+		result = info.Metadata()
+	}
+	_ = c.client.Finish(ctx, result, err)
+}
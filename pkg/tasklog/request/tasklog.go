@@ -12,3 +12,12 @@ type QueryTaskLogReq struct {
 	IsRelation int    `form:"is_relation"` // 当 page_size 为 1时 生效，是否通过 trace_id 查出其它数据， 1 表示 是
 	ShowInfo   int    `form:"show_info"`   // 1 显示详情
 }
+
+// QueryTaskLogCursorReq 游标分页查询,请求中携带cursor参数时使用,默认按start_time倒序
+type QueryTaskLogCursorReq struct {
+	database.CursorPagination
+	TraceId    string `form:"trace_id"`    // traceID
+	ResourceId string `form:"resource_id"` // 资源ID
+	TaskType   string `form:"task_type"`   // 任务类型
+	ShowInfo   int    `form:"show_info"`   // 1 显示详情
+}
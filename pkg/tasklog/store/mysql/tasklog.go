@@ -8,6 +8,7 @@ import (
 	"gorm.io/gorm"
 
 	e "template/pkg/code"
+	database "template/pkg/storage"
 	"template/pkg/tasklog/model"
 	"template/pkg/tasklog/request"
 )
@@ -87,7 +88,7 @@ func (r *taskLog) List(ctx context.Context, data *request.QueryTaskLogReq) ([]*m
 	}
 
 	if data.ResourceId != "" {
-		query = query.Where("resource_id = ?", data.ResourceId)
+		query = query.Where("res_id = ?", data.ResourceId)
 	}
 
 	if data.TaskType != "" {
@@ -109,3 +110,38 @@ func (r *taskLog) List(ctx context.Context, data *request.QueryTaskLogReq) ([]*m
 
 	return list, nil
 }
+
+// ListByCursor 按条件游标分页查询
+func (r *taskLog) ListByCursor(ctx context.Context, data *request.QueryTaskLogCursorReq) ([]*model.TaskLog, error) {
+	var list []*model.TaskLog
+	query := r.WithContext(ctx).Model(&model.TaskLog{})
+	if data.ShowInfo != 1 {
+		query = query.Select("id,created_at,updated_at,start_time,end_time,task_name,task_type,progress,res_id,trace_id,status")
+	}
+
+	if data.TraceId != "" {
+		query = query.Where("trace_id = ?", data.TraceId)
+	}
+
+	if data.ResourceId != "" {
+		query = query.Where("res_id = ?", data.ResourceId)
+	}
+
+	if data.TaskType != "" {
+		query = query.Where("task_type = ?", data.TaskType)
+	}
+
+	if data.SortField == "" {
+		data.SortField = "start_time desc"
+	}
+	if err := data.Build(ctx, query).Find(&list).Error; err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			return nil, errors.WithStack(e.ErrCodeInvalidParam.WithResult(err.Error()))
+		}
+		return nil, errors.WithStack(err)
+	}
+	if err := data.Next(&list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
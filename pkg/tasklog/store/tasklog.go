@@ -21,4 +21,6 @@ type TaskLog interface {
 	DeleteByIds(ctx context.Context, ids []string) error
 	Get(ctx context.Context, id string, selectQuery ...string) (*model.TaskLog, error)
 	List(ctx context.Context, req *request.QueryTaskLogReq) ([]*model.TaskLog, error)
+	// ListByCursor 游标分页查询,查询后会设置 req.NextCursor
+	ListByCursor(ctx context.Context, req *request.QueryTaskLogCursorReq) ([]*model.TaskLog, error)
 }
@@ -211,6 +211,11 @@ func GetTaskLog(c *gin.Context) {
 }
 
 func ListTaskLog(c *gin.Context) {
+	// 携带cursor参数时使用游标分页,首页cursor为空
+	if _, ok := c.GetQuery("cursor"); ok {
+		listTaskLogByCursor(c)
+		return
+	}
 	ctx := c.Request.Context()
 	var req request.QueryTaskLogReq
 
@@ -239,3 +244,33 @@ func ListTaskLog(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, result)
 }
+
+func listTaskLogByCursor(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.QueryTaskLogCursorReq
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.ErrorParam(c, err)
+		return
+	}
+	list, err := taskLogStore.ListByCursor(ctx, &req)
+	if err != nil {
+		resp.Error(c, err)
+		return
+	}
+	result := make([]response.TaskLogRes, len(list))
+	for i, log := range list {
+		var cost int64
+		if log.Status == model.StatusRunning {
+			cost = time.Since(log.StartTime).Milliseconds()
+			list[i].Progress = getProgress(log.PK())
+		} else {
+			cost = log.EndTime.Sub(log.StartTime).Milliseconds()
+		}
+		result[i] = response.TaskLogRes{
+			TaskLog: list[i],
+			Cost:    cost,
+		}
+	}
+	resp.CursorList(c, result, req.PageSize, req.NextCursor, req.Total)
+}
@@ -19,6 +19,10 @@ var (
 
 	HeaderCacheControl = textproto.CanonicalMIMEHeaderKey("Cache-Control")
 
+	// 幂等请求的key及重复请求返回已保存响应的标识
+	HeaderIdempotencyKey     = textproto.CanonicalMIMEHeaderKey("Idempotency-Key")
+	HeaderIdempotentReplayed = textproto.CanonicalMIMEHeaderKey("Idempotent-Replayed")
+
 	// 网关解析的请求头
 	HeaderGWAccountID = textproto.CanonicalMIMEHeaderKey("Accountid")
 	HeaderGWUserID    = textproto.CanonicalMIMEHeaderKey("Userid")